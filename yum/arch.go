@@ -0,0 +1,73 @@
+package yum
+
+import (
+	"sort"
+	"strings"
+)
+
+// archRank ranks the architecture of a package according to the Arch
+// preference of the repository: the preferred architecture first, then
+// noarch, then any other one.
+func (repo *Repository) archRank(arch string) int {
+	switch arch {
+	case repo.Arch:
+		return 0
+	case "noarch":
+		return 1
+	}
+	return 2
+}
+
+// preferArch returns the package satisfying match with the best ranked
+// architecture among the packages named like pkg, the newest one winning
+// among packages of the same rank.
+// pkg is returned if it is not beaten by any other candidate.
+func (repo *Repository) preferArch(pkg *Package, match func(p *Package) bool) (*Package, error) {
+	if repo.Arch == "" || repo.archRank(pkg.Arch()) == 0 {
+		return pkg, nil
+	}
+
+	pkgs, err := repo.FindAllMatchingName(pkg.Name())
+	if err != nil {
+		// e.g. a file provider only known from the filelists metadata.
+		return pkg, nil
+	}
+
+	cands := make(Packages, 0, len(pkgs))
+	for _, p := range pkgs {
+		if match(p) {
+			cands = append(cands, p)
+		}
+	}
+	if len(cands) == 0 {
+		return pkg, nil
+	}
+	sort.Stable(sort.Reverse(cands))
+
+	best := pkg
+	for _, p := range cands {
+		if repo.archRank(p.Arch()) < repo.archRank(best.Arch()) {
+			best = p
+		}
+	}
+	return best, nil
+}
+
+// archMatchingName returns a predicate selecting the packages at version and
+// release (when not empty).
+func archMatchingName(name, version, release string) func(p *Package) bool {
+	if version == "" {
+		return func(p *Package) bool { return true }
+	}
+	req := NewRequires(name, version, release, "", "EQ", "")
+	return func(p *Package) bool { return req.ProvideMatches(p) }
+}
+
+// archMatchingRequire returns a predicate selecting the packages satisfying
+// requirement.
+func archMatchingRequire(requirement *Requires) func(p *Package) bool {
+	return func(p *Package) bool {
+		// files are not listed among the provides of packages.
+		return strings.HasPrefix(requirement.Name(), "/") || p.Satisfies(requirement)
+	}
+}
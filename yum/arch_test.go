@@ -0,0 +1,77 @@
+package yum
+
+import (
+	"testing"
+)
+
+func TestRepositoryArch(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		arch     string
+		version  string
+		expected string
+	}{
+		{"", "", "foo-2.0-1"},
+		{"", "1.5", "foo-1.5-2"},
+		{"x86_64", "", "foo-2.0-1"},
+		{"x86_64", "1.5", "foo-1.5-1"},
+		{"i686", "", "foo-1.5-2"},
+		{"i686", "1.5", "foo-1.5-2"},
+		{"ppc64le", "1.5", "foo-1.5-2"},
+	} {
+		repo.Arch = table.arch
+		pkg, err := repo.FindLatestMatchingName("foo", table.version, "")
+		if err != nil {
+			t.Fatalf("arch=%q: could not find package: %v\n", table.arch, err)
+		}
+		if pkg.ID() != table.expected {
+			t.Fatalf("arch=%q version=%q: expected %s. got=%s\n", table.arch, table.version, table.expected, pkg.ID())
+		}
+	}
+
+	for _, table := range []struct {
+		arch     string
+		expected string
+	}{
+		{"", "foo-2.0-1"},
+		{"x86_64", "foo-2.0-1"},
+		{"i686", "foo-1.5-2"},
+	} {
+		repo.Arch = table.arch
+		pkg, err := repo.FindLatestMatchingRequire(NewRequires("foo", "", "", "", "", ""))
+		if err != nil {
+			t.Fatalf("arch=%q: could not find provider: %v\n", table.arch, err)
+		}
+		if pkg.ID() != table.expected {
+			t.Fatalf("arch=%q: expected %s. got=%s\n", table.arch, table.expected, pkg.ID())
+		}
+	}
+
+	// noarch packages are preferred over packages of other architectures.
+	mem := newTestMemoryRepository(t)
+	defer mem.Close()
+	zlib := NewPackage("zlib", "1.3", "1", "0")
+	zlib.SetArch("i686")
+	zlib.AddProvides(NewProvides("libz", "", "", "", "", nil))
+	mem.Backend = NewMemoryBackend(append(mem.GetPackages(), zlib))
+
+	for _, table := range []struct {
+		arch     string
+		expected string
+	}{
+		{"", "i686"},
+		{"x86_64", "noarch"},
+		{"i686", "i686"},
+	} {
+		mem.Arch = table.arch
+		pkg, err := mem.FindLatestMatchingRequire(NewRequires("libz", "", "", "", "", ""))
+		if err != nil {
+			t.Fatalf("arch=%q: could not find provider: %v\n", table.arch, err)
+		}
+		if pkg.Arch() != table.expected {
+			t.Fatalf("arch=%q: expected a %s package. got=%s\n", table.arch, table.expected, pkg.ID()+"."+pkg.Arch())
+		}
+	}
+}
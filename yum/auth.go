@@ -0,0 +1,160 @@
+package yum
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// authHeader returns header, completed with the Authorization header needed
+// to retrieve rpath, if any.
+// Explicit credentials (BearerToken, Username/Password) take precedence over
+// the ones found in the netrc file.
+func (repo *Repository) authHeader(rpath string, header http.Header) http.Header {
+	u, err := url.Parse(rpath)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return header
+	}
+
+	auth := ""
+	switch {
+	case repo.BearerToken != "":
+		auth = "Bearer " + repo.BearerToken
+	case repo.Username != "":
+		auth = basicAuth(repo.Username, repo.Password)
+	case u.User != nil:
+		// credentials embedded in the URL are handled by net/http.
+		return header
+	default:
+		login, passwd, ok := netrcCredentials(repo.netrcFile(), u.Hostname())
+		if !ok {
+			return header
+		}
+		auth = basicAuth(login, passwd)
+	}
+
+	hdr := make(http.Header, len(header)+1)
+	for k, v := range header {
+		hdr[k] = v
+	}
+	hdr.Set("Authorization", auth)
+	return hdr
+}
+
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// netrcFile returns the path to the netrc file holding credentials:
+// repo.Netrc, $NETRC or ~/.netrc.
+func (repo *Repository) netrcFile() string {
+	if repo.Netrc != "" {
+		return repo.Netrc
+	}
+	if fname := os.Getenv("NETRC"); fname != "" {
+		return fname
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcCredentials returns the login and password for host from the netrc
+// file fname, falling back on the "default" entry.
+func netrcCredentials(fname, host string) (string, string, bool) {
+	if fname == "" {
+		return "", "", false
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", "", false
+	}
+	defer f.Close()
+
+	type entry struct {
+		login    string
+		password string
+	}
+
+	var (
+		cur      *entry
+		found    *entry
+		fallback *entry
+		macdef   bool
+	)
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := scan.Text()
+		if macdef {
+			// macro definitions end with an empty line.
+			macdef = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		toks := strings.Fields(line)
+		for i := 0; i < len(toks); i++ {
+			switch toks[i] {
+			case "machine":
+				cur = nil
+				if i+1 < len(toks) {
+					i++
+					if toks[i] == host && found == nil {
+						found = &entry{}
+						cur = found
+					}
+				}
+			case "default":
+				cur = nil
+				if fallback == nil {
+					fallback = &entry{}
+					cur = fallback
+				}
+			case "login", "password":
+				if i+1 >= len(toks) {
+					continue
+				}
+				i++
+				if cur == nil {
+					continue
+				}
+				if toks[i-1] == "login" {
+					cur.login = toks[i]
+				} else {
+					cur.password = toks[i]
+				}
+			case "account":
+				i++
+			case "macdef":
+				macdef = true
+				i = len(toks)
+			}
+		}
+	}
+
+	switch {
+	case found != nil:
+		return found.login, found.password, true
+	case fallback != nil:
+		return fallback.login, fallback.password, true
+	}
+	return "", "", false
+}
+
+// redact returns rawurl with its password, if any, masked.
+func redact(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	return u.Redacted()
+}
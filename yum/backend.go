@@ -2,6 +2,7 @@ package yum
 
 import (
 	"fmt"
+	"sort"
 )
 
 // global registry of known backends
@@ -16,6 +17,23 @@ func NewBackend(backend string, repo *Repository) (Backend, error) {
 	return factory(repo)
 }
 
+// RegisteredBackends returns the sorted names of all the registered backends.
+// Some backends are registered under several names.
+func RegisteredBackends() []string {
+	names := make([]string, 0, len(g_backends))
+	for name := range g_backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasBackend returns whether a backend is registered under name.
+func HasBackend(name string) bool {
+	_, ok := g_backends[name]
+	return ok
+}
+
 // Backend queries a YUM DB repository
 type Backend interface {
 
@@ -37,9 +55,25 @@ type Backend interface {
 	// FindLatestMatchingName locats a package by name, returns the latest available version.
 	FindLatestMatchingName(name, version, release string) (*Package, error)
 
+	// FindAllMatchingName locates all the packages with a given name,
+	// sorted from the newest to the oldest version.
+	FindAllMatchingName(name string) ([]*Package, error)
+
 	// FindLatestMatchingRequire locates a package providing a given functionality.
 	FindLatestMatchingRequire(requirement *Requires) (*Package, error)
 
 	// GetPackages returns all the packages known by a YUM repository
 	GetPackages() []*Package
+
+	// ListPackages returns the packages whose name matches the shell pattern,
+	// sorted by name and version.
+	ListPackages(pattern string) ([]*Package, error)
+
+	// FindObsoleting returns the packages obsoleting the package name,
+	// sorted by name and version.
+	FindObsoleting(name string) ([]*Package, error)
+
+	// FindRequiring returns the packages requiring the capability name,
+	// sorted by name and version.
+	FindRequiring(name string) ([]*Package, error)
 }
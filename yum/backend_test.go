@@ -0,0 +1,22 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisteredBackends(t *testing.T) {
+	want := []string{"RepositorySQLiteBackend", "RepositoryXMLBackend", "primary", "primary_db"}
+	if got := RegisteredBackends(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v. got=%v\n", want, got)
+	}
+
+	for _, name := range want {
+		if !HasBackend(name) {
+			t.Fatalf("expected backend [%s] to be registered\n", name)
+		}
+	}
+	if HasBackend("nosuchbackend") {
+		t.Fatalf("expected backend [nosuchbackend] not to be registered\n")
+	}
+}
@@ -0,0 +1,187 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheFresh returns whether the cached repo metadata was retrieved (or
+// checked against the remote repository) less than repo.CacheTTL ago.
+func (repo *Repository) cacheFresh() bool {
+	if repo.CacheTTL <= 0 {
+		return false
+	}
+	fi, err := os.Stat(repo.LocalRepoMdXml)
+	if err != nil {
+		return false
+	}
+	return time.Since(fi.ModTime()) < repo.CacheTTL
+}
+
+// touchMetadata records that the cached repo metadata was just checked
+// against the remote repository.
+func (repo *Repository) touchMetadata() {
+	now := time.Now()
+	err := os.Chtimes(repo.LocalRepoMdXml, now, now)
+	if err != nil && !os.IsNotExist(err) {
+		repo.msg.Warnf("repository [%s] - could not touch [%s]: %v\n", repo.Name, repo.LocalRepoMdXml, err)
+	}
+}
+
+// ExpireCache marks the cached repo metadata as expired: the next setup
+// or refresh of the repository checks the remote repository, whatever
+// repo.CacheTTL.
+// The cached DBs are kept, and only downloaded again if outdated.
+func (repo *Repository) ExpireCache() error {
+	epoch := time.Unix(0, 0)
+	err := os.Chtimes(repo.LocalRepoMdXml, epoch, epoch)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// backendFile returns the path to the file recording the name of the
+// backend last chosen for the repository.
+func (repo *Repository) backendFile() string {
+	return repo.LocalRepoMdXml + ".backend"
+}
+
+// recordBackend records bname as the backend chosen for the repository, so
+// the next setup from the cache tries it first.
+func (repo *Repository) recordBackend(bname string) {
+	fname := repo.backendFile()
+	if data, err := ioutil.ReadFile(fname); err == nil && strings.TrimSpace(string(data)) == bname {
+		return
+	}
+	err := write_file_atomic(fname, func(w io.Writer) error {
+		_, err := io.WriteString(w, bname+"\n")
+		return err
+	})
+	if err != nil {
+		repo.msg.Warnf("repository [%s] - could not record backend [%s]: %v\n", repo.Name, bname, err)
+	}
+}
+
+// localBackends returns the backends to try when setting up the repository
+// from its cache: the one last chosen first, if any, then the other ones of
+// repo.Backends, in order of preference.
+func (repo *Repository) localBackends() []string {
+	data, err := ioutil.ReadFile(repo.backendFile())
+	if err != nil {
+		return repo.Backends
+	}
+	last := strings.TrimSpace(string(data))
+	if len(repo.Backends) == 0 || repo.Backends[0] == last || !str_in_slice(last, repo.Backends) {
+		return repo.Backends
+	}
+
+	backends := make([]string, 0, len(repo.Backends))
+	backends = append(backends, last)
+	for _, bname := range repo.Backends {
+		if bname != last {
+			backends = append(backends, bname)
+		}
+	}
+	return backends
+}
+
+// cacheFiles returns the files the repository owns in its cache
+// directory: the repo metadata and the files recording its ETag and chosen
+// backend, the metadata files listed in the cached repo metadata, the DBs
+// (and their indices) of the backends and the interrupted downloads.
+func (repo *Repository) cacheFiles() []string {
+	type cacheFiler interface {
+		cacheFiles() []string
+	}
+
+	files := []string{
+		repo.LocalRepoMdXml,
+		repo.LocalRepoMdXml + ".etag",
+		repo.backendFile(),
+	}
+
+	if data, err := repo.localMetadata(); err == nil {
+		md, err := repo.checkRepoMD(data)
+		if err != nil {
+			repo.msg.Warnf("repository [%s] - could not parse cached metadata: %v\n", repo.Name, err)
+		}
+		for _, rmd := range md {
+			files = append(files, filepath.Join(repo.CacheDir, path.Base(rmd.Location)))
+		}
+	}
+
+	backends := make([]Backend, 0, len(repo.Backends)+1)
+	if repo.Backend != nil {
+		backends = append(backends, repo.Backend)
+	}
+	for _, bname := range repo.Backends {
+		ba, err := NewBackend(bname, repo)
+		if err == nil {
+			backends = append(backends, ba)
+		}
+	}
+	for _, ba := range backends {
+		if c, ok := ba.(cacheFiler); ok {
+			files = append(files, c.cacheFiles()...)
+		}
+	}
+
+	fis, _ := ioutil.ReadDir(repo.CacheDir)
+	for _, fi := range fis {
+		if !fi.IsDir() && strings.HasPrefix(fi.Name(), "download-") {
+			files = append(files, filepath.Join(repo.CacheDir, fi.Name()))
+		}
+	}
+	return files
+}
+
+// CleanCache removes the cached repo metadata and DBs of the repository,
+// after closing its backend: the repository must be set up again (see
+// SetupBackend and Refresh) before being queried.
+// Only the files owned by the repository are removed, so the cache
+// directory may be shared with other data. In particular, the package cache
+// (see PackageCacheDir) is kept: use CleanPackageCache to remove it.
+func (repo *Repository) CleanCache() error {
+	files := repo.cacheFiles()
+
+	err := repo.Close()
+	if err != nil {
+		repo.msg.Warnf("repository [%s] - could not close backend: %v\n", repo.Name, err)
+	}
+	repo.Backend = nil
+	repo.mdetag = ""
+
+	var errs []error
+	for _, fname := range files {
+		err := os.Remove(fname)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("yum: could not clean cache of repository [%s]: %w", repo.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Refresh cleans the cache of the repository (see CleanCache) and sets the
+// repository up again from the remote repository.
+func (repo *Repository) Refresh() error {
+	if repo.Offline {
+		return fmt.Errorf("%w: can not refresh repository [%s]", ErrOffline, repo.Name)
+	}
+	err := repo.CleanCache()
+	if err != nil {
+		return err
+	}
+	return repo.SetupBackend(true)
+}
@@ -0,0 +1,247 @@
+package yum
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRepositoryCacheTTL(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	var mu sync.Mutex
+	nreqs := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		nreqs++
+		mu.Unlock()
+		data, err := ioutil.ReadFile(filepath.Join(remote, strings.TrimPrefix(req.URL.Path, "/")))
+		if err != nil {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(strings.NewReader("not found")),
+				Request:    req,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	})
+	requests := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := nreqs
+		nreqs = 0
+		return n
+	}
+
+	setup := func() *Repository {
+		repo, err := NewRepository(
+			"lcg", "http://dummy-url.org", cachedir,
+			[]string{"RepositoryXMLBackend"},
+			false, false,
+		)
+		if err != nil {
+			t.Fatalf("could not create repository: %v\n", err)
+		}
+		repo.CacheTTL = time.Hour
+		repo.SetTransport(transport)
+		err = repo.SetupBackend(true)
+		if err != nil {
+			t.Fatalf("could not setup backend: %v\n", err)
+		}
+		return repo
+	}
+
+	repo := setup()
+	defer repo.Close()
+	if requests() == 0 {
+		t.Fatalf("expected the remote repository to be hit on an empty cache\n")
+	}
+
+	// fresh cache.
+	cached := setup()
+	defer cached.Close()
+	if n := requests(); n != 0 {
+		t.Fatalf("expected a fresh cache not to hit the remote repository. got=%d requests\n", n)
+	}
+	err = cached.RefreshDB()
+	if err != nil {
+		t.Fatalf("could not refresh DB: %v\n", err)
+	}
+	if n := requests(); n != 0 {
+		t.Fatalf("expected a fresh cache not to be refreshed. got=%d requests\n", n)
+	}
+
+	// expired cache.
+	err = cached.ExpireCache()
+	if err != nil {
+		t.Fatalf("could not expire cache: %v\n", err)
+	}
+	err = cached.RefreshDB()
+	if err != nil {
+		t.Fatalf("could not refresh DB: %v\n", err)
+	}
+	if requests() == 0 {
+		t.Fatalf("expected an expired cache to be refreshed\n")
+	}
+	if !cached.cacheFresh() {
+		t.Fatalf("expected the cache to be fresh after a refresh\n")
+	}
+
+	cached.CacheTTL = 0
+	if cached.cacheFresh() {
+		t.Fatalf("expected the cache to never be fresh without TTL\n")
+	}
+}
+
+func TestRepositoryCleanCache(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+	npkgs := len(repo.GetPackages())
+
+	repo.PackageCacheDir = filepath.Join(cachedir, "packages")
+	err = os.MkdirAll(repo.PackageCacheDir, 0755)
+	if err != nil {
+		t.Fatalf("could not create package cache: %v\n", err)
+	}
+	rpm := filepath.Join(repo.PackageCacheDir, "foo-1.0-1.noarch.rpm")
+	err = ioutil.WriteFile(rpm, []byte("rpm"), 0644)
+	if err != nil {
+		t.Fatalf("could not fill package cache: %v\n", err)
+	}
+
+	// the cache directory may be shared with unrelated data.
+	other := filepath.Join(cachedir, "unrelated.txt")
+	err = ioutil.WriteFile(other, []byte("keep me"), 0644)
+	if err != nil {
+		t.Fatalf("could not write unrelated file: %v\n", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(cachedir, "download-123-primary.xml.gz"), []byte("partial"), 0644)
+	if err != nil {
+		t.Fatalf("could not write interrupted download: %v\n", err)
+	}
+
+	err = repo.CleanCache()
+	if err != nil {
+		t.Fatalf("could not clean cache: %v\n", err)
+	}
+	if repo.Backend != nil {
+		t.Fatalf("expected the backend to be closed\n")
+	}
+	fis, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		t.Fatalf("could not read cachedir: %v\n", err)
+	}
+	var left []string
+	for _, fi := range fis {
+		left = append(left, fi.Name())
+	}
+	if want := []string{"packages", "unrelated.txt"}; !reflect.DeepEqual(left, want) {
+		t.Fatalf("invalid cache content after cleaning.\ngot= %v\nwant=%v\n", left, want)
+	}
+	if !path_exists(rpm) {
+		t.Fatalf("expected the package cache to be kept\n")
+	}
+
+	repo.Offline = true
+	err = repo.Refresh()
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+	repo.Offline = false
+
+	err = repo.Refresh()
+	if err != nil {
+		t.Fatalf("could not refresh repository: %v\n", err)
+	}
+	if !path_exists(repo.LocalRepoMdXml) {
+		t.Fatalf("expected local repomd.xml file [%s]\n", repo.LocalRepoMdXml)
+	}
+	if got := len(repo.GetPackages()); got != npkgs {
+		t.Fatalf("invalid number of packages. got=%d. want=%d\n", got, npkgs)
+	}
+}
+
+func TestRepositoryRecordedBackend(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	backends := []string{"RepositorySQLiteBackend", "RepositoryXMLBackend"}
+	repo, err := NewRepository("lcg", "file://"+remote, cachedir, backends, true, true)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Close()
+
+	data, err := ioutil.ReadFile(filepath.Join(cachedir, "repomd.xml.backend"))
+	if err != nil {
+		t.Fatalf("expected the chosen backend to be recorded: %v\n", err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "RepositoryXMLBackend"; got != want {
+		t.Fatalf("invalid recorded backend. got=%q. want=%q\n", got, want)
+	}
+
+	repo, err = NewRepository("lcg", "file://"+remote, cachedir, backends, false, false)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+	if got, want := repo.localBackends(), []string{"RepositoryXMLBackend", "RepositorySQLiteBackend"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid order of backends. got=%v. want=%v\n", got, want)
+	}
+
+	err = repo.SetupBackend(false)
+	if err != nil {
+		t.Fatalf("could not setup repository from cache: %v\n", err)
+	}
+	if _, ok := repo.Backend.(*RepositoryXMLBackend); !ok {
+		t.Fatalf("expected the XML backend. got=%T\n", repo.Backend)
+	}
+
+	// a recorded backend which is not configured any more is ignored.
+	repo.Backends = []string{"RepositorySQLiteBackend"}
+	if got, want := repo.localBackends(), repo.Backends; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid order of backends. got=%v. want=%v\n", got, want)
+	}
+}
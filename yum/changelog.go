@@ -0,0 +1,135 @@
+package yum
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// otherDataType is the ID of the other (changelogs) data in the repomd.xml file
+const otherDataType = "other"
+
+// ChangelogEntry is an entry of the changelog of a package, from the other
+// metadata of a repository.
+type ChangelogEntry struct {
+	Author string // e.g. "John Doe <jdoe@example.org> - 1.0-1"
+	Date   time.Time
+	Text   string
+}
+
+// Changelog returns the changelog entries of pkg, as listed in the other
+// metadata of the repository (usually from the newest to the oldest).
+// The other metadata is only downloaded on first use, and as it is large it
+// is not kept in memory: each call scans it for the entries of pkg.
+// Changelog returns an empty list if the metadata has no entry for pkg.
+func (repo *Repository) Changelog(pkg *Package) ([]ChangelogEntry, error) {
+	fname, err := repo.metadataFile(otherDataType)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not retrieve changelogs of repository [%s]: %w", repo.Name, err)
+	}
+
+	entries, err := loadChangelog(fname, pkg)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not load changelogs [%s]: %w", fname, err)
+	}
+	return entries, nil
+}
+
+// loadChangelog parses the other XML file fname and returns the changelog
+// entries of pkg.
+func loadChangelog(fname string, pkg *Package) ([]ChangelogEntry, error) {
+	type xmlPackage struct {
+		Version struct {
+			Epoch   string `xml:"epoch,attr"`
+			Version string `xml:"ver,attr"`
+			Release string `xml:"rel,attr"`
+		} `xml:"version"`
+		Changelogs []struct {
+			Author string `xml:"author,attr"`
+			Date   string `xml:"date,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"changelog"`
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	entries := make([]ChangelogEntry, 0)
+
+	// the other metadata is large: decode it one package at a time, only
+	// for the packages with the name and arch of pkg.
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+
+		var name, arch string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "name":
+				name = attr.Value
+			case "arch":
+				arch = attr.Value
+			}
+		}
+		if name != pkg.Name() || arch != pkg.Arch() {
+			err = dec.Skip()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var xpkg xmlPackage
+		err = dec.DecodeElement(&xpkg, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		if RpmEvrCompare(
+			pkg.Epoch(), pkg.Version(), pkg.Release(),
+			xpkg.Version.Epoch, xpkg.Version.Version, xpkg.Version.Release,
+		) != 0 {
+			continue
+		}
+
+		for _, c := range xpkg.Changelogs {
+			date, err := parseTimestamp(c.Date)
+			if err != nil {
+				return nil, fmt.Errorf("yum: invalid date of changelog entry of %s: %w", pkg.NEVRA(), err)
+			}
+			entries = append(entries, ChangelogEntry{
+				Author: strings.TrimSpace(c.Author),
+				Date:   date,
+				Text:   strings.TrimSpace(c.Text),
+			})
+		}
+		break
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,102 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepositoryChangelog(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+
+	foo, err := repo.FindLatestMatchingName("foo", "2.0", "1")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+
+	_, err = repo.Changelog(foo)
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	err = copyTestFile(filepath.Join(remote, "repodata", "other.xml"), "testdata/other.xml")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	repo.RepoUrl = "file://" + remote
+
+	sum, err := checksumFile("testdata/other.xml", "sha256")
+	if err != nil {
+		t.Fatalf("could not compute checksum: %v\n", err)
+	}
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(fmt.Sprintf(`<repomd>
+  <data type="other">
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/other.xml"/>
+  </data>
+</repomd>`, sum)), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	entries, err := repo.Changelog(foo)
+	if err != nil {
+		t.Fatalf("could not load changelog: %v\n", err)
+	}
+	want := []ChangelogEntry{
+		{
+			Author: "Jane Doe <jdoe@example.org> - 2.0-1",
+			Date:   time.Unix(1343606400, 0),
+			Text:   "- update to 2.0\n- fix CVE-2012-1234",
+		},
+		{
+			Author: "Jane Doe <jdoe@example.org> - 1.5-1",
+			Date:   time.Unix(1325376000, 0),
+			Text:   "- update to 1.5",
+		},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("invalid changelog.\ngot= %#v\nwant=%#v\n", entries, want)
+	}
+
+	for _, table := range []struct {
+		name    string
+		version string
+		release string
+		n       int
+	}{
+		{"foo", "1.5", "1", 1},
+		{"foo", "1.5", "2", 0}, // i686, no entry
+		{"bar", "", "", 0},
+	} {
+		pkg, err := repo.FindLatestMatchingName(table.name, table.version, table.release)
+		if err != nil {
+			t.Fatalf("%s-%s-%s: could not find package: %v\n", table.name, table.version, table.release, err)
+		}
+		entries, err := repo.Changelog(pkg)
+		if err != nil {
+			t.Fatalf("%s: could not load changelog: %v\n", pkg.NEVRA(), err)
+		}
+		if len(entries) != table.n {
+			t.Fatalf("%s: expected %d entries. got=%d\n", pkg.NEVRA(), table.n, len(entries))
+		}
+	}
+}
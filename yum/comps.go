@@ -0,0 +1,169 @@
+package yum
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// groupDataTypes are the IDs of the comps data in the repomd.xml file, in
+// order of preference.
+var groupDataTypes = []string{"group_gz", "group"}
+
+// Group is a group of packages, as defined by the comps metadata of a
+// repository (e.g. "Development Tools").
+type Group struct {
+	ID          string
+	Name        string
+	Description string
+	Default     bool // whether the group is installed by default
+	UserVisible bool // whether the group is listed to users
+	Packages    []GroupPackage
+}
+
+// GroupPackage is a package of a Group.
+type GroupPackage struct {
+	Name     string
+	Type     string // mandatory, default, optional or conditional
+	Requires string // package triggering the install of a conditional package
+}
+
+// PackageNames returns the names of the packages installed with the group:
+// its mandatory and default packages.
+func (grp *Group) PackageNames() []string {
+	names := make([]string, 0, len(grp.Packages))
+	for _, pkg := range grp.Packages {
+		switch pkg.Type {
+		case "", "mandatory", "default":
+			names = append(names, pkg.Name)
+		}
+	}
+	return names
+}
+
+// FindGroup locates the package group with the given ID or name (ignoring
+// case), from the comps metadata of the repository.
+// The comps metadata is only loaded (and downloaded if needed) on first use.
+func (repo *Repository) FindGroup(id string) (*Group, error) {
+	groups, err := repo.compsGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, grp := range groups {
+		if grp.ID == id {
+			return grp, nil
+		}
+	}
+	for _, grp := range groups {
+		if strings.EqualFold(grp.Name, id) {
+			return grp, nil
+		}
+	}
+	return nil, fmt.Errorf("yum: no such group %q in repository [%s]", id, repo.Name)
+}
+
+// compsGroups returns the package groups of the repository.
+func (repo *Repository) compsGroups() ([]*Group, error) {
+	if repo.groups != nil {
+		return repo.groups, nil
+	}
+
+	var err error
+	for _, dtype := range groupDataTypes {
+		var fname string
+		fname, err = repo.metadataFile(dtype)
+		if errors.Is(err, ErrMetadataNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not retrieve comps of repository [%s]: %w", repo.Name, err)
+		}
+
+		groups, err := loadComps(fname)
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not load comps [%s]: %w", fname, err)
+		}
+		repo.groups = groups
+		return repo.groups, nil
+	}
+	return nil, err
+}
+
+// loadComps parses the comps XML file fname.
+func loadComps(fname string) ([]*Group, error) {
+	type xmlText struct {
+		Lang  string `xml:"lang,attr"`
+		Value string `xml:",chardata"`
+	}
+
+	type xmlTree struct {
+		XMLName xml.Name `xml:"comps"`
+		Groups  []struct {
+			ID           string    `xml:"id"`
+			Names        []xmlText `xml:"name"`
+			Descriptions []xmlText `xml:"description"`
+			Default      bool      `xml:"default"`
+			UserVisible  *bool     `xml:"uservisible"` // nil when missing: visible
+			Packages     []struct {
+				Type     string `xml:"type,attr"`
+				Requires string `xml:"requires,attr"`
+				Name     string `xml:",chardata"`
+			} `xml:"packagelist>packagereq"`
+		} `xml:"group"`
+	}
+
+	// untranslated returns the text without xml:lang attribute.
+	untranslated := func(texts []xmlText) string {
+		for _, txt := range texts {
+			if txt.Lang == "" {
+				return strings.TrimSpace(txt.Value)
+			}
+		}
+		return ""
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	var tree xmlTree
+	err = xml.NewDecoder(r).Decode(&tree)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*Group, 0, len(tree.Groups))
+	for _, xgrp := range tree.Groups {
+		grp := &Group{
+			ID:          strings.TrimSpace(xgrp.ID),
+			Name:        untranslated(xgrp.Names),
+			Description: untranslated(xgrp.Descriptions),
+			Default:     xgrp.Default,
+			UserVisible: xgrp.UserVisible == nil || *xgrp.UserVisible,
+			Packages:    make([]GroupPackage, 0, len(xgrp.Packages)),
+		}
+		for _, p := range xgrp.Packages {
+			grp.Packages = append(grp.Packages, GroupPackage{
+				Name:     strings.TrimSpace(p.Name),
+				Type:     p.Type,
+				Requires: p.Requires,
+			})
+		}
+		groups = append(groups, grp)
+	}
+	return groups, nil
+}
@@ -0,0 +1,118 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepositoryFindGroup(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	err = copyTestFile(filepath.Join(remote, "repodata", "comps.xml"), "testdata/comps.xml")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	repo.RepoUrl = "file://" + remote
+
+	// no comps metadata.
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(`<repomd></repomd>`), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+	_, err = repo.FindGroup("foo-tools")
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+
+	sum, err := checksumFile("testdata/comps.xml", "sha256")
+	if err != nil {
+		t.Fatalf("could not compute checksum: %v\n", err)
+	}
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(fmt.Sprintf(`<repomd>
+  <data type="group">
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/comps.xml"/>
+  </data>
+</repomd>`, sum)), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	for _, id := range []string{"foo-tools", "Foo Tools", "foo tools"} {
+		grp, err := repo.FindGroup(id)
+		if err != nil {
+			t.Fatalf("could not find group %q: %v\n", id, err)
+		}
+		if grp.ID != "foo-tools" || grp.Name != "Foo Tools" || grp.Description != "Tools to work with foo." {
+			t.Fatalf("invalid group %q: %#v\n", id, grp)
+		}
+		if !grp.Default || !grp.UserVisible {
+			t.Fatalf("invalid group %q flags: %#v\n", id, grp)
+		}
+		if want := []string{"foo", "cyc-a", "nosuchpackage"}; !reflect.DeepEqual(grp.PackageNames(), want) {
+			t.Fatalf("invalid package names. got=%v. want=%v\n", grp.PackageNames(), want)
+		}
+	}
+
+	if !path_exists(filepath.Join(repo.CacheDir, "comps.xml")) {
+		t.Fatalf("expected comps to be cached\n")
+	}
+
+	// groups are visible unless stated otherwise.
+	for _, test := range []struct {
+		id      string
+		visible bool
+	}{
+		{"foo-tools", true},
+		{"empty", false},
+		{"implicit", true},
+	} {
+		grp, err := repo.FindGroup(test.id)
+		if err != nil {
+			t.Fatalf("could not find group %q: %v\n", test.id, err)
+		}
+		if grp.UserVisible != test.visible {
+			t.Fatalf("group %q: invalid visibility. got=%v. want=%v\n", test.id, grp.UserVisible, test.visible)
+		}
+	}
+
+	_, err = repo.FindGroup("nosuchgroup")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown group\n")
+	}
+
+	plan, err := repo.PlanInstall([]string{"@foo-tools"})
+	if err != nil {
+		t.Fatalf("could not plan install: %v\n", err)
+	}
+	names := make([]string, 0, len(plan.Packages))
+	for _, pkg := range plan.Packages {
+		names = append(names, pkg.Name)
+	}
+	if want := []string{"bar", "foo", "cyc-b", "cyc-a"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected %v. got=%v\n", want, names)
+	}
+
+	_, err = repo.PlanInstall([]string{"@nosuchgroup"})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown group\n")
+	}
+}
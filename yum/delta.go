@@ -0,0 +1,178 @@
+package yum
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// deltaDataTypes are the IDs of the delta RPMs data in the repomd.xml file,
+// in order of preference.
+var deltaDataTypes = []string{"prestodelta", "deltainfo"}
+
+// DeltaRPM describes a delta RPM, from which a package can be rebuilt out
+// of an older version of that package.
+type DeltaRPM struct {
+	Name    string
+	Arch    string
+	Epoch   string
+	Version string
+	Release string
+
+	OldEpoch   string
+	OldVersion string
+	OldRelease string
+
+	Location     string // location of the delta RPM, relative to the base URL of the repository
+	Sequence     string
+	Size         int64
+	Checksum     string
+	ChecksumType string
+}
+
+// FindDelta returns the delta RPM rebuilding newPkg out of oldPkg, according
+// to the prestodelta (or deltainfo) metadata of the repository.
+// FindDelta returns nil if the repository provides no such delta RPM.
+// The delta metadata is only loaded (and downloaded if needed) on first use.
+func (repo *Repository) FindDelta(oldPkg, newPkg *Package) (*DeltaRPM, error) {
+	if oldPkg.Name() != newPkg.Name() || oldPkg.Arch() != newPkg.Arch() {
+		return nil, nil
+	}
+
+	deltas, err := repo.deltaRPMs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delta := range deltas[newPkg.Name()+"."+newPkg.Arch()] {
+		if RpmEvrCompare(
+			delta.Epoch, delta.Version, delta.Release,
+			newPkg.Epoch(), newPkg.Version(), newPkg.Release(),
+		) != 0 {
+			continue
+		}
+		if RpmEvrCompare(
+			delta.OldEpoch, delta.OldVersion, delta.OldRelease,
+			oldPkg.Epoch(), oldPkg.Version(), oldPkg.Release(),
+		) != 0 {
+			continue
+		}
+		return delta, nil
+	}
+	return nil, nil
+}
+
+// deltaRPMs returns the delta RPMs of the repository, by name.arch of the
+// packages they rebuild.
+func (repo *Repository) deltaRPMs() (map[string][]*DeltaRPM, error) {
+	if repo.deltas != nil {
+		return repo.deltas, nil
+	}
+
+	var err error
+	for _, dtype := range deltaDataTypes {
+		var fname string
+		fname, err = repo.metadataFile(dtype)
+		if errors.Is(err, ErrMetadataNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not retrieve delta RPMs of repository [%s]: %w", repo.Name, err)
+		}
+
+		deltas, err := loadDeltas(fname)
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not load delta RPMs [%s]: %w", fname, err)
+		}
+		repo.deltas = deltas
+		return repo.deltas, nil
+	}
+	return nil, err
+}
+
+// loadDeltas parses the prestodelta XML file fname and returns the delta
+// RPMs it describes, by name.arch of the packages they rebuild.
+func loadDeltas(fname string) (map[string][]*DeltaRPM, error) {
+	type xmlNewPackage struct {
+		Name    string `xml:"name,attr"`
+		Arch    string `xml:"arch,attr"`
+		Epoch   string `xml:"epoch,attr"`
+		Version string `xml:"version,attr"`
+		Release string `xml:"release,attr"`
+		Deltas  []struct {
+			OldEpoch   string `xml:"oldepoch,attr"`
+			OldVersion string `xml:"oldversion,attr"`
+			OldRelease string `xml:"oldrelease,attr"`
+			Filename   string `xml:"filename"`
+			Sequence   string `xml:"sequence"`
+			Size       int64  `xml:"size"`
+			Checksum   struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"checksum"`
+		} `xml:"delta"`
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	deltas := make(map[string][]*DeltaRPM)
+
+	// decode the metadata one package at a time.
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "newpackage" {
+			continue
+		}
+
+		var xpkg xmlNewPackage
+		err = dec.DecodeElement(&xpkg, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		key := xpkg.Name + "." + xpkg.Arch
+		for _, d := range xpkg.Deltas {
+			deltas[key] = append(deltas[key], &DeltaRPM{
+				Name:         xpkg.Name,
+				Arch:         xpkg.Arch,
+				Epoch:        xpkg.Epoch,
+				Version:      xpkg.Version,
+				Release:      xpkg.Release,
+				OldEpoch:     d.OldEpoch,
+				OldVersion:   d.OldVersion,
+				OldRelease:   d.OldRelease,
+				Location:     strings.TrimSpace(d.Filename),
+				Sequence:     strings.TrimSpace(d.Sequence),
+				Size:         d.Size,
+				Checksum:     strings.TrimSpace(d.Checksum.Value),
+				ChecksumType: d.Checksum.Type,
+			})
+		}
+	}
+
+	return deltas, nil
+}
@@ -0,0 +1,91 @@
+package yum
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepositoryFindDelta(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	err = copyTestFile(filepath.Join(remote, "repodata", "prestodelta.xml"), "testdata/prestodelta.xml")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	repo.RepoUrl = "file://" + remote
+
+	sum, err := checksumFile("testdata/prestodelta.xml", "sha256")
+	if err != nil {
+		t.Fatalf("could not compute checksum: %v\n", err)
+	}
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(fmt.Sprintf(`<repomd>
+  <data type="prestodelta">
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/prestodelta.xml"/>
+  </data>
+</repomd>`, sum)), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	find := func(name, version, release string) *Package {
+		pkg, err := repo.FindLatestMatchingName(name, version, release)
+		if err != nil || pkg == nil {
+			t.Fatalf("could not find package %s-%s-%s: %v\n", name, version, release, err)
+		}
+		return pkg
+	}
+
+	delta, err := repo.FindDelta(find("foo", "1.5", "1"), find("foo", "2.0", "1"))
+	if err != nil {
+		t.Fatalf("could not find delta: %v\n", err)
+	}
+	if delta == nil {
+		t.Fatalf("expected a delta RPM\n")
+	}
+	want := DeltaRPM{
+		Name: "foo", Arch: "x86_64", Epoch: "0", Version: "2.0", Release: "1",
+		OldEpoch: "0", OldVersion: "1.5", OldRelease: "1",
+		Location:     "drpms/foo-1.5-1_2.0-1.x86_64.drpm",
+		Sequence:     "foo-1.5-1-0123456789abcdef",
+		Size:         256,
+		Checksum:     "3f8b2a6c1d0e4f5a6b7c8d9e0f1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c",
+		ChecksumType: "sha256",
+	}
+	if *delta != want {
+		t.Fatalf("invalid delta RPM.\ngot= %#v\nwant=%#v\n", *delta, want)
+	}
+
+	// no delta between different architectures, nor from another version.
+	for _, table := range []struct {
+		old, new *Package
+	}{
+		{find("foo", "1.5", "2"), find("foo", "2.0", "1")},
+		{find("foo", "2.0", "1"), find("foo", "1.5", "1")},
+		{find("bar", "1.0", "1"), find("foo", "2.0", "1")},
+	} {
+		delta, err := repo.FindDelta(table.old, table.new)
+		if err != nil {
+			t.Fatalf("could not look for delta %s -> %s: %v\n", table.old.ID(), table.new.ID(), err)
+		}
+		if delta != nil {
+			t.Fatalf("expected no delta %s -> %s. got=%#v\n", table.old.ID(), table.new.ID(), delta)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package yum
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RepoDiff describes the differences between two repositories (or two
+// snapshots of one repository), as computed by DiffRepositories.
+// Packages are compared by name and architecture, considering only the
+// latest version of each of them.
+type RepoDiff struct {
+	Added   []*Package      // packages only in the new repository
+	Removed []*Package      // packages only in the old repository
+	Changed []PackageChange // packages whose latest version differs
+}
+
+// PackageChange is a package whose latest version differs between two
+// repositories.
+type PackageChange struct {
+	Old *Package
+	New *Package
+}
+
+// Upgrade returns whether the new version of the package is newer than the
+// old one.
+func (c PackageChange) Upgrade() bool {
+	return RpmEvrCompare(
+		c.New.Epoch(), c.New.Version(), c.New.Release(),
+		c.Old.Epoch(), c.Old.Version(), c.Old.Release(),
+	) > 0
+}
+
+// DiffRepositories returns the packages added, removed and changed in the
+// repository b, compared to the repository a.
+// All the lists of the returned RepoDiff are sorted by name and architecture.
+func DiffRepositories(a, b *Repository) (*RepoDiff, error) {
+	old, err := latestPackages(a)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := latestPackages(b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RepoDiff{}
+	i, j := 0, 0
+	for i < len(old) || j < len(cur) {
+		switch {
+		case j >= len(cur) || (i < len(old) && lessNameArch(old[i], cur[j])):
+			diff.Removed = append(diff.Removed, old[i])
+			i++
+		case i >= len(old) || lessNameArch(cur[j], old[i]):
+			diff.Added = append(diff.Added, cur[j])
+			j++
+		default:
+			if RpmEvrCompare(
+				old[i].Epoch(), old[i].Version(), old[i].Release(),
+				cur[j].Epoch(), cur[j].Version(), cur[j].Release(),
+			) != 0 {
+				diff.Changed = append(diff.Changed, PackageChange{Old: old[i], New: cur[j]})
+			}
+			i++
+			j++
+		}
+	}
+	return diff, nil
+}
+
+// latestPackages returns the latest version of each package of repo, by
+// name and architecture, sorted by name and architecture.
+func latestPackages(repo *Repository) ([]*Package, error) {
+	if repo.Backend == nil {
+		return nil, fmt.Errorf("%w: repository [%s] is not set up", ErrNoBackend, repo.Name)
+	}
+
+	latest := make(map[string]*Package)
+	err := repo.WalkPackages(func(pkg *Package) error {
+		key := pkg.Name() + "." + pkg.Arch()
+		if cur, ok := latest[key]; ok && RpmEvrCompare(
+			pkg.Epoch(), pkg.Version(), pkg.Release(),
+			cur.Epoch(), cur.Version(), cur.Release(),
+		) <= 0 {
+			return nil
+		}
+		latest[key] = pkg
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]*Package, 0, len(latest))
+	for _, pkg := range latest {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return lessNameArch(pkgs[i], pkgs[j])
+	})
+	return pkgs, nil
+}
+
+// lessNameArch orders packages by name, then architecture.
+func lessNameArch(a, b *Package) bool {
+	if a.Name() != b.Name() {
+		return a.Name() < b.Name()
+	}
+	return a.Arch() < b.Arch()
+}
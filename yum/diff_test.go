@@ -0,0 +1,87 @@
+package yum
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffRepositories(t *testing.T) {
+	mkrepo := func(ids ...[3]string) *Repository {
+		pkgs := make([]*Package, 0, len(ids))
+		for _, id := range ids {
+			pkg := NewPackage(id[0], id[1], "1", "0")
+			pkg.SetArch(id[2])
+			pkgs = append(pkgs, pkg)
+		}
+		repo, err := NewRepository("memory", "http://dummy-url.org", "testdata/cachedir.tmp",
+			nil, false, false,
+		)
+		if err != nil {
+			t.Fatalf("could not create repository: %v\n", err)
+		}
+		repo.Backend = NewMemoryBackend(pkgs)
+		return repo
+	}
+
+	old := mkrepo(
+		[3]string{"app", "1.0", "noarch"},
+		[3]string{"gone", "1.0", "noarch"},
+		[3]string{"lib", "1.0", "i686"},
+		[3]string{"lib", "1.0", "x86_64"},
+		[3]string{"zlib", "1.1", "x86_64"},
+		[3]string{"zlib", "1.2", "x86_64"},
+	)
+	defer old.Close()
+	cur := mkrepo(
+		[3]string{"app", "1.0", "noarch"},
+		[3]string{"lib", "2.0", "x86_64"},
+		[3]string{"new", "1.0", "noarch"},
+		[3]string{"zlib", "1.1", "x86_64"},
+	)
+	defer cur.Close()
+
+	diff, err := DiffRepositories(old, cur)
+	if err != nil {
+		t.Fatalf("could not diff repositories: %v\n", err)
+	}
+
+	ids := func(pkgs []*Package) []string {
+		var ids []string
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.ID()+"."+pkg.Arch())
+		}
+		return ids
+	}
+	if got, want := ids(diff.Added), []string{"new-1.0-1.noarch"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid added packages. got=%v. want=%v\n", got, want)
+	}
+	if got, want := ids(diff.Removed), []string{"gone-1.0-1.noarch", "lib-1.0-1.i686"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid removed packages. got=%v. want=%v\n", got, want)
+	}
+
+	var changed []string
+	for _, c := range diff.Changed {
+		changed = append(changed, c.Old.ID()+" -> "+c.New.ID())
+	}
+	if want := []string{"lib-1.0-1 -> lib-2.0-1", "zlib-1.2-1 -> zlib-1.1-1"}; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("invalid changed packages. got=%v. want=%v\n", changed, want)
+	}
+	if !diff.Changed[0].Upgrade() || diff.Changed[1].Upgrade() {
+		t.Fatalf("expected an upgrade of lib and a downgrade of zlib\n")
+	}
+
+	diff, err = DiffRepositories(cur, cur)
+	if err != nil {
+		t.Fatalf("could not diff repositories: %v\n", err)
+	}
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 {
+		t.Fatalf("expected no difference. got=%#v\n", diff)
+	}
+
+	cur.Backend = nil
+	_, err = DiffRepositories(old, cur)
+	if !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("expected ErrNoBackend. got=%v\n", err)
+	}
+}
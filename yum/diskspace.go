@@ -0,0 +1,55 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CheckDiskSpace checks there is enough space available on the filesystem
+// holding path to install the packages of the plan.
+// If path does not exist yet, its closest existing parent is checked.
+// CheckDiskSpace returns an error wrapping ErrNoSpace if the total install
+// size of the plan exceeds the available space.
+func (plan *InstallPlan) CheckDiskSpace(path string) error {
+	dir, err := existingParent(path)
+	if err != nil {
+		return fmt.Errorf("yum: could not check disk space in [%s]: %w", path, err)
+	}
+
+	avail, err := availableSpace(dir)
+	if err != nil {
+		return fmt.Errorf("yum: could not check disk space in [%s]: %w", path, err)
+	}
+
+	if plan.TotalInstallSize > avail {
+		return fmt.Errorf(
+			"%w in [%s]: %d bytes needed, %d bytes available",
+			ErrNoSpace, path, plan.TotalInstallSize, avail,
+		)
+	}
+	return nil
+}
+
+// existingParent returns path, or its closest parent directory which exists.
+func existingParent(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package yum
+
+import (
+	"errors"
+)
+
+// availableSpace is not implemented on this platform.
+func availableSpace(path string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,225 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DownloadPackage downloads the RPM file of pkg into dest, trying each mirror
+// of the repository in turn.
+// If dest is an existing directory, the RPM file is created under dest with
+// the name of the RPM file in the repository.
+// The RPM file is first retrieved into dest+".part": interrupted downloads
+// are resumed from that partial file by the next call to DownloadPackage.
+// The RPM file is verified against the checksum recorded in the repository
+// metadata: on mismatch, nothing is written and DownloadPackage returns an
+// error wrapping ErrChecksumMismatch.
+// If repo.PackageCacheDir is set, a verified copy of the RPM file from the
+// package cache is used instead of downloading it, and downloaded RPM files
+// are kept in the package cache.
+func (repo *Repository) DownloadPackage(pkg *Package, dest string) error {
+	if pkg.Location() == "" {
+		return fmt.Errorf("yum: no location for package %s", pkg.ID())
+	}
+	if pkg.Checksum() == "" {
+		return fmt.Errorf("yum: no checksum for package %s", pkg.ID())
+	}
+
+	if fi, err := os.Stat(dest); err == nil && fi.IsDir() {
+		dest = filepath.Join(dest, path.Base(pkg.Location()))
+	}
+
+	if repo.fromPackageCache(pkg, dest) {
+		return nil
+	}
+
+	var err error
+	for _, mirror := range repo.mirrors() {
+		var url string
+		url, err = joinURL(mirror, pkg.Location())
+		if err == nil {
+			err = repo.downloadPackageFrom(pkg, url, dest)
+		}
+		if err != nil {
+			if repo.context().Err() != nil {
+				return repo.context().Err()
+			}
+			repo.msg.Warnf("could not download package %s from mirror [%s]: %v\n", pkg.ID(), redact(mirror), err)
+			continue
+		}
+		repo.toPackageCache(pkg, dest)
+		return nil
+	}
+	return err
+}
+
+func (repo *Repository) downloadPackageFrom(pkg *Package, url, dest string) error {
+	fname := dest + ".part"
+	err := repo.downloadResume(repo.context(), url, fname, repo.packageProgress(pkg))
+	if err != nil {
+		return err
+	}
+
+	err = VerifyFile(fname, pkg.ChecksumType(), pkg.Checksum())
+	if err != nil {
+		os.RemoveAll(fname)
+		return fmt.Errorf("yum: could not verify [%s]: %w", redact(url), err)
+	}
+
+	err = os.Rename(fname, dest)
+	if err != nil {
+		os.RemoveAll(fname)
+		return err
+	}
+	return nil
+}
+
+// DownloadPackages downloads the RPM files of pkgs into the directory dest,
+// like DownloadPackage, running at most concurrency downloads at once.
+// A concurrency lower than 1 downloads the packages one at a time.
+// DownloadPackages tries to download all the packages, and returns the
+// errors of the failed downloads joined together.
+func (repo *Repository) DownloadPackages(pkgs []*Package, dest string, concurrency int) error {
+	err := os.MkdirAll(dest, 0755)
+	if err != nil {
+		return err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// set up the HTTP client once, before the downloads share it.
+	repo.httpClient()
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		errs = make([]error, len(pkgs))
+		seen = make(map[string]bool, len(pkgs))
+	)
+	for i, pkg := range pkgs {
+		// do not download the same file concurrently.
+		fname := path.Base(pkg.Location())
+		if pkg.Location() != "" && seen[fname] {
+			continue
+		}
+		seen[fname] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pkg *Package) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := repo.DownloadPackage(pkg, dest)
+			if err != nil {
+				errs[i] = fmt.Errorf("yum: could not download package %s: %w", pkg.ID(), err)
+			}
+		}(i, pkg)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// packageProgress returns the function reporting the progress of the
+// download of pkg, if any.
+func (repo *Repository) packageProgress(pkg *Package) ProgressFunc {
+	if repo.PackageProgress != nil {
+		return func(bytesRead, total int64) {
+			repo.PackageProgress(pkg, bytesRead, total)
+		}
+	}
+	return repo.Progress
+}
+
+// packageCacheFile returns the path to the RPM file of pkg in the package
+// cache, or an empty string if the repository has no package cache.
+func (repo *Repository) packageCacheFile(pkg *Package) string {
+	if repo.PackageCacheDir == "" {
+		return ""
+	}
+	return filepath.Join(repo.PackageCacheDir, path.Base(pkg.Location()))
+}
+
+// fromPackageCache copies the RPM file of pkg from the package cache to dest.
+// It returns whether the package cache held a valid RPM file for pkg.
+// Invalid RPM files are removed from the package cache.
+func (repo *Repository) fromPackageCache(pkg *Package, dest string) bool {
+	fname := repo.packageCacheFile(pkg)
+	if fname == "" || !path_exists(fname) {
+		return false
+	}
+
+	err := VerifyFile(fname, pkg.ChecksumType(), pkg.Checksum())
+	if err != nil {
+		repo.msg.Warnf("removing invalid package [%s] from package cache: %v\n", fname, err)
+		os.RemoveAll(fname)
+		return false
+	}
+
+	if same_file(fname, dest) {
+		return true
+	}
+	err = copy_file(dest, fname)
+	if err != nil {
+		repo.msg.Warnf("could not copy package %s from package cache: %v\n", pkg.ID(), err)
+		return false
+	}
+	repo.msg.Debugf("package %s retrieved from package cache\n", pkg.ID())
+	return true
+}
+
+// toPackageCache keeps a copy of the RPM file dest of pkg in the package
+// cache. Failing to do so is not an error.
+func (repo *Repository) toPackageCache(pkg *Package, dest string) {
+	fname := repo.packageCacheFile(pkg)
+	if fname == "" || same_file(fname, dest) {
+		return
+	}
+
+	err := os.MkdirAll(repo.PackageCacheDir, 0755)
+	if err == nil {
+		err = copy_file(fname, dest)
+	}
+	if err != nil {
+		repo.msg.Warnf("could not add package %s to package cache: %v\n", pkg.ID(), err)
+	}
+}
+
+// CleanPackageCache removes the RPM files kept in the package cache of the
+// repository (see PackageCacheDir), as well as partial downloads.
+func (repo *Repository) CleanPackageCache() error {
+	if repo.PackageCacheDir == "" {
+		return nil
+	}
+
+	fis, err := ioutil.ReadDir(repo.PackageCacheDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("yum: could not clean package cache [%s]: %w", repo.PackageCacheDir, err)
+	}
+
+	var errs []error
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !(strings.HasSuffix(name, ".rpm") || strings.HasSuffix(name, ".rpm.part")) {
+			continue
+		}
+		err = os.Remove(filepath.Join(repo.PackageCacheDir, name))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("yum: could not clean package cache [%s]: %w", repo.PackageCacheDir, errors.Join(errs...))
+	}
+	return nil
+}
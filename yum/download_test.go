@@ -0,0 +1,347 @@
+package yum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRepositoryDownloadPackage(t *testing.T) {
+	const content = "not really an RPM file"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/foo-2.0-1.x86_64.rpm" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	repo.RepoUrl = srv.URL
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if pkg.ChecksumType() != "sha256" || pkg.Checksum() == "" {
+		t.Fatalf("invalid package checksum: type=%q sum=%q\n", pkg.ChecksumType(), pkg.Checksum())
+	}
+
+	dir, err := ioutil.TempDir("", "lbpkr-test-download-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the checksum recorded in the test metadata does not match content.
+	err = repo.DownloadPackage(pkg, dir)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("could not read tmpdir: %v\n", err)
+	}
+	if len(fis) != 0 {
+		t.Fatalf("expected no file left after a checksum mismatch. got=%d\n", len(fis))
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	pkg.checksum = hex.EncodeToString(sum[:])
+
+	err = repo.DownloadPackage(pkg, dir)
+	if err != nil {
+		t.Fatalf("could not download package: %v\n", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "foo-2.0-1.x86_64.rpm"))
+	if err != nil {
+		t.Fatalf("could not read downloaded package: %v\n", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected %q. got=%q\n", content, string(data))
+	}
+
+	fname := filepath.Join(dir, "foo.rpm")
+	err = repo.DownloadPackage(pkg, fname)
+	if err != nil {
+		t.Fatalf("could not download package to [%s]: %v\n", fname, err)
+	}
+	if _, err := os.Stat(fname); err != nil {
+		t.Fatalf("expected [%s] to be created: %v\n", fname, err)
+	}
+}
+
+func TestRepositoryDownloadPackageResume(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	content := strings.Repeat("0123456789", 100)
+	ranges := true
+	drop := 0 // number of bytes sent before dropping the connection, once
+	var hdr string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr = r.Header.Get("Range")
+		if !ranges {
+			r.Header.Del("Range")
+		}
+		if drop > 0 {
+			n := drop
+			drop = 0
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			io.WriteString(w, content[:n])
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("could not hijack connection: %v\n", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "foo.rpm", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	repo.RepoUrl = srv.URL
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	pkg.checksum = hex.EncodeToString(sum[:])
+
+	dir, err := ioutil.TempDir("", "lbpkr-test-download-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		name    string
+		partial string
+		ranges  bool
+		drop    int
+		want    string
+	}{
+		{
+			name:    "resume",
+			partial: content[:400],
+			ranges:  true,
+			want:    "bytes=400-",
+		},
+		{
+			name:    "no-range-support",
+			partial: "garbage",
+			ranges:  false,
+			want:    "bytes=7-",
+		},
+		{
+			// the content is retrieved from byte 0, then resumed.
+			name:    "no-range-support-dropped",
+			partial: "garbage",
+			ranges:  false,
+			drop:    500,
+			want:    "bytes=500-",
+		},
+		{
+			name:    "not-satisfiable",
+			partial: content + "garbage",
+			ranges:  true,
+			want:    "",
+		},
+		{
+			name:   "no-partial",
+			ranges: true,
+			want:   "",
+		},
+	} {
+		dest := filepath.Join(dir, test.name+".rpm")
+		if test.partial != "" {
+			err = ioutil.WriteFile(dest+".part", []byte(test.partial), 0644)
+			if err != nil {
+				t.Fatalf("%s: could not create partial file: %v\n", test.name, err)
+			}
+		}
+
+		ranges = test.ranges
+		drop = test.drop
+		err = repo.DownloadPackage(pkg, dest)
+		if err != nil {
+			t.Fatalf("%s: could not download package: %v\n", test.name, err)
+		}
+		if hdr != test.want {
+			t.Fatalf("%s: invalid last Range header. got=%q. want=%q\n", test.name, hdr, test.want)
+		}
+
+		data, err := ioutil.ReadFile(dest)
+		if err != nil {
+			t.Fatalf("%s: could not read downloaded package: %v\n", test.name, err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s: invalid content (len=%d)\n", test.name, len(data))
+		}
+		if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected partial file to be removed: %v\n", test.name, err)
+		}
+	}
+}
+
+func TestRepositoryPackageCache(t *testing.T) {
+	const content = "not really an RPM file"
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	repo.RepoUrl = srv.URL
+
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-pkgcache-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	repo.PackageCacheDir = filepath.Join(tmpdir, "packages")
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	pkg.checksum = hex.EncodeToString(sum[:])
+
+	cached := filepath.Join(repo.PackageCacheDir, "foo-2.0-1.x86_64.rpm")
+	for i, want := range []int{1, 1, 2} {
+		dest := filepath.Join(tmpdir, fmt.Sprintf("foo-%d.rpm", i))
+		err = repo.DownloadPackage(pkg, dest)
+		if err != nil {
+			t.Fatalf("download #%d: could not download package: %v\n", i, err)
+		}
+		if hits != want {
+			t.Fatalf("download #%d: invalid number of requests. got=%d. want=%d\n", i, hits, want)
+		}
+		data, err := ioutil.ReadFile(dest)
+		if err != nil || string(data) != content {
+			t.Fatalf("download #%d: invalid package (err=%v): %q\n", i, err, string(data))
+		}
+		if !path_exists(cached) {
+			t.Fatalf("download #%d: expected package in package cache\n", i)
+		}
+		if i == 1 {
+			// corrupt the package cache: the package is downloaded again.
+			err = ioutil.WriteFile(cached, []byte("corrupted"), 0644)
+			if err != nil {
+				t.Fatalf("could not corrupt package cache: %v\n", err)
+			}
+		}
+	}
+
+	err = repo.CleanPackageCache()
+	if err != nil {
+		t.Fatalf("could not clean package cache: %v\n", err)
+	}
+	if path_exists(cached) {
+		t.Fatalf("expected package cache to be cleaned\n")
+	}
+}
+
+func TestRepositoryDownloadPackages(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		maxrun  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxrun {
+			maxrun = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, "content of "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	repo.RepoUrl = srv.URL
+
+	progress := make(map[string]int64)
+	repo.PackageProgress = func(pkg *Package, n, total int64) {
+		mu.Lock()
+		progress[pkg.Name()] = n
+		mu.Unlock()
+	}
+
+	var pkgs []*Package
+	for _, name := range []string{"foo", "bar", "baz", "qux"} {
+		pkg, err := repo.FindLatestMatchingName(name, "", "")
+		if err != nil {
+			t.Fatalf("could not find package %q: %v\n", name, err)
+		}
+		if name != "qux" {
+			// leave the checksum of qux mismatched.
+			sum := sha256.Sum256([]byte("content of /" + pkg.Location()))
+			pkg.checksum = hex.EncodeToString(sum[:])
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	dir, err := ioutil.TempDir("", "lbpkr-test-download-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dest := filepath.Join(dir, "rpms")
+	err = repo.DownloadPackages(pkgs, dest, 2)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+	if !strings.Contains(err.Error(), "qux") || strings.Contains(err.Error(), "foo") {
+		t.Fatalf("expected only the download of qux to fail. got=%v\n", err)
+	}
+	if maxrun > 2 {
+		t.Fatalf("expected at most 2 concurrent downloads. got=%d\n", maxrun)
+	}
+
+	for _, pkg := range pkgs[:3] {
+		want := "content of /" + pkg.Location()
+		data, err := ioutil.ReadFile(filepath.Join(dest, pkg.Location()))
+		if err != nil || string(data) != want {
+			t.Fatalf("invalid package %s (err=%v): %q\n", pkg.ID(), err, string(data))
+		}
+		if progress[pkg.Name()] != int64(len(want)) {
+			t.Fatalf("invalid progress for %s. got=%d. want=%d\n", pkg.ID(), progress[pkg.Name()], len(want))
+		}
+	}
+	if path_exists(filepath.Join(dest, "qux-1.0-1.noarch.rpm")) {
+		t.Fatalf("expected no file for qux\n")
+	}
+}
@@ -0,0 +1,39 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrOffline is returned when an offline repository needs to access the network.
+	ErrOffline = errors.New("yum: repository is offline")
+
+	// ErrNoBackend is returned when none of the backends of a repository could be set up.
+	ErrNoBackend = errors.New("yum: no valid backend found")
+
+	// ErrChecksumMismatch is returned when a downloaded file does not match
+	// the checksum advertised in the repository metadata.
+	ErrChecksumMismatch = errors.New("yum: checksum mismatch")
+
+	// ErrMetadataNotFound is returned when the repository metadata (repomd.xml)
+	// could not be retrieved.
+	ErrMetadataNotFound = errors.New("yum: repository metadata not found")
+
+	// ErrNoLocalCache is returned when a repository is set up from its cache
+	// directory but no repo metadata was ever cached there.
+	// It wraps ErrMetadataNotFound.
+	ErrNoLocalCache = fmt.Errorf("%w: no local cache present; run with update enabled", ErrMetadataNotFound)
+
+	// ErrSignature is returned when the signature of the repository metadata is
+	// invalid or could not be verified.
+	ErrSignature = errors.New("yum: invalid repository metadata signature")
+
+	// ErrUnsupportedSchema is returned when a SQLite DB is not a YUM DB
+	// or its schema version is not supported.
+	ErrUnsupportedSchema = errors.New("yum: unsupported SQLite DB schema")
+
+	// ErrNoSpace is returned when there is not enough disk space to install
+	// the packages of an InstallPlan.
+	ErrNoSpace = errors.New("yum: not enough disk space")
+)
@@ -0,0 +1,77 @@
+package yum
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventKind identifies the step of the lifecycle of a backend an Event
+// reports.
+type EventKind int
+
+const (
+	EventProbeStart    EventKind = iota // a backend starts being probed
+	EventDownloadStart                  // the download of a DB starts
+	EventDownloadDone                   // a DB was downloaded (Bytes is set)
+	EventLoadDone                       // a backend loaded its DB (Packages is set)
+	EventError                          // a backend could not be set up (Err is set)
+)
+
+func (kind EventKind) String() string {
+	switch kind {
+	case EventProbeStart:
+		return "probe-start"
+	case EventDownloadStart:
+		return "download-start"
+	case EventDownloadDone:
+		return "download-done"
+	case EventLoadDone:
+		return "load-done"
+	case EventError:
+		return "error"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(kind))
+}
+
+// Event describes a step of the lifecycle of the backends of a repository.
+type Event struct {
+	Kind       EventKind
+	Repository string // name of the repository
+	Backend    string // name of the backend, empty for downloads
+	URL        string // URL of the downloaded DB, for downloads
+	Bytes      int64  // number of bytes downloaded
+	Packages   int64  // number of packages loaded
+	Err        error
+}
+
+// emit reports the event ev to repo.OnEvent, if any.
+func (repo *Repository) emit(ev Event) {
+	if repo.OnEvent == nil {
+		return
+	}
+	ev.Repository = repo.Name
+	repo.OnEvent(ev)
+}
+
+// emitLoaded reports that the backend bname loaded its DB.
+func (repo *Repository) emitLoaded(bname string) {
+	if repo.OnEvent == nil {
+		// don't count packages for nothing.
+		return
+	}
+	repo.emit(Event{Kind: EventLoadDone, Backend: bname, Packages: repo.Stats().Packages})
+}
+
+// backendName returns the name of the type of the backend ba
+// (e.g. RepositoryXMLBackend).
+func backendName(ba Backend) string {
+	name := fmt.Sprintf("%T", ba)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// backendError reports that the backend bname could not be set up because
+// of err, and returns err annotated with the name of the backend.
+func (repo *Repository) backendError(bname string, err error) error {
+	repo.emit(Event{Kind: EventError, Backend: bname, Err: err})
+	return fmt.Errorf("backend [%s]: %w", bname, err)
+}
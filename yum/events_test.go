@@ -0,0 +1,72 @@
+package yum
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRepositoryEvents(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"nosuchbackend", "RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	var mu sync.Mutex
+	events := make(map[EventKind][]Event)
+	repo.OnEvent = func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events[ev.Kind] = append(events[ev.Kind], ev)
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not set up backend: %v\n", err)
+	}
+
+	for kind, n := range map[EventKind]int{
+		EventProbeStart:    2,
+		EventDownloadStart: 1,
+		EventDownloadDone:  1,
+		EventLoadDone:      1,
+		EventError:         1,
+	} {
+		if len(events[kind]) != n {
+			t.Fatalf("expected %d %v events. got=%v\n", n, kind, events[kind])
+		}
+		for _, ev := range events[kind] {
+			if ev.Repository != "lcg" {
+				t.Fatalf("%v: expected repository lcg. got=%q\n", kind, ev.Repository)
+			}
+		}
+	}
+
+	if ev := events[EventDownloadDone][0]; ev.Bytes <= 0 || ev.URL == "" {
+		t.Fatalf("expected the size and URL of the downloaded DB. got=%+v\n", ev)
+	}
+	if ev := events[EventLoadDone][0]; ev.Backend != "RepositoryXMLBackend" || ev.Packages <= 0 {
+		t.Fatalf("expected the number of packages loaded by the XML backend. got=%+v\n", ev)
+	}
+	if ev := events[EventError][0]; ev.Backend != "nosuchbackend" || ev.Err == nil {
+		t.Fatalf("expected an error for backend nosuchbackend. got=%+v\n", ev)
+	}
+	if s := EventLoadDone.String(); s != "load-done" {
+		t.Fatalf("expected load-done. got=%q\n", s)
+	}
+}
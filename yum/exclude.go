@@ -0,0 +1,112 @@
+package yum
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Exclude makes the repository ignore the packages matching one of the shell
+// patterns (e.g. "kernel*" or "foo-1.5-*"), as the exclude= option of yum:
+// excluded packages are never returned by the finders of the repository and
+// are thus never considered during dependency resolution.
+// A pattern matches a package if it matches its name, name.arch,
+// name-version, name-version-release, NVRA or NEVRA.
+// The pattern syntax is the one of path.Match.
+func (repo *Repository) Exclude(patterns ...string) error {
+	for _, pattern := range patterns {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("yum: invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	repo.excludes = append(repo.excludes, patterns...)
+	return nil
+}
+
+// Excludes returns the exclude patterns of the repository, sorted.
+func (repo *Repository) Excludes() []string {
+	excludes := append([]string(nil), repo.excludes...)
+	sort.Strings(excludes)
+	return excludes
+}
+
+// excluded returns whether pkg matches one of the exclude patterns.
+func (repo *Repository) excluded(pkg *Package) bool {
+	if len(repo.excludes) == 0 || pkg == nil {
+		return false
+	}
+	names := []string{
+		pkg.Name(),
+		pkg.Name() + "." + pkg.Arch(),
+		pkg.Name() + "-" + pkg.Version(),
+		pkg.ID(),
+		pkg.NVRA(),
+		pkg.NEVRA(),
+	}
+	for _, pattern := range repo.excludes {
+		for _, name := range names {
+			// patterns were validated by Exclude.
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutExcluded returns the packages of pkgs not excluded, keeping their
+// order. pkgs is returned as is if the repository excludes nothing.
+func (repo *Repository) withoutExcluded(pkgs []*Package) []*Package {
+	if len(repo.excludes) == 0 {
+		return pkgs
+	}
+	out := make([]*Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if !repo.excluded(pkg) {
+			out = append(out, pkg)
+		}
+	}
+	return out
+}
+
+// latestIncluded returns the latest package named name selected by match and
+// not excluded.
+func (repo *Repository) latestIncluded(name string, match func(p *Package) bool) (*Package, error) {
+	pkgs, err := repo.FindAllMatchingName(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		if match(pkg) {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("yum: package %q is excluded", name)
+}
+
+// latestIncludedProvider returns the latest package satisfying requirement
+// and not excluded.
+func (repo *Repository) latestIncludedProvider(requirement *Requires) (*Package, error) {
+	pkgs, err := repo.FindAllMatchingRequire(requirement.Name())
+	if err != nil {
+		return nil, err
+	}
+	var latest *Package
+	match := archMatchingRequire(requirement)
+	for _, pkg := range pkgs {
+		if !match(pkg) {
+			continue
+		}
+		if latest == nil || RpmEvrCompare(
+			pkg.Epoch(), pkg.Version(), pkg.Release(),
+			latest.Epoch(), latest.Version(), latest.Release(),
+		) > 0 {
+			latest = pkg
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("yum: all the packages providing %s are excluded", requirement.ID())
+	}
+	return latest, nil
+}
@@ -0,0 +1,81 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepositoryExclude(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	err := repo.Exclude("foo-2.0-*", "ba?")
+	if err != nil {
+		t.Fatalf("could not exclude packages: %v\n", err)
+	}
+	if got, want := repo.Excludes(), []string{"ba?", "foo-2.0-*"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid excludes.\ngot= %v\nwant=%v\n", got, want)
+	}
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if pkg.ID() != "foo-1.5-2" {
+		t.Fatalf("expected foo-1.5-2. got=%s\n", pkg.ID())
+	}
+
+	pkg, err = repo.FindLatestMatchingRequire(NewRequires("foo", "", "", "", "", ""))
+	if err != nil {
+		t.Fatalf("could not find provider: %v\n", err)
+	}
+	if pkg.ID() != "foo-1.5-2" {
+		t.Fatalf("expected provider foo-1.5-2. got=%s\n", pkg.ID())
+	}
+
+	// only foo-2.0 provides libfoo.so
+	_, err = repo.FindLatestMatchingRequire(NewRequires("libfoo.so", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error for a requirement only provided by an excluded package\n")
+	}
+
+	pkgs, err := repo.FindAllMatchingName("foo")
+	if err != nil {
+		t.Fatalf("could not find packages: %v\n", err)
+	}
+	ids := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		ids = append(ids, pkg.ID())
+	}
+	if want := []string{"foo-1.5-2", "foo-1.5-1"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("invalid packages.\ngot= %v\nwant=%v\n", ids, want)
+	}
+
+	for _, name := range []string{"bar", "baz"} {
+		_, err = repo.FindLatestMatchingName(name, "", "")
+		if err == nil {
+			t.Fatalf("expected an error for the excluded package %q\n", name)
+		}
+	}
+
+	for _, pkg := range repo.GetPackages() {
+		if pkg.Name() == "bar" || pkg.Name() == "baz" || pkg.ID() == "foo-2.0-1" {
+			t.Fatalf("GetPackages returned the excluded package %s\n", pkg.ID())
+		}
+	}
+
+	pkgs, err = repo.ListPackages("*")
+	if err != nil {
+		t.Fatalf("could not list packages: %v\n", err)
+	}
+	for _, pkg := range pkgs {
+		if pkg.Name() == "bar" || pkg.Name() == "baz" || pkg.ID() == "foo-2.0-1" {
+			t.Fatalf("ListPackages returned the excluded package %s\n", pkg.ID())
+		}
+	}
+
+	err = repo.Exclude("[")
+	if err == nil {
+		t.Fatalf("expected an error for an invalid pattern\n")
+	}
+}
@@ -0,0 +1,101 @@
+package yum
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportedPackage describes a package, as written by ExportJSON.
+type ExportedPackage struct {
+	Name         string               `json:"name"`
+	Epoch        string               `json:"epoch,omitempty"`
+	Version      string               `json:"version"`
+	Release      string               `json:"release"`
+	Arch         string               `json:"arch"`
+	Provides     []ExportedCapability `json:"provides"`
+	Requires     []ExportedCapability `json:"requires"`
+	Location     string               `json:"location"`
+	Checksum     string               `json:"checksum,omitempty"`
+	ChecksumType string               `json:"checksum_type,omitempty"`
+}
+
+// ExportedCapability describes a capability provided or required by an
+// ExportedPackage.
+type ExportedCapability struct {
+	Name    string `json:"name"`
+	Flags   string `json:"flags,omitempty"` // EQ, LT, LE, GT or GE
+	Epoch   string `json:"epoch,omitempty"`
+	Version string `json:"version,omitempty"`
+	Release string `json:"release,omitempty"`
+}
+
+// newExportedPackage returns the exported description of pkg.
+func newExportedPackage(pkg *Package) ExportedPackage {
+	capability := func(rpm RPM) ExportedCapability {
+		return ExportedCapability{
+			Name:    rpm.Name(),
+			Flags:   rpm.Flags(),
+			Epoch:   rpm.Epoch(),
+			Version: rpm.Version(),
+			Release: rpm.Release(),
+		}
+	}
+
+	epkg := ExportedPackage{
+		Name:         pkg.Name(),
+		Epoch:        pkg.Epoch(),
+		Version:      pkg.Version(),
+		Release:      pkg.Release(),
+		Arch:         pkg.Arch(),
+		Provides:     make([]ExportedCapability, 0, len(pkg.Provides())),
+		Requires:     make([]ExportedCapability, 0, len(pkg.Requires())),
+		Location:     pkg.Location(),
+		Checksum:     pkg.Checksum(),
+		ChecksumType: pkg.ChecksumType(),
+	}
+	for _, prov := range pkg.Provides() {
+		epkg.Provides = append(epkg.Provides, capability(prov))
+	}
+	for _, req := range pkg.Requires() {
+		epkg.Requires = append(epkg.Requires, capability(req))
+	}
+	return epkg
+}
+
+// ExportJSON writes all the packages of the repository to w, as a JSON array
+// of ExportedPackage, in no particular order.
+// Packages are written as they are walked (see WalkPackages), so the whole
+// list is never held in memory.
+func (repo *Repository) ExportJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	_, err := bw.WriteString("[")
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	err = repo.WalkPackages(func(pkg *Package) error {
+		buf, err := json.Marshal(newExportedPackage(pkg))
+		if err != nil {
+			return fmt.Errorf("yum: could not export package %s: %w", pkg.ID(), err)
+		}
+		if n > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+		_, err = bw.Write(buf)
+		n++
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
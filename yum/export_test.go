@@ -0,0 +1,64 @@
+package yum
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRepositoryExportJSON(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	buf := new(bytes.Buffer)
+	err := repo.ExportJSON(buf)
+	if err != nil {
+		t.Fatalf("could not export packages: %v\n", err)
+	}
+
+	var pkgs []ExportedPackage
+	err = json.Unmarshal(buf.Bytes(), &pkgs)
+	if err != nil {
+		t.Fatalf("could not decode exported packages: %v\n", err)
+	}
+	if len(pkgs) != len(repo.Backend.GetPackages()) {
+		t.Fatalf("expected %d packages. got=%d\n", len(repo.Backend.GetPackages()), len(pkgs))
+	}
+
+	var foo *ExportedPackage
+	for i := range pkgs {
+		if pkgs[i].Name == "foo" && pkgs[i].Version == "2.0" {
+			foo = &pkgs[i]
+		}
+	}
+	if foo == nil {
+		t.Fatalf("package foo-2.0-1 not exported\n")
+	}
+	if foo.Location != "foo-2.0-1.x86_64.rpm" || foo.Arch != "x86_64" {
+		t.Fatalf("unexpected location or arch: %+v\n", foo)
+	}
+	if foo.ChecksumType != "sha256" || foo.Checksum != "0a5b5bb1e36a1e1e1a2bd0b2e9b0f6b8a60fe1bb8c5b8b8e7c9f77e1f8a4b2d3" {
+		t.Fatalf("unexpected checksum: %+v\n", foo)
+	}
+	want := ExportedCapability{Name: "bar", Flags: "GE", Epoch: "0", Version: "1.0"}
+	if !reflect.DeepEqual(foo.Requires, []ExportedCapability{want}) {
+		t.Fatalf("expected requires %v. got=%v\n", want, foo.Requires)
+	}
+	if len(foo.Provides) != 2 || foo.Provides[1].Name != "libfoo.so" {
+		t.Fatalf("unexpected provides: %v\n", foo.Provides)
+	}
+
+	// an empty repository is exported as an empty array.
+	mem := newTestMemoryRepository(t)
+	defer mem.Close()
+	mem.Backend = NewMemoryBackend(nil)
+	buf.Reset()
+	err = mem.ExportJSON(buf)
+	if err != nil {
+		t.Fatalf("could not export packages: %v\n", err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Fatalf("expected an empty array. got=%q\n", got)
+	}
+}
@@ -0,0 +1,181 @@
+package yum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Fetcher retrieves the content located at a URL.
+// Fetchers are registered by URL scheme with RegisterFetcher.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawurl string) (io.ReadCloser, error)
+}
+
+// FetcherFunc is a function implementing Fetcher.
+type FetcherFunc func(ctx context.Context, rawurl string) (io.ReadCloser, error)
+
+// Fetch calls f(ctx, rawurl).
+func (f FetcherFunc) Fetch(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+	return f(ctx, rawurl)
+}
+
+// fetchers are the registered Fetchers, by URL scheme.
+// s3:// and gs:// URLs are retrieved with the AWS and Google Cloud CLIs,
+// which take their credentials from the environment.
+var fetchers = struct {
+	sync.RWMutex
+	m map[string]Fetcher
+}{m: map[string]Fetcher{
+	"http":  &httpFetcher{},
+	"https": &httpFetcher{},
+	"s3":    &CommandFetcher{Name: "aws", Args: []string{"s3", "cp", "--quiet", "{url}", "-"}},
+	"gs":    &CommandFetcher{Name: "gcloud", Args: []string{"storage", "cat", "{url}"}},
+}}
+
+// RegisterFetcher registers f to retrieve the content of the URLs with the
+// given scheme (e.g. "s3"), replacing the Fetcher previously registered for
+// that scheme, if any. A nil f unregisters the scheme.
+//
+// The default Fetchers retrieve http:// and https:// URLs with the HTTP
+// client of the repository, which supports conditional and resumed
+// downloads, and s3:// and gs:// URLs by running the aws and gcloud
+// commands, which must then be installed (and in $PATH) at run time.
+// file:// URLs are always read from disk. URLs whose scheme has no
+// registered Fetcher can not be retrieved.
+func RegisterFetcher(scheme string, f Fetcher) {
+	fetchers.Lock()
+	defer fetchers.Unlock()
+	if f == nil {
+		delete(fetchers.m, scheme)
+		return
+	}
+	fetchers.m[scheme] = f
+}
+
+// fetcher returns the Fetcher registered for scheme, if any.
+func fetcher(scheme string) Fetcher {
+	fetchers.RLock()
+	defer fetchers.RUnlock()
+	return fetchers.m[scheme]
+}
+
+// remoteFetcher is implemented by the Fetchers retrieving content with the
+// HTTP client of the repository, honoring the headers of conditional and
+// ranged requests.
+type remoteFetcher interface {
+	fetchRemote(ctx context.Context, client *http.Client, rawurl string, header http.Header) (*remoteData, error)
+}
+
+// httpFetcher is the Fetcher of http:// and https:// URLs.
+type httpFetcher struct{}
+
+// Fetch retrieves the content located at rawurl with http.DefaultClient.
+func (f *httpFetcher) Fetch(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+	r, err := f.fetchRemote(ctx, http.DefaultClient, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (f *httpFetcher) fetchRemote(ctx context.Context, client *http.Client, rawurl string, header http.Header) (*remoteData, error) {
+	return getHTTPData(ctx, client, rawurl, header)
+}
+
+// CommandFetcher is a Fetcher running an external command which writes the
+// content located at the URL to its standard output.
+type CommandFetcher struct {
+	Name string   // command to run (e.g. "aws")
+	Args []string // arguments of the command, where "{url}" is replaced with the URL
+}
+
+// Fetch runs the command to retrieve the content located at rawurl.
+// The command is killed when ctx is done or the returned reader is closed
+// before the end of the content.
+func (f *CommandFetcher) Fetch(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = strings.Replace(arg, "{url}", rawurl, -1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, f.Name, args...)
+	r := &cmdReader{cmd: cmd, cancel: cancel, url: redact(rawurl)}
+	cmd.Stderr = &r.stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("yum: could not run %s to retrieve [%s]: %w", f.Name, r.url, err)
+	}
+	r.stdout = stdout
+	return r, nil
+}
+
+// cmdReader reads the standard output of a command.
+// Reaching the end of the output reports the failure of the command, if any.
+type cmdReader struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	url    string
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (r *cmdReader) Read(data []byte) (int, error) {
+	if r.done {
+		return 0, r.eof()
+	}
+	n, err := r.stdout.Read(data)
+	if err == io.EOF {
+		r.wait()
+		return n, r.eof()
+	}
+	return n, err
+}
+
+// eof returns the error reported at the end of the output.
+func (r *cmdReader) eof() error {
+	if r.err != nil {
+		return r.err
+	}
+	return io.EOF
+}
+
+// wait waits for the command to exit.
+func (r *cmdReader) wait() {
+	if r.done {
+		return
+	}
+	r.done = true
+	err := r.cmd.Wait()
+	r.cancel()
+	if err != nil {
+		r.err = fmt.Errorf(
+			"yum: %s could not retrieve [%s]: %w: %s",
+			r.cmd.Args[0], r.url, err, strings.TrimSpace(r.stderr.String()),
+		)
+	}
+}
+
+func (r *cmdReader) Close() error {
+	if !r.done {
+		r.cancel()
+		r.wait()
+		return nil
+	}
+	return r.err
+}
@@ -0,0 +1,158 @@
+package yum
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryFetcher(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	var fetched []string
+	RegisterFetcher("test", FetcherFunc(func(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, u.Path)
+		return os.Open(filepath.Join(remote, u.Path))
+	}))
+	defer RegisterFetcher("test", nil)
+
+	repo, err := NewRepository(
+		"lcg", "test://bucket/", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	if len(repo.GetPackages()) <= 0 {
+		t.Fatalf("expected some packages\n")
+	}
+	if len(fetched) < 2 || fetched[0] != "/repodata/repomd.xml" {
+		t.Fatalf("expected the metadata and DB to be fetched. got=%v\n", fetched)
+	}
+
+	RegisterFetcher("test", nil)
+	if fetcher("test") != nil {
+		t.Fatalf("expected the fetcher to be unregistered\n")
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	const content = "<repomd></repomd>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	for _, scheme := range []string{"http", "https"} {
+		if _, ok := fetcher(scheme).(*httpFetcher); !ok {
+			t.Fatalf("expected %s:// URLs to be retrieved by the HTTP fetcher. got=%T\n", scheme, fetcher(scheme))
+		}
+	}
+
+	r, err := fetcher("http").Fetch(context.Background(), srv.URL+"/repodata/repomd.xml")
+	if err != nil {
+		t.Fatalf("could not fetch: %v\n", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("could not read: %v\n", err)
+	}
+	if string(got) != content {
+		t.Fatalf("invalid content.\ngot= %q\nwant=%q\n", got, content)
+	}
+
+	// http:// URLs are retrieved through the registry.
+	var fetched []string
+	RegisterFetcher("http", FetcherFunc(func(ctx context.Context, rawurl string) (io.ReadCloser, error) {
+		fetched = append(fetched, rawurl)
+		return ioutil.NopCloser(strings.NewReader("custom")), nil
+	}))
+	defer RegisterFetcher("http", &httpFetcher{})
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	r, err = repo.getRemoteData(srv.URL + "/data")
+	if err != nil {
+		t.Fatalf("could not retrieve remote data: %v\n", err)
+	}
+	got, err = ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("could not read: %v\n", err)
+	}
+	if string(got) != "custom" || len(fetched) != 1 {
+		t.Fatalf("expected the registered fetcher to be used. got=%q (fetched=%v)\n", got, fetched)
+	}
+
+	RegisterFetcher("http", nil)
+	_, err = repo.getRemoteData(srv.URL + "/data")
+	if err == nil || !strings.Contains(err.Error(), "no fetcher registered") {
+		t.Fatalf("expected an unregistered scheme error. got=%v\n", err)
+	}
+}
+
+func TestCommandFetcher(t *testing.T) {
+	f := &CommandFetcher{Name: "cat", Args: []string{"{url}"}}
+	r, err := f.Fetch(context.Background(), "testdata/comps.xml")
+	if err != nil {
+		t.Fatalf("could not fetch: %v\n", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("could not read: %v\n", err)
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatalf("could not close: %v\n", err)
+	}
+	want, err := ioutil.ReadFile("testdata/comps.xml")
+	if err != nil {
+		t.Fatalf("could not read comps: %v\n", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("invalid content.\ngot= %q\nwant=%q\n", got, want)
+	}
+
+	r, err = f.Fetch(context.Background(), "testdata/no-such-file.xml")
+	if err != nil {
+		t.Fatalf("could not fetch: %v\n", err)
+	}
+	_, err = ioutil.ReadAll(r)
+	r.Close()
+	if err == nil || !strings.Contains(err.Error(), "no-such-file.xml") {
+		t.Fatalf("expected the failure of the command to be reported. got=%v\n", err)
+	}
+
+	f = &CommandFetcher{Name: "lbpkr-no-such-command"}
+	_, err = f.Fetch(context.Background(), "s3://bucket/repomd.xml")
+	if err == nil {
+		t.Fatalf("expected an error\n")
+	}
+}
@@ -0,0 +1,193 @@
+package yum
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileListsDataType is the ID of the filelists data in the repomd.xml file
+const fileListsDataType = "filelists"
+
+// findFileProvider locates the latest package shipping the file fname,
+// according to the filelists metadata of the repository.
+func (repo *Repository) findFileProvider(fname string) (*Package, error) {
+	pkgs := repo.fileLists()[fname]
+	if len(pkgs) <= 0 {
+		return nil, fmt.Errorf("no package providing file %q", fname)
+	}
+
+	sorted := make(Packages, len(pkgs))
+	copy(sorted, pkgs)
+	sort.Sort(sorted)
+	return sorted[len(sorted)-1], nil
+}
+
+// fileLists returns the packages of the repository shipping each file, by path.
+// The filelists metadata is only loaded (and downloaded if needed) on first use.
+// fileLists returns nil if it could not be loaded: it is then tried again on
+// the next call.
+func (repo *Repository) fileLists() map[string][]*Package {
+	if repo.files != nil {
+		return repo.files
+	}
+
+	fname, err := repo.fileListsDB()
+	if err != nil {
+		repo.msg.Warnf("repository [%s] - could not retrieve filelists: %v\n", repo.Name, err)
+		return nil
+	}
+
+	files, err := repo.loadFileLists(fname)
+	if err != nil {
+		repo.msg.Warnf("repository [%s] - could not load filelists [%s]: %v\n", repo.Name, fname, err)
+		return nil
+	}
+	repo.files = files
+	return repo.files
+}
+
+// fileListsDB returns the path to the filelists DB in the cache directory,
+// downloading it from the remote repository if it is missing or outdated.
+func (repo *Repository) fileListsDB() (string, error) {
+	return repo.metadataFile(fileListsDataType)
+}
+
+// metadataFile returns the path to the metadata file of type dtype in the
+// cache directory, downloading it from the remote repository if it is
+// missing or outdated.
+// Data types left out by repo.WantedDataTypes are never downloaded.
+func (repo *Repository) metadataFile(dtype string) (string, error) {
+	err := repo.checkDataType(dtype)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := repo.localMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		return "", err
+	}
+
+	rmd, ok := md[dtype]
+	if !ok {
+		return "", fmt.Errorf("%w: no %s entry in [%s]", ErrMetadataNotFound, dtype, repo.LocalRepoMdXml)
+	}
+
+	fname := filepath.Join(repo.CacheDir, path.Base(rmd.Location))
+	if path_exists(fname) {
+		if VerifyFile(fname, rmd.ChecksumType, rmd.Checksum) == nil {
+			return fname, nil
+		}
+	}
+
+	if repo.Offline {
+		return "", fmt.Errorf("%w: missing cache file [%s]", ErrOffline, fname)
+	}
+
+	tmp, err := repo.downloadDB(repo.context(), rmd)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp, fname)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return fname, nil
+}
+
+// loadFileLists parses the filelists XML file fname and returns the packages
+// of the repository shipping each file, by path.
+func (repo *Repository) loadFileLists(fname string) (map[string][]*Package, error) {
+	type xmlPackage struct {
+		Name    string `xml:"name,attr"`
+		Arch    string `xml:"arch,attr"`
+		Version struct {
+			Epoch   string `xml:"epoch,attr"`
+			Version string `xml:"ver,attr"`
+			Release string `xml:"rel,attr"`
+		} `xml:"version"`
+		Files []string `xml:"file"`
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	files := make(map[string][]*Package)
+	candidates := make(map[string][]*Package)
+
+	// filelists are large: decode them one package at a time.
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+
+		var xpkg xmlPackage
+		err = dec.DecodeElement(&xpkg, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs, ok := candidates[xpkg.Name]
+		if !ok {
+			pkgs, _ = repo.Backend.FindAllMatchingName(xpkg.Name)
+			candidates[xpkg.Name] = pkgs
+		}
+
+		var pkg *Package
+		for _, p := range pkgs {
+			if p.Arch() == xpkg.Arch && RpmEvrCompare(
+				p.Epoch(), p.Version(), p.Release(),
+				xpkg.Version.Epoch, xpkg.Version.Version, xpkg.Version.Release,
+			) == 0 {
+				pkg = p
+				break
+			}
+		}
+		if pkg == nil {
+			repo.msg.Debugf("no package %s-%s-%s.%s for filelists entry\n",
+				xpkg.Name, xpkg.Version.Version, xpkg.Version.Release, xpkg.Arch,
+			)
+			continue
+		}
+
+		for _, file := range xpkg.Files {
+			file = strings.TrimSpace(file)
+			files[file] = append(files[file], pkg)
+		}
+	}
+
+	return files, nil
+}
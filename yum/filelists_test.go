@@ -0,0 +1,123 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepositoryFileLists(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	repo.RepoUrl = "file://" + remote
+
+	sum, err := checksumFile("testdata/filelists.xml", "sha256")
+	if err != nil {
+		t.Fatalf("could not compute checksum: %v\n", err)
+	}
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(fmt.Sprintf(`<repomd>
+  <data type="filelists">
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/filelists.xml"/>
+  </data>
+</repomd>`, sum)), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	// a failure to retrieve the filelists is not remembered.
+	_, err = repo.FindLatestMatchingRequire(NewRequires("/usr/bin/bar", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error without remote filelists\n")
+	}
+	err = copyTestFile(filepath.Join(remote, "repodata", "filelists.xml"), "testdata/filelists.xml")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+
+	// file requirements are resolved through filelists, downloaded on demand.
+	for _, table := range []struct {
+		file string
+		want string
+	}{
+		{"/usr/bin/bar", "bar-1.0-1"},
+		{"/usr/bin/foo", "foo-2.0-1"},
+		{"/usr/lib64/libfoo.so", "foo-2.0-1"},
+	} {
+		pkg, err := repo.FindLatestMatchingRequire(NewRequires(table.file, "", "", "", "", ""))
+		if err != nil {
+			t.Fatalf("could not find provider of %s: %v\n", table.file, err)
+		}
+		if pkg.ID() != table.want {
+			t.Fatalf("%s: expected %s. got=%s\n", table.file, table.want, pkg.ID())
+		}
+	}
+
+	if !path_exists(filepath.Join(repo.CacheDir, "filelists.xml")) {
+		t.Fatalf("expected filelists to be cached\n")
+	}
+
+	_, err = repo.FindLatestMatchingRequire(NewRequires("/usr/bin/nosuchfile", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error for an unknown file\n")
+	}
+
+	// filelists are not consulted for non-file requirements.
+	_, err = repo.FindLatestMatchingRequire(NewRequires("usr/bin/bar", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error for a non-file requirement\n")
+	}
+
+	needsfile, err := repo.FindLatestMatchingName("needsfile", "", "")
+	if err != nil {
+		t.Fatalf("could not find needsfile: %v\n", err)
+	}
+	pkgs, err := repo.RequiredPackages(needsfile)
+	if err != nil {
+		t.Fatalf("could not resolve needsfile: %v\n", err)
+	}
+	if got, want := pkgNames(pkgs), []string{"bar", "needsfile"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v. got=%v\n", want, got)
+	}
+}
+
+func TestRepositoryFileListsOffline(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+	repo.Offline = true
+
+	err := copyTestFile(
+		repo.LocalRepoMdXml,
+		"testdata/testconfig-xml/var/cache/lbyum/lcg/repomd.xml",
+	)
+	if err != nil {
+		t.Fatalf("could not seed cache: %v\n", err)
+	}
+
+	_, err = repo.fileListsDB()
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+
+	_, err = repo.FindLatestMatchingRequire(NewRequires("/usr/bin/bar", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error without filelists\n")
+	}
+}
@@ -0,0 +1,107 @@
+package yum
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// verifyRepoMD verifies the repository metadata data against its detached
+// signature (repomd.xml.asc), using the GPG key of the repository.
+// If no GPG key is configured, the verification is skipped.
+func (repo *Repository) verifyRepoMD(data []byte) error {
+	if repo.GPGKey == "" {
+		repo.msg.Warnf("repository [%s] - no GPG key configured, skipping signature verification\n", repo.Name)
+		return nil
+	}
+
+	sigurl, err := repo.repoDataURL(repo.RepoUrl, "repomd.xml.asc")
+	if err != nil {
+		return err
+	}
+	r, err := repo.getRemoteData(sigurl)
+	if err != nil {
+		return fmt.Errorf("%w: could not retrieve signature [%s]: %v", ErrSignature, redact(sigurl), err)
+	}
+	defer r.Close()
+
+	sig := new(bytes.Buffer)
+	_, err = io.Copy(sig, r)
+	if err != nil {
+		return fmt.Errorf("%w: could not retrieve signature [%s]: %v", ErrSignature, redact(sigurl), err)
+	}
+
+	err = gpgVerify(repo.GPGKey, data, sig.Bytes())
+	if err != nil {
+		return err
+	}
+	repo.msg.Debugf("repository [%s] - valid signature [%s]\n", repo.Name, redact(sigurl))
+	return nil
+}
+
+// gpgVerify verifies data against the detached signature sig with the gpg command.
+// key is either the path to a public key file or an ASCII-armored public key.
+// All the failures of the verification wrap ErrSignature.
+func gpgVerify(key string, data, sig []byte) error {
+	bin, err := exec.LookPath("gpg")
+	if err != nil {
+		return fmt.Errorf("%w: verification needs the 'gpg' command: %v", ErrSignature, err)
+	}
+
+	var keydata []byte
+	if strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN PGP") {
+		keydata = []byte(key)
+	} else {
+		keydata, err = ioutil.ReadFile(key)
+		if err != nil {
+			return fmt.Errorf("%w: could not read GPG key: %v", ErrSignature, err)
+		}
+	}
+
+	// use a throw-away keyring, holding only the key of the repository.
+	home, err := ioutil.TempDir("", "lbpkr-gpg-")
+	if err != nil {
+		return fmt.Errorf("%w: could not create GPG keyring: %v", ErrSignature, err)
+	}
+	defer os.RemoveAll(home)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"key", keydata},
+		{"repomd.xml", data},
+		{"repomd.xml.asc", sig},
+	}
+	for _, f := range files {
+		err = ioutil.WriteFile(filepath.Join(home, f.name), f.data, 0600)
+		if err != nil {
+			return fmt.Errorf("%w: could not create GPG keyring: %v", ErrSignature, err)
+		}
+	}
+
+	gpg := func(args ...string) error {
+		args = append([]string{"--homedir", home, "--batch", "--no-tty", "--quiet"}, args...)
+		out, err := exec.Command(bin, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v\n%s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	err = gpg("--import", filepath.Join(home, "key"))
+	if err != nil {
+		return fmt.Errorf("%w: could not import GPG key: %v", ErrSignature, err)
+	}
+
+	err = gpg("--verify", filepath.Join(home, "repomd.xml.asc"), filepath.Join(home, "repomd.xml"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package yum
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyRepoMD(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skipf("no gpg command available: %v\n", err)
+	}
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-gpg-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create repodata: %v\n", err)
+	}
+	data := []byte("<repomd></repomd>\n")
+	repomd := filepath.Join(remote, "repodata", "repomd.xml")
+	err = ioutil.WriteFile(repomd, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	// generate a signing key and sign repomd.xml with it.
+	home := filepath.Join(remote, "gnupg")
+	err = os.Mkdir(home, 0700)
+	if err != nil {
+		t.Fatalf("could not create gpg homedir: %v\n", err)
+	}
+	gpg := func(args ...string) []byte {
+		args = append([]string{"--homedir", home, "--batch", "--quiet"}, args...)
+		out, err := exec.Command("gpg", args...).Output()
+		if err != nil {
+			t.Fatalf("gpg %v: %v\n", args, err)
+		}
+		return out
+	}
+	gpg("--passphrase", "", "--pinentry-mode", "loopback",
+		"--quick-gen-key", "lbpkr test <test@example.org>", "ed25519", "sign", "never",
+	)
+	gpg("--armor", "--detach-sign", "-o", repomd+".asc", repomd)
+	key := string(gpg("--armor", "--export"))
+	defer exec.Command("gpgconf", "--homedir", home, "--kill", "all").Run()
+
+	repo, err := NewRepository(
+		"testrepo", "file://"+remote, filepath.Join(remote, "cache"),
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	for _, test := range []struct {
+		name string
+		key  string
+		data []byte
+		path string // value of $PATH, if not empty
+		ok   bool
+	}{
+		{
+			name: "valid",
+			key:  key,
+			data: data,
+			ok:   true,
+		},
+		{
+			name: "bad-signature",
+			key:  key,
+			data: []byte("<repomd><!-- tampered --></repomd>\n"),
+		},
+		{
+			name: "missing-key",
+			key:  filepath.Join(remote, "no-such-key.asc"),
+			data: data,
+		},
+		{
+			name: "invalid-key",
+			key:  "-----BEGIN PGP PUBLIC KEY BLOCK-----\ngarbage\n-----END PGP PUBLIC KEY BLOCK-----\n",
+			data: data,
+		},
+		{
+			name: "no-gpg",
+			key:  key,
+			data: data,
+			path: filepath.Join(remote, "no-such-dir"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.path != "" {
+				t.Setenv("PATH", test.path)
+			}
+			repo.GPGKey = test.key
+			err := repo.verifyRepoMD(test.data)
+			switch {
+			case test.ok && err != nil:
+				t.Fatalf("expected a valid signature: %v\n", err)
+			case !test.ok && !errors.Is(err, ErrSignature):
+				t.Fatalf("expected ErrSignature. got=%v\n", err)
+			}
+		})
+	}
+}
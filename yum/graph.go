@@ -0,0 +1,70 @@
+package yum
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GraphDeps writes to w the dependency graph of the latest versions of the
+// packages names, in the Graphviz DOT format.
+// Nodes are the packages resolved as for PlanInstall, edges go from a package
+// to the packages satisfying its requirements and are labeled with these
+// requirements. Requirements which could not be satisfied are drawn as
+// dashed edges to "missing" nodes.
+// Conflicts among the resolved packages are not reported.
+func (repo *Repository) GraphDeps(names []string, w io.Writer) error {
+	r, err := repo.resolveNames(names)
+	if err != nil {
+		return err
+	}
+
+	node := func(pkg *Package) string {
+		return strconv.Quote(pkg.ID() + "." + pkg.Arch())
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", strconv.Quote(repo.Name))
+	fmt.Fprintf(bw, "\tnode [shape=box];\n")
+	for _, pkg := range r.order {
+		fmt.Fprintf(bw, "\t%s;\n", node(pkg))
+	}
+	for _, edge := range r.edges {
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s];\n",
+			node(edge.from), node(edge.to), strconv.Quote(requireLabel(edge.requires)),
+		)
+	}
+	for _, m := range r.missing {
+		label := strconv.Quote(requireLabel(m.Requires))
+		missing := strconv.Quote("missing: " + requireLabel(m.Requires))
+		fmt.Fprintf(bw, "\t%s [color=red, style=dashed];\n", missing)
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s, color=red, style=dashed];\n",
+			node(m.Package), missing, label,
+		)
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// requireLabel returns a human readable form of req (e.g. "bar >= 1.0").
+func requireLabel(req *Requires) string {
+	ops := map[string]string{"EQ": "=", "LT": "<", "LE": "<=", "GT": ">", "GE": ">="}
+	op, ok := ops[strings.ToUpper(req.Flags())]
+	if !ok {
+		op = req.Flags()
+	}
+	if op == "" || req.Version() == "" {
+		return req.Name()
+	}
+
+	evr := req.Version()
+	if req.Epoch() != "" && req.Epoch() != "0" {
+		evr = req.Epoch() + ":" + evr
+	}
+	if req.Release() != "" {
+		evr += "-" + req.Release()
+	}
+	return req.Name() + " " + op + " " + evr
+}
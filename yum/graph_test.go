@@ -0,0 +1,41 @@
+package yum
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryGraphDeps(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	buf := new(bytes.Buffer)
+	err := repo.GraphDeps([]string{"qux", "cyc-a"}, buf)
+	if err != nil {
+		t.Fatalf("could not graph dependencies: %v\n", err)
+	}
+	dot := buf.String()
+
+	if !strings.HasPrefix(dot, `digraph "`+repo.Name+`" {`) || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("invalid DOT graph:\n%s\n", dot)
+	}
+	for _, want := range []string{
+		`"qux-1.0-1.noarch";`,
+		`"qux-1.0-1.noarch" -> "foo-2.0-1.x86_64" [label="foo"];`,
+		`"foo-2.0-1.x86_64" -> "bar-1.0-1.x86_64" [label="bar >= 1.0"];`,
+		`"qux-1.0-1.noarch" -> "missing: nosuchlib >= 3.0" [label="nosuchlib >= 3.0", color=red, style=dashed];`,
+		// cycles are drawn in full.
+		`"cyc-a-1.0-1.noarch" -> "cyc-b-1.0-1.noarch" [label="cyc-b"];`,
+		`"cyc-b-1.0-1.noarch" -> "cyc-a-1.0-1.noarch" [label="cyc-a"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %s in DOT graph:\n%s\n", want, dot)
+		}
+	}
+
+	err = repo.GraphDeps([]string{"nosuchpkg"}, buf)
+	if err == nil {
+		t.Fatalf("expected an error for an unknown package\n")
+	}
+}
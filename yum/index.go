@@ -0,0 +1,221 @@
+package yum
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// indexEntry is the on-disk representation of a Provides or Requires entry.
+type indexEntry struct {
+	Name    string
+	Version string
+	Release string
+	Epoch   string
+	Flags   string
+	Pre     string
+}
+
+// indexPackage is the on-disk representation of a Package.
+type indexPackage struct {
+	Name       string
+	Version    string
+	Release    string
+	Epoch      string
+	Group      string
+	Arch       string
+	Location   string
+	Size       int64
+	ISize      int64
+	Checksum   string
+	ChkType    string
+	Info       *PackageInfo
+	Requires   []indexEntry
+	Provides   []indexEntry
+	Obsoletes  []indexEntry
+	Conflicts  []indexEntry
+	Recommends []indexEntry
+	Suggests   []indexEntry
+}
+
+// indexVersion is the version of the on-disk index format.
+// Indices written with another version are considered stale.
+const indexVersion = 6
+
+// packageIndex is the on-disk representation of a parsed DB.
+type packageIndex struct {
+	Key      string // checksum of the DB the index was built from
+	Packages []indexPackage
+}
+
+func newIndexEntries(reqs []*Requires) []indexEntry {
+	entries := make([]indexEntry, 0, len(reqs))
+	for _, req := range reqs {
+		entries = append(entries, indexEntry{
+			Name:    req.name,
+			Version: req.version,
+			Release: req.release,
+			Epoch:   req.epoch,
+			Flags:   req.flags,
+			Pre:     req.pre,
+		})
+	}
+	return entries
+}
+
+func newIndexRequires(entries []indexEntry) []*Requires {
+	reqs := make([]*Requires, 0, len(entries))
+	for _, e := range entries {
+		reqs = append(reqs, NewRequires(e.Name, e.Version, e.Release, e.Epoch, e.Flags, e.Pre))
+	}
+	return reqs
+}
+
+// indexKey returns the key identifying the current DB, from the checksum
+// recorded in the local repomd.xml file.
+// indexKey returns an empty string if the key could not be computed.
+func (repo *RepositoryXMLBackend) indexKey() string {
+	data, err := repo.Repository.localMetadata()
+	if err != nil || len(data) <= 0 {
+		return ""
+	}
+	md, err := repo.Repository.checkRepoMD(data)
+	if err != nil {
+		return ""
+	}
+	rmd, ok := md[repo.YumDataType()]
+	if !ok || rmd.Checksum == "" {
+		return ""
+	}
+	return fmt.Sprintf("v%d:%s:%s:%s", indexVersion, repo.Primary, rmd.ChecksumType, rmd.Checksum)
+}
+
+// saveIndex writes the packages known by the backend into the index file.
+func (repo *RepositoryXMLBackend) saveIndex(key string) error {
+	idx := packageIndex{
+		Key:      key,
+		Packages: make([]indexPackage, 0, len(repo.Packages)),
+	}
+	for _, pkgs := range repo.Packages {
+		for _, pkg := range pkgs {
+			provs := make([]indexEntry, 0, len(pkg.provides))
+			for _, prov := range pkg.provides {
+				provs = append(provs, indexEntry{
+					Name:    prov.name,
+					Version: prov.version,
+					Release: prov.release,
+					Epoch:   prov.epoch,
+					Flags:   prov.flags,
+				})
+			}
+			idx.Packages = append(idx.Packages, indexPackage{
+				Name:       pkg.name,
+				Version:    pkg.version,
+				Release:    pkg.release,
+				Epoch:      pkg.epoch,
+				Group:      pkg.group,
+				Arch:       pkg.arch,
+				Location:   pkg.location,
+				Size:       pkg.size,
+				ISize:      pkg.isize,
+				Checksum:   pkg.checksum,
+				ChkType:    pkg.chksumType,
+				Info:       pkg.info,
+				Requires:   newIndexEntries(pkg.requires),
+				Provides:   provs,
+				Obsoletes:  newIndexEntries(pkg.obsoletes),
+				Conflicts:  newIndexEntries(pkg.conflicts),
+				Recommends: newIndexEntries(pkg.recommends),
+				Suggests:   newIndexEntries(pkg.suggests),
+			})
+		}
+	}
+
+	return write_file_atomic(repo.Index, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(&idx)
+	})
+}
+
+// loadIndex loads the packages from the index file, if it was built for the DB key.
+func (repo *RepositoryXMLBackend) loadIndex(key string) error {
+	f, err := os.Open(repo.Index)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var idx packageIndex
+	err = gob.NewDecoder(f).Decode(&idx)
+	if err != nil {
+		return err
+	}
+
+	if idx.Key != key {
+		return fmt.Errorf("yum: stale index (key=%q, want=%q)", idx.Key, key)
+	}
+
+	for _, v := range idx.Packages {
+		pkg := NewPackage(v.Name, v.Version, v.Release, v.Epoch)
+		pkg.group = v.Group
+		pkg.arch = v.Arch
+		pkg.location = v.Location
+		pkg.size = v.Size
+		pkg.isize = v.ISize
+		pkg.checksum = v.Checksum
+		pkg.chksumType = v.ChkType
+		pkg.info = v.Info
+		pkg.repository = repo.Repository
+		pkg.backend = "RepositoryXMLBackend"
+		for _, e := range v.Provides {
+			pkg.provides = append(pkg.provides, NewProvides(
+				e.Name, e.Version, e.Release, e.Epoch, e.Flags, pkg,
+			))
+		}
+		pkg.requires = newIndexRequires(v.Requires)
+		pkg.obsoletes = newIndexRequires(v.Obsoletes)
+		pkg.conflicts = newIndexRequires(v.Conflicts)
+		pkg.recommends = newIndexRequires(v.Recommends)
+		pkg.suggests = newIndexRequires(v.Suggests)
+		repo.addPackage(pkg)
+	}
+	return err
+}
+
+// RebuildIndex removes the index of the parsed DB and loads the DB again,
+// regenerating the index.
+func (repo *RepositoryXMLBackend) RebuildIndex() error {
+	if path_exists(repo.Index) {
+		err := os.RemoveAll(repo.Index)
+		if err != nil {
+			return err
+		}
+	}
+	return repo.LoadDB()
+}
+
+// Flush writes the index of the parsed DB, if it could not be written
+// when the DB was loaded.
+func (repo *RepositoryXMLBackend) Flush() error {
+	if repo.pending == "" {
+		return nil
+	}
+	err := repo.saveIndex(repo.pending)
+	if err != nil {
+		return err
+	}
+	repo.pending = ""
+	return nil
+}
+
+// RebuildIndex forces the regeneration of the index of the parsed DB,
+// for backends maintaining such an index.
+func (repo *Repository) RebuildIndex() error {
+	type indexer interface {
+		RebuildIndex() error
+	}
+	if idx, ok := repo.Backend.(indexer); ok {
+		return idx.RebuildIndex()
+	}
+	return nil
+}
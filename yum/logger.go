@@ -0,0 +1,70 @@
+package yum
+
+import (
+	"os"
+
+	"github.com/gonuts/logger"
+)
+
+// Logger reports the messages of a Repository.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// leveler is a Logger with a configurable verbosity level.
+type leveler interface {
+	SetLevel(lvl logger.Level)
+}
+
+// stdLogger adapts a gonuts logger to the Logger interface.
+type stdLogger struct {
+	*logger.Logger
+}
+
+func newStdLogger(name string) stdLogger {
+	return stdLogger{logger.NewLogger(name, logger.INFO, os.Stdout)}
+}
+
+func (l stdLogger) Debugf(format string, args ...interface{}) { l.Logger.Debugf(format, args...) }
+func (l stdLogger) Infof(format string, args ...interface{})  { l.Logger.Infof(format, args...) }
+func (l stdLogger) Warnf(format string, args ...interface{})  { l.Logger.Warnf(format, args...) }
+func (l stdLogger) Errorf(format string, args ...interface{}) { l.Logger.Errorf(format, args...) }
+
+// SetLogger routes the messages of the repository, and of its backend, to msg.
+// A nil msg restores the default logger, writing to os.Stdout.
+// Messages emitted while setting up the backend are only routed to msg if
+// SetLogger is called before SetupBackend.
+func (repo *Repository) SetLogger(msg Logger) {
+	if msg == nil {
+		msg = newStdLogger("repo")
+	}
+	repo.msg = msg
+
+	type logSetter interface {
+		setLogger(msg Logger)
+	}
+	if b, ok := repo.Backend.(logSetter); ok {
+		b.setLogger(msg)
+	}
+}
+
+// SetLogLevel sets the verbosity level of the messages of the repository.
+// SetLogLevel has no effect on loggers without a configurable level.
+func (repo *Repository) SetLogLevel(lvl logger.Level) {
+	if msg, ok := repo.msg.(leveler); ok {
+		msg.SetLevel(lvl)
+	}
+}
+
+// SetQuiet suppresses all the messages of the repository but errors when
+// quiet is true, and restores the default (INFO) verbosity level otherwise.
+func (repo *Repository) SetQuiet(quiet bool) {
+	lvl := logger.INFO
+	if quiet {
+		lvl = logger.ERROR
+	}
+	repo.SetLogLevel(lvl)
+}
@@ -0,0 +1,81 @@
+package yum
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gonuts/logger"
+)
+
+// recordLogger is a Logger recording all messages.
+type recordLogger struct {
+	msgs []string
+}
+
+func (l *recordLogger) logf(lvl, format string, args ...interface{}) {
+	l.msgs = append(l.msgs, lvl+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Debugf(format string, args ...interface{}) { l.logf("DBG", format, args...) }
+func (l *recordLogger) Infof(format string, args ...interface{})  { l.logf("INFO", format, args...) }
+func (l *recordLogger) Warnf(format string, args ...interface{})  { l.logf("WARN", format, args...) }
+func (l *recordLogger) Errorf(format string, args ...interface{}) { l.logf("ERR", format, args...) }
+
+func TestRepositorySetLogger(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	msg := &recordLogger{}
+	repo.SetLogger(msg)
+
+	// messages from the backend are routed to the new logger as well.
+	_, err := repo.FindAllMatchingName("nosuchpkg")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown package\n")
+	}
+	if len(msg.msgs) == 0 || !strings.Contains(strings.Join(msg.msgs, ""), "nosuchpkg") {
+		t.Fatalf("expected backend messages to be recorded. got=%q\n", msg.msgs)
+	}
+
+	repo.SetLogger(nil)
+	if _, ok := repo.msg.(stdLogger); !ok {
+		t.Fatalf("expected the default logger to be restored. got=%T\n", repo.msg)
+	}
+}
+
+func TestRepositorySetQuiet(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	buf := new(bytes.Buffer)
+	repo.SetLogger(stdLogger{logger.NewLogger("repo", logger.INFO, buf)})
+
+	repo.SetQuiet(true)
+	repo.msg.Infof("info message\n")
+	repo.msg.Warnf("warning message\n")
+	repo.msg.Errorf("error message\n")
+	if out := buf.String(); strings.Contains(out, "info") || strings.Contains(out, "warning") || !strings.Contains(out, "error") {
+		t.Fatalf("expected only errors in quiet mode. got=%q\n", out)
+	}
+
+	buf.Reset()
+	repo.SetQuiet(false)
+	repo.msg.Debugf("debug message\n")
+	repo.msg.Infof("info message\n")
+	if out := buf.String(); strings.Contains(out, "debug") || !strings.Contains(out, "info") {
+		t.Fatalf("expected info messages. got=%q\n", out)
+	}
+
+	buf.Reset()
+	repo.SetLogLevel(logger.DEBUG)
+	repo.msg.Debugf("debug message\n")
+	if out := buf.String(); !strings.Contains(out, "debug") {
+		t.Fatalf("expected debug messages. got=%q\n", out)
+	}
+
+	// loggers without levels are left untouched.
+	repo.SetLogger(&recordLogger{})
+	repo.SetQuiet(true)
+}
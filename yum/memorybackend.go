@@ -0,0 +1,117 @@
+package yum
+
+import (
+	"fmt"
+)
+
+// memoryBackend is a Backend serving a fixed set of packages, held in memory.
+// Queries are delegated to an XML backend holding these packages.
+type memoryBackend struct {
+	db *RepositoryXMLBackend
+}
+
+// NewMemoryBackend returns a Backend serving the packages pkgs.
+// The returned backend has no DB to download or load: it is meant for tests
+// and for embedding lbpkr with a known set of packages, by assigning it to
+// the Backend field of a Repository.
+func NewMemoryBackend(pkgs []*Package) Backend {
+	db := &RepositoryXMLBackend{
+		Name:     "MemoryBackend",
+		Packages: make(map[string][]*Package),
+		Provides: make(map[string][]*Provides),
+		msg:      newStdLogger("memory"),
+	}
+	ba := &memoryBackend{db: db}
+	for _, pkg := range pkgs {
+		if pkg.backend == "" {
+			pkg.backend = backendName(ba)
+		}
+		db.addPackage(pkg)
+	}
+	return ba
+}
+
+// Close cleans up a backend after use
+func (repo *memoryBackend) Close() error {
+	return nil
+}
+
+// YumDataType returns the ID for the data type as used in the repomd.xml file
+func (repo *memoryBackend) YumDataType() string {
+	return "memory"
+}
+
+// GetLatestDB always fails: the packages of a memory backend are fixed.
+func (repo *memoryBackend) GetLatestDB(url string) error {
+	return fmt.Errorf("yum: memory backend can not download DB [%s]", redact(url))
+}
+
+// HasDB returns true: the packages of a memory backend are always there.
+func (repo *memoryBackend) HasDB() bool {
+	return true
+}
+
+// LoadDB does nothing: the packages of a memory backend are always loaded.
+func (repo *memoryBackend) LoadDB() error {
+	return nil
+}
+
+// FindLatestMatchingName locats a package by name, returns the latest available version.
+func (repo *memoryBackend) FindLatestMatchingName(name, version, release string) (*Package, error) {
+	return repo.db.FindLatestMatchingName(name, version, release)
+}
+
+// FindAllMatchingName locates all the packages with a given name,
+// sorted from the newest to the oldest version.
+func (repo *memoryBackend) FindAllMatchingName(name string) ([]*Package, error) {
+	return repo.db.FindAllMatchingName(name)
+}
+
+// FindLatestMatchingRequire locates a package providing a given functionality.
+func (repo *memoryBackend) FindLatestMatchingRequire(requirement *Requires) (*Package, error) {
+	return repo.db.FindLatestMatchingRequire(requirement)
+}
+
+// GetPackages returns all the packages known by the backend
+func (repo *memoryBackend) GetPackages() []*Package {
+	return repo.db.GetPackages()
+}
+
+// walkPackages calls fn for each package known by the backend, until fn
+// returns an error.
+func (repo *memoryBackend) walkPackages(fn func(pkg *Package) error) error {
+	return repo.db.walkPackages(fn)
+}
+
+// findProviders returns the packages named name or providing the
+// capability name, whatever their version.
+func (repo *memoryBackend) findProviders(name string) ([]*Package, error) {
+	return repo.db.findProviders(name)
+}
+
+// ListPackages returns the packages whose name matches the shell pattern,
+// sorted by name and version.
+func (repo *memoryBackend) ListPackages(pattern string) ([]*Package, error) {
+	return repo.db.ListPackages(pattern)
+}
+
+// FindObsoleting returns the packages obsoleting the package name,
+// sorted by name and version.
+func (repo *memoryBackend) FindObsoleting(name string) ([]*Package, error) {
+	return repo.db.FindObsoleting(name)
+}
+
+// FindRequiring returns the packages requiring the capability name,
+// sorted by name and version.
+func (repo *memoryBackend) FindRequiring(name string) ([]*Package, error) {
+	return repo.db.FindRequiring(name)
+}
+
+// counts returns the number of packages, provides and requires entries.
+func (repo *memoryBackend) counts() (int64, int64, int64, error) {
+	return repo.db.counts()
+}
+
+func (repo *memoryBackend) setLogger(msg Logger) {
+	repo.db.msg = msg
+}
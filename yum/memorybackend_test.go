@@ -0,0 +1,99 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestMemoryRepository(t *testing.T) *Repository {
+	mkpkg := func(name, version string, requires ...string) *Package {
+		pkg := NewPackage(name, version, "1", "0")
+		pkg.SetArch("noarch")
+		pkg.SetLocation(name + "-" + version + "-1.noarch.rpm")
+		pkg.AddProvides(NewProvides(name, version, "1", "0", "EQ", nil))
+		for _, req := range requires {
+			pkg.AddRequires(NewRequires(req, "", "", "", "", ""))
+		}
+		return pkg
+	}
+
+	app := mkpkg("app", "1.0", "libz")
+	app.AddObsoletes(NewRequires("oldapp", "", "", "", "", ""))
+	zlib := mkpkg("zlib", "1.2")
+	zlib.AddProvides(NewProvides("libz", "", "", "", "", nil))
+
+	repo, err := NewRepository("memory", "http://dummy-url.org", "testdata/cachedir.tmp",
+		nil, false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Backend = NewMemoryBackend([]*Package{
+		app, zlib, mkpkg("zlib", "1.1"),
+	})
+	return repo
+}
+
+func TestMemoryBackend(t *testing.T) {
+	repo := newTestMemoryRepository(t)
+	defer repo.Close()
+
+	pkg, err := repo.FindLatestMatchingName("zlib", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if pkg.ID() != "zlib-1.2-1" {
+		t.Fatalf("expected zlib-1.2-1. got=%s\n", pkg.ID())
+	}
+
+	pkgs, err := repo.FindAllMatchingName("zlib")
+	if err != nil {
+		t.Fatalf("could not find packages: %v\n", err)
+	}
+	if got, want := pkgNames(pkgs), []string{"zlib", "zlib"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v. got=%v\n", want, got)
+	}
+
+	app, err := repo.FindLatestMatchingName("app", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	pkgs, err = repo.RequiredPackages(app)
+	if err != nil {
+		t.Fatalf("could not resolve requirements: %v\n", err)
+	}
+	if got, want := pkgNames(pkgs), []string{"zlib", "app"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v. got=%v\n", want, got)
+	}
+
+	pkgs, err = repo.ListPackages("z*")
+	if err != nil || len(pkgs) != 2 {
+		t.Fatalf("expected 2 packages. got=%d (err=%v)\n", len(pkgs), err)
+	}
+
+	pkgs, err = repo.FindObsoleting("oldapp")
+	if err != nil {
+		t.Fatalf("could not find obsoleting packages: %v\n", err)
+	}
+	if got, want := pkgNames(pkgs), []string{"app"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v. got=%v\n", want, got)
+	}
+
+	if n := len(repo.GetPackages()); n != 3 {
+		t.Fatalf("expected 3 packages. got=%d\n", n)
+	}
+	if stats := repo.Stats(); stats.Packages != 3 || stats.Provides != 4 || stats.Requires != 1 {
+		t.Fatalf("invalid stats: %#v\n", stats)
+	}
+
+	if app.Url() != "app-1.0-1.noarch.rpm" {
+		t.Fatalf("invalid URL: %q\n", app.Url())
+	}
+
+	if !repo.Backend.HasDB() || repo.Backend.LoadDB() != nil {
+		t.Fatalf("expected memory backend to be loaded\n")
+	}
+	if err := repo.Backend.GetLatestDB("http://dummy-url.org/primary.xml.gz"); err == nil {
+		t.Fatalf("expected memory backend to refuse downloading a DB\n")
+	}
+}
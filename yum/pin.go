@@ -0,0 +1,81 @@
+package yum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Pin locks a package to a given version.
+type Pin struct {
+	Name    string
+	Version string
+	Release string // empty to accept any release of Version
+}
+
+func (pin Pin) String() string {
+	if pin.Release == "" {
+		return pin.Version
+	}
+	return pin.Version + "-" + pin.Release
+}
+
+// matches returns whether pkg is at the version pin locks to.
+func (pin Pin) matches(pkg *Package) bool {
+	return pkg.Version() == pin.Version && (pin.Release == "" || pkg.Release() == pin.Release)
+}
+
+// Pin locks the package name to version (and release, if not empty):
+// FindLatestMatchingName and FindLatestMatchingRequire then return that
+// version of the package, even when a newer one is available.
+// Pinning an already pinned package replaces the previous pin.
+func (repo *Repository) Pin(name, version, release string) {
+	if repo.pins == nil {
+		repo.pins = make(map[string]Pin)
+	}
+	repo.pins[name] = Pin{Name: name, Version: version, Release: release}
+}
+
+// Unpin removes the pin on the package name, if any.
+func (repo *Repository) Unpin(name string) {
+	delete(repo.pins, name)
+}
+
+// Pins returns the pins of the repository, sorted by package name.
+func (repo *Repository) Pins() []Pin {
+	pins := make([]Pin, 0, len(repo.pins))
+	for _, pin := range repo.pins {
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].Name < pins[j].Name })
+	return pins
+}
+
+// pinnedRequire returns the package satisfying requirement in place of pkg,
+// according to the pin on pkg, if any.
+func (repo *Repository) pinnedRequire(pkg *Package, requirement *Requires) (*Package, error) {
+	if pkg == nil {
+		return nil, nil
+	}
+	pin, ok := repo.pins[pkg.Name()]
+	if !ok || pin.matches(pkg) {
+		return pkg, nil
+	}
+
+	pkgs, err := repo.FindAllMatchingName(pkg.Name())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pkgs {
+		if !pin.matches(p) {
+			continue
+		}
+		// files are not listed among the provides of packages.
+		if strings.HasPrefix(requirement.Name(), "/") || p.Satisfies(requirement) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("yum: package %q pinned to %s does not provide %s",
+		pkg.Name(), pin, requirement.ID(),
+	)
+}
@@ -0,0 +1,86 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepositoryPin(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		version  string
+		release  string
+		expected string
+	}{
+		{"", "", "foo-2.0-1"},
+		{"1.5", "1", "foo-1.5-1"},
+		{"1.5", "2", "foo-1.5-2"},
+		{"2.0", "", "foo-2.0-1"},
+	} {
+		if table.version == "" {
+			repo.Unpin("foo")
+		} else {
+			repo.Pin("foo", table.version, table.release)
+		}
+
+		pkg, err := repo.FindLatestMatchingName("foo", "", "")
+		if err != nil {
+			t.Fatalf("pin=%s-%s: could not find package: %v\n", table.version, table.release, err)
+		}
+		if pkg.ID() != table.expected {
+			t.Fatalf("pin=%s-%s: expected %s. got=%s\n", table.version, table.release, table.expected, pkg.ID())
+		}
+
+		pkg, err = repo.FindLatestMatchingRequire(NewRequires("foo", "", "", "", "", ""))
+		if err != nil {
+			t.Fatalf("pin=%s-%s: could not find provider: %v\n", table.version, table.release, err)
+		}
+		if pkg.ID() != table.expected {
+			t.Fatalf("pin=%s-%s: expected provider %s. got=%s\n", table.version, table.release, table.expected, pkg.ID())
+		}
+	}
+
+	repo.Pin("foo", "1.5", "1")
+	repo.Pin("bar", "1.0", "")
+	if got, want := repo.Pins(), []Pin{{"bar", "1.0", ""}, {"foo", "1.5", "1"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid pins.\ngot= %v\nwant=%v\n", got, want)
+	}
+
+	_, err := repo.FindLatestMatchingName("foo", "2.0", "")
+	if err == nil {
+		t.Fatalf("expected an error for a version conflicting with a pin\n")
+	}
+
+	// only foo-2.0 provides libfoo.so
+	_, err = repo.FindLatestMatchingRequire(NewRequires("libfoo.so", "", "", "", "", ""))
+	if err == nil {
+		t.Fatalf("expected an error for a requirement not provided by the pinned version\n")
+	}
+
+	// dependency resolution honors pins.
+	pkg, err := repo.FindLatestMatchingName("qux", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	pkgs, _ := repo.RequiredPackages(pkg)
+	found := false
+	for _, p := range pkgs {
+		if p.Name() == "foo" {
+			found = true
+			if p.ID() != "foo-1.5-1" {
+				t.Fatalf("expected pinned foo-1.5-1 to be required. got=%s\n", p.ID())
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected foo to be required by qux. got=%v\n", pkgNames(pkgs))
+	}
+
+	repo.Unpin("foo")
+	repo.Unpin("bar")
+	if pins := repo.Pins(); len(pins) != 0 {
+		t.Fatalf("expected no pins. got=%v\n", pins)
+	}
+}
@@ -0,0 +1,32 @@
+package yum
+
+import (
+	"fmt"
+)
+
+// Ping checks that the remote repository is reachable and serves valid repo
+// metadata: its repomd.xml file is retrieved from repo.RepoMdUrl and parsed,
+// but neither cached nor used to update the backend.
+// Ping returns nil if the repository is healthy, e.g. for readiness probes.
+// Offline repositories can only be pinged if their URL is a local one.
+func (repo *Repository) Ping() error {
+	r, err := repo.openRemoteData(repo.context(), repo.RepoMdUrl, nil)
+	if err != nil {
+		return fmt.Errorf("yum: could not reach repository [%s]: %w", repo.Name, err)
+	}
+	defer r.Close()
+
+	data, err := repo.readMetadata(r, repo.RepoMdUrl)
+	if err != nil {
+		return fmt.Errorf("yum: could not read metadata of repository [%s]: %w", repo.Name, err)
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		return fmt.Errorf("yum: invalid metadata for repository [%s]: %w", repo.Name, err)
+	}
+	if len(md) == 0 {
+		return fmt.Errorf("%w: no data entry in [%s]", ErrMetadataNotFound, redact(repo.RepoMdUrl))
+	}
+	return nil
+}
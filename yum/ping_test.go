@@ -0,0 +1,78 @@
+package yum
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRepositoryPing(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-ping-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	files := http.FileServer(http.Dir(remote))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid/repodata/repomd.xml":
+			io.WriteString(w, "<repomd><data")
+		case "/empty/repodata/repomd.xml":
+			io.WriteString(w, "<repomd></repomd>")
+		default:
+			files.ServeHTTP(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	newRepo := func(url string) *Repository {
+		repo, err := NewRepository("testrepo", url, cachedir,
+			[]string{"RepositoryXMLBackend"},
+			false, false,
+		)
+		if err != nil {
+			t.Fatalf("could not create repository: %v\n", err)
+		}
+		repo.Retries = 0
+		return repo
+	}
+
+	repo := newRepo(srv.URL)
+	err = repo.Ping()
+	if err != nil {
+		t.Fatalf("could not ping repository: %v\n", err)
+	}
+	if path_exists(repo.LocalRepoMdXml) {
+		t.Fatalf("Ping should not cache the metadata\n")
+	}
+
+	repo.Offline = true
+	err = repo.Ping()
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+
+	var herr *HTTPError
+	err = newRepo(srv.URL + "/missing").Ping()
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 *HTTPError. got=%v\n", err)
+	}
+
+	err = newRepo(srv.URL + "/invalid").Ping()
+	if err == nil {
+		t.Fatalf("expected an error for invalid metadata\n")
+	}
+
+	err = newRepo(srv.URL + "/empty").Ping()
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+}
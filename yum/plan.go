@@ -0,0 +1,171 @@
+package yum
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlannedPackage describes a package which would be installed by an InstallPlan.
+type PlannedPackage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Release     string `json:"release"`
+	Epoch       string `json:"epoch,omitempty"`
+	Arch        string `json:"arch"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`         // size of the RPM file, 0 if unknown
+	InstallSize int64  `json:"install_size"` // size of the installed package, 0 if unknown
+
+	RequiredBy  string `json:"required_by,omitempty"` // name of the package which pulled this one in, empty if requested
+	Requirement string `json:"requirement,omitempty"` // requirement of RequiredBy satisfied by this package (e.g. "bar >= 1.0")
+	Recommended bool   `json:"recommended,omitempty"` // whether Requirement is a recommendation of RequiredBy
+}
+
+// InstallPlan describes what installing a list of packages would do.
+type InstallPlan struct {
+	Repository       string           `json:"repository"`
+	Packages         []PlannedPackage `json:"packages"`           // packages to install, dependencies first
+	Suggests         []string         `json:"suggests,omitempty"` // packages suggested by the planned ones, not installed
+	TotalSize        int64            `json:"total_size"`         // sum of the sizes of the RPM files
+	TotalInstallSize int64            `json:"total_install_size"` // sum of the sizes of the installed packages
+}
+
+// PlanInstall returns the plan to install the latest versions of the
+// packages names, together with all their dependencies.
+// Names of the form "@group" select the packages of a group (see FindGroup);
+// packages of the group missing from the repository are skipped.
+// PlanInstall does not download any package.
+// Like RequiredPackages, PlanInstall returns the plan for the packages it
+// could resolve together with an *UnresolvedError or a *ConflictError if
+// the requirements of the packages could not be satisfied.
+func (repo *Repository) PlanInstall(names []string) (*InstallPlan, error) {
+	r, err := repo.resolveNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := r.result()
+	plan := &InstallPlan{
+		Repository: repo.Name,
+		Packages:   make([]PlannedPackage, 0, len(pkgs)),
+	}
+	for _, pkg := range pkgs {
+		planned := PlannedPackage{
+			Name:        pkg.Name(),
+			Version:     pkg.Version(),
+			Release:     pkg.Release(),
+			Epoch:       pkg.Epoch(),
+			Arch:        pkg.Arch(),
+			URL:         redact(pkg.Url()),
+			Size:        pkg.Size(),
+			InstallSize: pkg.InstallSize(),
+		}
+		if edge, ok := r.parents[pkg.Key()]; ok {
+			planned.RequiredBy = edge.from.Name()
+			planned.Requirement = requireLabel(edge.requires)
+			planned.Recommended = edge.weak
+		}
+		plan.Packages = append(plan.Packages, planned)
+		plan.TotalSize += pkg.Size()
+		plan.TotalInstallSize += pkg.InstallSize()
+	}
+	plan.Suggests = suggested(pkgs)
+	return plan, err
+}
+
+// Why returns the chain of requirements which caused the package name to be
+// part of the plan, starting from the requested package, e.g.:
+//
+//	requested qux
+//	qux requires foo
+//	foo requires bar >= 1.0
+//
+// Why returns nil if name is not part of the plan.
+func (plan *InstallPlan) Why(name string) []string {
+	find := func(name string) *PlannedPackage {
+		for i := range plan.Packages {
+			if plan.Packages[i].Name == name {
+				return &plan.Packages[i]
+			}
+		}
+		return nil
+	}
+
+	var chain []string
+	seen := make(map[string]bool)
+	for pkg := find(name); pkg != nil && !seen[pkg.Name]; pkg = find(pkg.RequiredBy) {
+		seen[pkg.Name] = true
+		if pkg.RequiredBy == "" {
+			chain = append(chain, "requested "+pkg.Name)
+			break
+		}
+		verb := "requires"
+		if pkg.Recommended {
+			verb = "recommends"
+		}
+		step := fmt.Sprintf("%s %s %s", pkg.RequiredBy, verb, pkg.Requirement)
+		if pkg.Requirement != pkg.Name && !strings.HasPrefix(pkg.Requirement, pkg.Name+" ") {
+			step += " (" + pkg.Name + ")"
+		}
+		chain = append(chain, step)
+	}
+
+	// requested package first.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// resolveNames returns a resolver holding the latest versions of the
+// packages names, together with all their dependencies.
+// Names of the form "@group" select the packages of a group, as for
+// PlanInstall.
+func (repo *Repository) resolveNames(names []string) (*resolver, error) {
+	r := repo.newResolver()
+	for _, name := range names {
+		if strings.HasPrefix(name, "@") {
+			grp, err := repo.FindGroup(name[1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, pname := range grp.PackageNames() {
+				pkg, err := repo.FindLatestMatchingName(pname, "", "")
+				if err != nil || pkg == nil {
+					repo.msg.Warnf("group [%s] - skipping missing package %q\n", grp.ID, pname)
+					continue
+				}
+				r.add(pkg)
+			}
+			continue
+		}
+		pkg, err := repo.FindLatestMatchingName(name, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("yum: could not find package %q: %w", name, err)
+		}
+		r.add(pkg)
+	}
+	return r, nil
+}
+
+// suggested returns the packages suggested by pkgs and not provided by
+// any of them, in order of appearance.
+func suggested(pkgs []*Package) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+	loop:
+		for _, sug := range pkg.Suggests() {
+			for _, p := range pkgs {
+				if p.Satisfies(sug) {
+					continue loop
+				}
+			}
+			if name := requireLabel(sug); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
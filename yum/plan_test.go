@@ -0,0 +1,148 @@
+package yum
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryPlanInstall(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	plan, err := repo.PlanInstall([]string{"foo", "cyc-a"})
+	if err != nil {
+		t.Fatalf("could not plan install: %v\n", err)
+	}
+
+	names := make([]string, 0, len(plan.Packages))
+	for _, pkg := range plan.Packages {
+		names = append(names, pkg.Name)
+	}
+	if want := []string{"bar", "foo", "cyc-b", "cyc-a"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected %v. got=%v\n", want, names)
+	}
+
+	foo := plan.Packages[1]
+	if want := "http://dummy-url.org/foo-2.0-1.x86_64.rpm"; foo.URL != want {
+		t.Fatalf("invalid URL. got=%q. want=%q\n", foo.URL, want)
+	}
+	if foo.Version != "2.0" || foo.Arch != "x86_64" || foo.Size != 1024 || foo.InstallSize != 4096 {
+		t.Fatalf("invalid planned package: %#v\n", foo)
+	}
+	if want := int64(512 + 1024 + 128 + 128); plan.TotalSize != want {
+		t.Fatalf("invalid total size. got=%d. want=%d\n", plan.TotalSize, want)
+	}
+	if want := int64(2048 + 4096 + 512 + 512); plan.TotalInstallSize != want {
+		t.Fatalf("invalid total install size. got=%d. want=%d\n", plan.TotalInstallSize, want)
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("could not marshal plan: %v\n", err)
+	}
+	var decoded InstallPlan
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("could not unmarshal plan: %v\n", err)
+	}
+	if !reflect.DeepEqual(&decoded, plan) {
+		t.Fatalf("JSON round-trip failed.\ngot= %#v\nwant=%#v\n", &decoded, plan)
+	}
+
+	plan, err = repo.PlanInstall([]string{"qux"})
+	if _, ok := err.(*UnresolvedError); !ok {
+		t.Fatalf("expected an *UnresolvedError. got=%v\n", err)
+	}
+	if plan == nil || len(plan.Packages) != 3 {
+		t.Fatalf("expected a partial plan. got=%#v\n", plan)
+	}
+
+	_, err = repo.PlanInstall([]string{"nosuchpkg"})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown package\n")
+	}
+}
+
+func TestInstallPlanCheckDiskSpace(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-diskspace-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// the install directory does not need to exist yet.
+	target := filepath.Join(tmpdir, "opt", "lhcb")
+
+	plan := &InstallPlan{TotalInstallSize: 1024}
+	err = plan.CheckDiskSpace(target)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skipf("disk space check not supported: %v\n", err)
+	}
+	if err != nil {
+		t.Fatalf("could not check disk space: %v\n", err)
+	}
+
+	plan = &InstallPlan{TotalInstallSize: 1 << 62}
+	err = plan.CheckDiskSpace(target)
+	if !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("expected ErrNoSpace. got=%v\n", err)
+	}
+	if !strings.Contains(err.Error(), "4611686018427387904 bytes needed") {
+		t.Fatalf("error should report the needed space. got=%v\n", err)
+	}
+}
+
+func TestInstallPlanWhy(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	plan, err := repo.PlanInstall([]string{"qux", "cyc-a"})
+	if _, ok := err.(*UnresolvedError); !ok {
+		t.Fatalf("expected an *UnresolvedError. got=%v\n", err)
+	}
+
+	for _, table := range []struct {
+		name string
+		want []string
+	}{
+		{"qux", []string{"requested qux"}},
+		{"foo", []string{"requested qux", "qux requires foo"}},
+		{"bar", []string{"requested qux", "qux requires foo", "foo requires bar >= 1.0"}},
+		{"cyc-a", []string{"requested cyc-a"}},
+		{"cyc-b", []string{"requested cyc-a", "cyc-a requires cyc-b"}},
+		{"baz", nil},
+	} {
+		got := plan.Why(table.name)
+		if !reflect.DeepEqual(got, table.want) {
+			t.Errorf("why %s:\ngot= %q\nwant=%q\n", table.name, got, table.want)
+		}
+	}
+
+	mem := newTestMemoryRepository(t)
+	defer mem.Close()
+
+	plan, err = mem.PlanInstall([]string{"app"})
+	if err != nil {
+		t.Fatalf("could not plan install: %v\n", err)
+	}
+	if got, want := plan.Why("zlib"), []string{"requested app", "app requires libz (zlib)"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("why zlib:\ngot= %q\nwant=%q\n", got, want)
+	}
+
+	weak := newTestXMLRepository(t, "testdata/weakdeps.xml")
+	defer weak.Close()
+
+	plan, err = weak.PlanInstall([]string{"editor"})
+	if err != nil {
+		t.Fatalf("could not plan install: %v\n", err)
+	}
+	if got, want := plan.Why("spell"), []string{"requested editor", "editor recommends spell >= 1.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("why spell:\ngot= %q\nwant=%q\n", got, want)
+	}
+}
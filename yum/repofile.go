@@ -0,0 +1,296 @@
+package yum
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	gocfg "github.com/gonuts/config"
+)
+
+// repoVarPattern matches the $var and ${var} variables of .repo files.
+var repoVarPattern = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+// goarchToBaseArch maps GOARCH values to yum's $basearch.
+var goarchToBaseArch = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "i386",
+	"arm64":   "aarch64",
+	"arm":     "armhfp",
+	"ppc64le": "ppc64le",
+	"ppc64":   "ppc64",
+	"s390x":   "s390x",
+}
+
+// DefaultRepoVars returns the values of the variables of .repo files for the
+// current host: $arch and $basearch, from the architecture lbpkr was built
+// for, $releasever, from the major version of the VERSION_ID of
+// /etc/os-release (if any), and $YUM0 to $YUM9, from the environment.
+func DefaultRepoVars() map[string]string {
+	vars := make(map[string]string)
+	if arch, ok := goarchToBaseArch[runtime.GOARCH]; ok {
+		vars["arch"] = arch
+		vars["basearch"] = arch
+	}
+	if release := osReleaseVersion("/etc/os-release"); release != "" {
+		vars["releasever"] = strings.SplitN(release, ".", 2)[0]
+	}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("YUM%d", i)
+		if v, ok := os.LookupEnv(name); ok {
+			vars[name] = v
+		}
+	}
+	return vars
+}
+
+// osReleaseVersion returns the VERSION_ID of the os-release file fname,
+// or an empty string if it is not available.
+func osReleaseVersion(fname string) string {
+	f, err := os.Open(fname)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if strings.HasPrefix(line, "VERSION_ID=") {
+			return strings.Trim(strings.TrimPrefix(line, "VERSION_ID="), `"'`)
+		}
+	}
+	return ""
+}
+
+// LoadReposFromFile creates the repositories described by the yum .repo
+// file fname (e.g. /etc/yum.repos.d/base.repo), substituting the variables
+// of DefaultRepoVars. See LoadReposFromFileVars.
+func LoadReposFromFile(fname string) ([]*Repository, error) {
+	return LoadReposFromFileVars(fname, DefaultRepoVars())
+}
+
+// LoadReposFromFileVars creates the repositories described by the yum .repo
+// file fname, substituting the variables ($basearch, ${releasever}, ...) of
+// its values with vars. An undefined variable is an error.
+//
+// Each section of the file describes a repository, named after the section.
+// The following options are honored:
+//   - baseurl: URLs of the repository; the first one is its URL and the
+//     other ones its mirrors,
+//   - mirrorlist: URL of a file listing the URLs of the repository, one per
+//     line, only retrieved when baseurl is not set,
+//   - enabled: disabled repositories are skipped,
+//   - priority: priority of the repository (see RepositorySet),
+//   - gpgkey: key used to verify repomd.xml, if repo_gpgcheck is enabled
+//     (only the first key is used). Remote keys are retrieved.
+//
+// The other options are ignored. The repositories cache their metadata in
+// a directory named after them, under the lbpkr directory of the user cache
+// directory, and use the SQLite backend, then the XML one, as New.
+// Their backends are not set up: they may be configured further (e.g. with
+// WithCacheDir) before calling SetupBackend.
+func LoadReposFromFileVars(fname string, vars map[string]string) ([]*Repository, error) {
+	cfg, err := gocfg.ReadDefault(fname)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not read repo file [%s]: %w", fname, err)
+	}
+
+	cachedir, err := os.UserCacheDir()
+	if err != nil {
+		cachedir = os.TempDir()
+	}
+	cachedir = filepath.Join(cachedir, "lbpkr")
+
+	repos := make([]*Repository, 0)
+	for _, section := range cfg.Sections() {
+		switch strings.ToLower(section) {
+		case "default", "main":
+			continue
+		}
+
+		opt := func(name string) (string, error) {
+			if !cfg.HasOption(section, name) {
+				return "", nil
+			}
+			v, err := cfg.String(section, name)
+			if err != nil {
+				return "", err
+			}
+			v, err = expandRepoVars(v, vars)
+			if err != nil {
+				return "", fmt.Errorf("yum: invalid option %q of repository [%s] in [%s]: %w", name, section, fname, err)
+			}
+			return strings.TrimSpace(v), nil
+		}
+		flag := func(name string, def bool) (bool, error) {
+			if !cfg.HasOption(section, name) {
+				return def, nil
+			}
+			v, err := cfg.Bool(section, name)
+			if err != nil {
+				return false, fmt.Errorf("yum: invalid option %q of repository [%s] in [%s]: %w", name, section, fname, err)
+			}
+			return v, nil
+		}
+
+		enabled, err := flag("enabled", true)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			continue
+		}
+
+		baseurl, err := opt("baseurl")
+		if err != nil {
+			return nil, err
+		}
+		mirrorlist, err := opt("mirrorlist")
+		if err != nil {
+			return nil, err
+		}
+		urls := repoURLs(baseurl)
+		if len(urls) == 0 && mirrorlist == "" {
+			return nil, fmt.Errorf("yum: repository [%s] in [%s] has neither baseurl nor mirrorlist", section, fname)
+		}
+
+		rawurl := mirrorlist
+		if len(urls) > 0 {
+			rawurl = urls[0]
+		}
+		repo, err := NewRepository(
+			section, rawurl, filepath.Join(cachedir, section),
+			append([]string(nil), defaultBackends...),
+			false, false,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(urls) > 0 {
+			repo.Mirrors = urls[1:]
+		} else {
+			err = repo.loadMirrorList(mirrorlist)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.HasOption(section, "priority") {
+			repo.Priority, err = cfg.Int(section, "priority")
+			if err != nil {
+				return nil, fmt.Errorf("yum: invalid option %q of repository [%s] in [%s]: %w", "priority", section, fname, err)
+			}
+		}
+
+		gpgcheck, err := flag("repo_gpgcheck", false)
+		if err != nil {
+			return nil, err
+		}
+		if gpgcheck {
+			gpgkey, err := opt("gpgkey")
+			if err != nil {
+				return nil, err
+			}
+			keys := repoURLs(gpgkey)
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("yum: repository [%s] in [%s] has repo_gpgcheck enabled but no gpgkey", section, fname)
+			}
+			repo.GPGKey, err = repo.loadGPGKey(keys[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// expandRepoVars substitutes the $var and ${var} variables of s with vars.
+func expandRepoVars(s string, vars map[string]string) (string, error) {
+	var err error
+	s = repoVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := strings.Trim(m, "${}")
+		v, ok := vars[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("undefined variable $%s", name)
+			}
+			return m
+		}
+		return v
+	})
+	return s, err
+}
+
+// repoURLs splits the list of URLs of a .repo option, separated by spaces,
+// commas or new lines.
+func repoURLs(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// loadMirrorList sets the URL and the mirrors of the repository from the
+// mirror list at rawurl.
+func (repo *Repository) loadMirrorList(rawurl string) error {
+	r, err := repo.getRemoteData(rawurl)
+	if err != nil {
+		return fmt.Errorf("yum: could not retrieve mirror list [%s] of repository [%s]: %w", redact(rawurl), repo.Name, err)
+	}
+	defer r.Close()
+
+	var urls []string
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scan.Err(); err != nil {
+		return fmt.Errorf("yum: could not read mirror list [%s] of repository [%s]: %w", redact(rawurl), repo.Name, err)
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("yum: empty mirror list [%s] for repository [%s]", redact(rawurl), repo.Name)
+	}
+
+	base, err := normalizeURL(urls[0])
+	if err != nil {
+		return fmt.Errorf("yum: invalid URL in mirror list [%s] of repository [%s]: %w", redact(rawurl), repo.Name, err)
+	}
+	repo.RepoUrl = base
+	repo.Mirrors = urls[1:]
+	return repo.SetRepoDataPath(repo.RepoDataPath)
+}
+
+// loadGPGKey returns the GPG key at rawurl, as expected by repo.GPGKey: the
+// path to local keys, the content of remote ones.
+func (repo *Repository) loadGPGKey(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", fmt.Errorf("yum: invalid gpgkey [%s] for repository [%s]: %w", rawurl, repo.Name, err)
+	}
+	if u.Scheme == "file" || u.Scheme == "" {
+		return u.Path, nil
+	}
+
+	r, err := repo.getRemoteData(rawurl)
+	if err != nil {
+		return "", fmt.Errorf("yum: could not retrieve gpgkey [%s] of repository [%s]: %w", redact(rawurl), repo.Name, err)
+	}
+	defer r.Close()
+	key, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("yum: could not retrieve gpgkey [%s] of repository [%s]: %w", redact(rawurl), repo.Name, err)
+	}
+	return string(key), nil
+}
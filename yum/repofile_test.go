@@ -0,0 +1,105 @@
+package yum
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadReposFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lbpkr-test-repofile-")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	mirrorlist := filepath.Join(dir, "mirrorlist")
+	err = ioutil.WriteFile(mirrorlist, []byte(`# mirrors
+http://mirror1.example.org/updates/7/x86_64
+
+http://mirror2.example.org/updates/7/x86_64
+`), 0644)
+	if err != nil {
+		t.Fatalf("could not write mirror list: %v\n", err)
+	}
+
+	fname := filepath.Join(dir, "test.repo")
+	err = ioutil.WriteFile(fname, []byte(`[main]
+cachedir=/var/cache/yum
+
+[base]
+name=Base $releasever - $basearch
+baseurl=http://repo.example.org/$releasever/os/${basearch}/ http://mirror.example.org/$releasever/os/$basearch
+gpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-test
+repo_gpgcheck=1
+priority=10
+
+[updates]
+mirrorlist=file://`+mirrorlist+`
+enabled=1
+
+[extras]
+baseurl=http://repo.example.org/$releasever/extras/$basearch/
+enabled=0
+`), 0644)
+	if err != nil {
+		t.Fatalf("could not write repo file: %v\n", err)
+	}
+
+	vars := map[string]string{"releasever": "7", "basearch": "x86_64"}
+	repos, err := LoadReposFromFileVars(fname, vars)
+	if err != nil {
+		t.Fatalf("could not load repo file: %v\n", err)
+	}
+
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	if want := []string{"base", "updates"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("invalid repositories.\ngot= %v\nwant=%v\n", names, want)
+	}
+
+	base := repos[0]
+	if want := "http://repo.example.org/7/os/x86_64"; base.RepoUrl != want {
+		t.Fatalf("invalid URL.\ngot= %q\nwant=%q\n", base.RepoUrl, want)
+	}
+	if want := []string{"http://mirror.example.org/7/os/x86_64"}; !reflect.DeepEqual(base.Mirrors, want) {
+		t.Fatalf("invalid mirrors.\ngot= %v\nwant=%v\n", base.Mirrors, want)
+	}
+	if base.Priority != 10 {
+		t.Fatalf("invalid priority. got=%d. want=%d\n", base.Priority, 10)
+	}
+	if want := "/etc/pki/rpm-gpg/RPM-GPG-KEY-test"; base.GPGKey != want {
+		t.Fatalf("invalid GPG key.\ngot= %q\nwant=%q\n", base.GPGKey, want)
+	}
+	if want := filepath.Join(dir, "cache", "lbpkr", "base"); base.CacheDir != want {
+		t.Fatalf("invalid cache directory.\ngot= %q\nwant=%q\n", base.CacheDir, want)
+	}
+	if base.Backend != nil {
+		t.Fatalf("expected no backend to be set up\n")
+	}
+
+	updates := repos[1]
+	if want := "http://mirror1.example.org/updates/7/x86_64"; updates.RepoUrl != want {
+		t.Fatalf("invalid URL.\ngot= %q\nwant=%q\n", updates.RepoUrl, want)
+	}
+	if want := "http://mirror1.example.org/updates/7/x86_64/repodata/repomd.xml"; updates.RepoMdUrl != want {
+		t.Fatalf("invalid repomd URL.\ngot= %q\nwant=%q\n", updates.RepoMdUrl, want)
+	}
+	if want := []string{"http://mirror2.example.org/updates/7/x86_64"}; !reflect.DeepEqual(updates.Mirrors, want) {
+		t.Fatalf("invalid mirrors.\ngot= %v\nwant=%v\n", updates.Mirrors, want)
+	}
+	if updates.Priority != DefaultPriority || updates.GPGKey != "" {
+		t.Fatalf("invalid defaults: priority=%d gpgkey=%q\n", updates.Priority, updates.GPGKey)
+	}
+
+	_, err = LoadReposFromFileVars(fname, map[string]string{"basearch": "x86_64"})
+	if err == nil || !strings.Contains(err.Error(), "$releasever") {
+		t.Fatalf("expected an error for an undefined variable. got=%v\n", err)
+	}
+}
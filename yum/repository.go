@@ -2,16 +2,23 @@ package yum
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/gonuts/logger"
 )
 
 // List of packages to ignore for our case
@@ -22,76 +29,561 @@ var IGNORED_PACKAGES = []string{
 
 // Repository represents a YUM repository with all associated metadata.
 type Repository struct {
-	msg            *logger.Logger
+	msg            Logger
 	Name           string
 	RepoUrl        string
 	RepoMdUrl      string
+	RepoDataPath   string // path to the metadata directory, relative to RepoUrl (default: DefaultRepoDataPath)
 	LocalRepoMdXml string
 	CacheDir       string
 	Backends       []string
 	Backend        Backend
+	Mirrors        []string // base URLs of mirrors of the repository, tried in turn
+	Priority       int      // priority of the repository within a RepositorySet (lower wins)
+	Arch           string   // preferred architecture of packages (e.g. x86_64), then noarch. empty for no preference
+
+	ExcludeRecommends bool     // whether to leave out the packages recommended by the installed ones (weak dependencies)
+	WantedDataTypes   []string // repomd data types downloaded and parsed (e.g. "primary", "updateinfo"). empty for all
+
+	Timeout  time.Duration // timeout for connecting, receiving response headers and each chunk of the content
+	Retries  int           // number of retries on transient network errors
+	Offline  bool          // whether to only rely on the content of the cache directory
+	CacheTTL time.Duration // age under which the cached metadata is used without checking for updates (0: always check)
+	GPGKey   string        // path to (or ASCII-armored) GPG key used to verify repomd.xml
+
+	CACert             string // path to (or PEM-encoded) CA certificates trusted for HTTPS, on top of the system ones
+	InsecureSkipVerify bool   // whether to skip the verification of TLS certificates (insecure, logs a warning)
+
+	Progress ProgressFunc // optional callback reporting the progress of DB downloads (may be called concurrently)
+	OnEvent  func(Event)  // optional callback reporting the lifecycle of the backends (may be called concurrently)
+
+	PackageProgress func(pkg *Package, bytesRead, total int64) // optional callback reporting the progress of package downloads (default: Progress)
+
+	MaxBytesPerSec   int64  // maximum rate of the downloads of DBs and packages, shared by concurrent downloads (0: unlimited)
+	MaxMetadataBytes int64  // maximum size of the repo metadata (repomd.xml) downloaded from the remote repository (0: unlimited)
+	PackageCacheDir  string // directory keeping the downloaded RPM files, reused by DownloadPackage (empty: no package cache)
+
+	Username    string // user name for HTTP basic authentication
+	Password    string // password for HTTP basic authentication
+	BearerToken string // token for HTTP bearer authentication
+	Netrc       string // path to the netrc file with credentials (default: $NETRC or ~/.netrc)
+
+	ctx        context.Context
+	proxy      *url.URL
+	client     *http.Client
+	uclient    *http.Client           // HTTP client set by the user, if any
+	files      map[string][]*Package  // packages shipping a file, from the filelists metadata
+	groups     []*Group               // package groups, from the comps metadata
+	deltas     map[string][]*DeltaRPM // delta RPMs by name.arch, from the prestodelta metadata
+	advisories []*Advisory            // update advisories, from the updateinfo metadata
+	mdetag     string                 // ETag of the remote repo metadata
+	pins       map[string]Pin         // version locks, by package name
+	excludes   []string               // patterns of the packages to ignore
+	limiter    rateLimiter            // token bucket enforcing MaxBytesPerSec
 }
 
 // NewRepository create a new Repository with name and from url.
 func NewRepository(name, url, cachedir string, backends []string, setupBackend, checkForUpdates bool) (*Repository, error) {
+	return NewRepositoryContext(context.Background(), name, url, cachedir, backends, setupBackend, checkForUpdates)
+}
+
+// NewRepositoryContext creates a new Repository with name and from url.
+// The network operations of the repository are cancelled when ctx is done.
+func NewRepositoryContext(ctx context.Context, name, url, cachedir string, backends []string, setupBackend, checkForUpdates bool) (*Repository, error) {
+
+	url, err := normalizeURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid URL for repository [%s]: %w", name, err)
+	}
+
+	mdurl, err := joinURL(url, DefaultRepoDataPath+"/repomd.xml")
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid URL for repository [%s]: %w", name, err)
+	}
 
 	repo := Repository{
-		msg:            logger.NewLogger("repo", logger.INFO, os.Stdout),
+		ctx:            ctx,
+		msg:            newStdLogger("repo"),
 		Name:           name,
 		RepoUrl:        url,
-		RepoMdUrl:      url + "/repodata/repomd.xml",
+		RepoMdUrl:      mdurl,
+		RepoDataPath:   DefaultRepoDataPath,
 		LocalRepoMdXml: filepath.Join(cachedir, "repomd.xml"),
 		CacheDir:       cachedir,
 		Backends:       make([]string, len(backends)),
+		Timeout:        DefaultTimeout,
+		Retries:        DefaultRetries,
+		Priority:       DefaultPriority,
 	}
 	copy(repo.Backends, backends)
 
-	err := os.MkdirAll(cachedir, 0644)
+	err = os.MkdirAll(cachedir, 0755)
 	if err != nil {
 		return nil, err
 	}
 
 	// load appropriate backend if requested
 	if setupBackend {
-		if checkForUpdates {
-			err = repo.setupBackendFromRemote()
-			if err != nil {
-				return nil, err
+		err = repo.SetupBackend(checkForUpdates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &repo, err
+}
+
+// SetupBackend selects and loads the backend of the repository.
+// If checkForUpdates is true, the remote repository is checked for newer DBs.
+//
+// Repositories created with setupBackend=false can be configured (timeouts,
+// retries, ...) before calling SetupBackend.
+//
+// Offline repositories never check for updates. Neither do repositories
+// whose cached metadata is younger than repo.CacheTTL, unless the cache
+// can not be loaded.
+func (repo *Repository) SetupBackend(checkForUpdates bool) error {
+	if checkForUpdates && !repo.Offline {
+		if repo.cacheFresh() {
+			err := repo.setupBackendFromLocal()
+			if err == nil {
+				return nil
 			}
-		} else {
-			err = repo.setupBackendFromLocal()
+			repo.msg.Debugf("repository [%s] - could not load fresh cache: %v\n", repo.Name, err)
+		}
+		err := repo.setupBackendFromRemote()
+		if err == nil {
+			repo.touchMetadata()
+		}
+		return err
+	}
+	return repo.setupBackendFromLocal()
+}
+
+// Close cleans up after use.
+// Close flushes the index of the backend, if any, closes the backend and
+// releases the connections to the remote repository.
+func (repo *Repository) Close() error {
+	type flusher interface {
+		Flush() error
+	}
+
+	var err error
+	if repo.Backend != nil {
+		if f, ok := repo.Backend.(flusher); ok {
+			err = f.Flush()
 			if err != nil {
-				return nil, err
+				repo.msg.Warnf("could not flush index of repository [%s]: %v\n", repo.Name, err)
 			}
 		}
+		e := repo.Backend.Close()
+		if e != nil {
+			err = e
+		}
 	}
-	return &repo, err
+	repo.files = nil
+	repo.groups = nil
+	repo.deltas = nil
+	repo.advisories = nil
+	if repo.client != nil {
+		repo.client.CloseIdleConnections()
+	}
+	return err
 }
 
-// Close cleans up after use
-func (repo *Repository) Close() error {
-	return repo.Backend.Close()
+// WithCacheDir returns a copy of the repository caching its metadata in dir
+// instead of repo.CacheDir, e.g. to run isolated queries in parallel.
+// The configuration of the repository (URLs, backends, network and
+// authentication settings, pins, excludes, ...) is shared, but the copy has no backend
+// set up: call SetupBackend before querying it.
+// The copy does not share the download rate budget of repo.
+func (repo *Repository) WithCacheDir(dir string) *Repository {
+	clone := &Repository{
+		msg:                repo.msg,
+		Name:               repo.Name,
+		RepoUrl:            repo.RepoUrl,
+		RepoMdUrl:          repo.RepoMdUrl,
+		RepoDataPath:       repo.RepoDataPath,
+		LocalRepoMdXml:     repo.LocalRepoMdXml,
+		CacheDir:           dir,
+		Backends:           append([]string(nil), repo.Backends...),
+		Mirrors:            append([]string(nil), repo.Mirrors...),
+		Priority:           repo.Priority,
+		Arch:               repo.Arch,
+		ExcludeRecommends:  repo.ExcludeRecommends,
+		WantedDataTypes:    append([]string(nil), repo.WantedDataTypes...),
+		Timeout:            repo.Timeout,
+		Retries:            repo.Retries,
+		Offline:            repo.Offline,
+		CacheTTL:           repo.CacheTTL,
+		GPGKey:             repo.GPGKey,
+		CACert:             repo.CACert,
+		InsecureSkipVerify: repo.InsecureSkipVerify,
+		Progress:           repo.Progress,
+		OnEvent:            repo.OnEvent,
+		PackageProgress:    repo.PackageProgress,
+		MaxBytesPerSec:     repo.MaxBytesPerSec,
+		MaxMetadataBytes:   repo.MaxMetadataBytes,
+		PackageCacheDir:    repo.PackageCacheDir,
+		Username:           repo.Username,
+		Password:           repo.Password,
+		BearerToken:        repo.BearerToken,
+		Netrc:              repo.Netrc,
+		ctx:                repo.ctx,
+		proxy:              repo.proxy,
+		client:             repo.client,
+		uclient:            repo.uclient,
+	}
+	if repo.LocalRepoMdXml == filepath.Join(repo.CacheDir, "repomd.xml") {
+		clone.LocalRepoMdXml = filepath.Join(dir, "repomd.xml")
+	}
+	if repo.pins != nil {
+		clone.pins = make(map[string]Pin, len(repo.pins))
+		for name, pin := range repo.pins {
+			clone.pins[name] = pin
+		}
+	}
+	clone.excludes = append([]string(nil), repo.excludes...)
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		repo.msg.Warnf("could not create cache directory [%s]: %v\n", dir, err)
+	}
+	return clone
 }
 
 // FindLatestMatchingName locats a package by name, returns the latest available version.
+// Pinned packages are returned at their pinned version, and excluded
+// packages are skipped.
+// If repo.Arch is set, packages of that architecture are preferred, then
+// noarch ones, and packages of other architectures are only returned when
+// nothing else matches.
 func (repo *Repository) FindLatestMatchingName(name, version, release string) (*Package, error) {
-	return repo.Backend.FindLatestMatchingName(name, version, release)
+	if pin, ok := repo.pins[name]; ok {
+		if (version != "" && version != pin.Version) ||
+			(release != "" && pin.Release != "" && release != pin.Release) {
+			return nil, fmt.Errorf("yum: package %q is pinned to %s", name, pin)
+		}
+		version = pin.Version
+		if release == "" {
+			release = pin.Release
+		}
+	}
+	pkg, err := repo.Backend.FindLatestMatchingName(name, version, release)
+	if err != nil || pkg == nil {
+		return pkg, err
+	}
+	match := archMatchingName(name, version, release)
+	if repo.excluded(pkg) {
+		pkg, err = repo.latestIncluded(name, match)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repo.preferArch(pkg, match)
+}
+
+// FindLatestMatchingConstraint locates the latest version of the package name
+// satisfying all the comma-separated version constraints of constraint
+// (e.g. ">=1.2,<2.0"). See ParseConstraint for the syntax of constraints.
+// Pinned packages are only returned at their pinned version.
+// Packages are preferred by architecture as for FindLatestMatchingName.
+func (repo *Repository) FindLatestMatchingConstraint(name, constraint string) (*Package, error) {
+	reqs, err := ParseConstraint(name, constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := repo.FindAllMatchingName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pin, pinned := repo.pins[name]
+	match := func(p *Package) bool {
+		if pinned && !pin.matches(p) {
+			return false
+		}
+		for _, req := range reqs {
+			if !req.ProvideMatches(p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, pkg := range pkgs {
+		if match(pkg) {
+			return repo.preferArch(pkg, match)
+		}
+	}
+	return nil, fmt.Errorf("yum: no package %q matching %q", name, constraint)
+}
+
+// FindAllMatchingName locates all the packages with a given name, sorted from
+// the newest to the oldest version.
+// Excluded packages are left out.
+func (repo *Repository) FindAllMatchingName(name string) ([]*Package, error) {
+	pkgs, err := repo.Backend.FindAllMatchingName(name)
+	if err != nil || len(repo.excludes) == 0 {
+		return pkgs, err
+	}
+	included := repo.withoutExcluded(pkgs)
+	if len(included) == 0 && len(pkgs) > 0 {
+		return nil, fmt.Errorf("yum: package %q is excluded", name)
+	}
+	return included, nil
 }
 
 // FindLatestMatchingRequire locates a package providing a given functionality.
+// Requirements on files (absolute paths) not provided by any package of the
+// backend are looked up in the filelists metadata of the repository.
+// Pinned packages are returned at their pinned version, and excluded
+// packages are skipped.
+// Packages are preferred by architecture as for FindLatestMatchingName.
 func (repo *Repository) FindLatestMatchingRequire(requirement *Requires) (*Package, error) {
-	return repo.Backend.FindLatestMatchingRequire(requirement)
+	pkg, err := repo.Backend.FindLatestMatchingRequire(requirement)
+	if (err != nil || pkg == nil) && strings.HasPrefix(requirement.Name(), "/") {
+		p, ferr := repo.findFileProvider(requirement.Name())
+		if ferr == nil {
+			pkg, err = p, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		// backends return no package when no version matches the requirement.
+		return nil, fmt.Errorf("yum: no package providing %s", requirement.ID())
+	}
+	if repo.excluded(pkg) {
+		pkg, err = repo.latestIncludedProvider(requirement)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pkg, err = repo.pinnedRequire(pkg, requirement)
+	if err != nil {
+		return nil, err
+	}
+	pin, pinned := repo.pins[pkg.Name()]
+	return repo.preferArch(pkg, func(p *Package) bool {
+		return (!pinned || pin.matches(p)) && archMatchingRequire(requirement)(p)
+	})
+}
+
+// FindAllMatchingRequire locates all the packages providing the capability
+// requirement (e.g. "java"), whatever their version, so callers may choose
+// among alternative implementations.
+// Packages are sorted by name, then from the newest to the oldest version.
+// Requirements on files (absolute paths) are also looked up in the filelists
+// metadata of the repository.
+// Excluded packages are left out.
+func (repo *Repository) FindAllMatchingRequire(requirement string) ([]*Package, error) {
+	type providerFinder interface {
+		findProviders(name string) ([]*Package, error)
+	}
+
+	if requirement == "" {
+		return nil, fmt.Errorf("yum: empty requirement")
+	}
+
+	var pkgs []*Package
+	if f, ok := repo.Backend.(providerFinder); ok {
+		found, err := f.findProviders(requirement)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = found
+	} else {
+		err := repo.WalkPackages(func(pkg *Package) error {
+			if pkg.ProvidesCapability(requirement) {
+				pkgs = append(pkgs, pkg)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.HasPrefix(requirement, "/") {
+		seen := make(map[string]bool, len(pkgs))
+		for _, pkg := range pkgs {
+			seen[pkg.Key()] = true
+		}
+		for _, pkg := range repo.fileLists()[requirement] {
+			if !seen[pkg.Key()] {
+				seen[pkg.Key()] = true
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+
+	pkgs = repo.withoutExcluded(pkgs)
+	if len(pkgs) <= 0 {
+		return nil, fmt.Errorf("yum: no package providing %q", requirement)
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		pi, pj := pkgs[i], pkgs[j]
+		if pi.Name() != pj.Name() {
+			return pi.Name() < pj.Name()
+		}
+		c := RpmEvrCompare(
+			pi.Epoch(), pi.Version(), pi.Release(),
+			pj.Epoch(), pj.Version(), pj.Release(),
+		)
+		if c != 0 {
+			return c > 0
+		}
+		return pi.Arch() < pj.Arch()
+	})
+	return pkgs, nil
 }
 
-// GetPackages returns all the packages known by a YUM repository
+// GetPackages returns all the packages known by a YUM repository, sorted by
+// name, then epoch:version-release, then arch, whatever the backend.
+// Excluded packages are left out.
 func (repo *Repository) GetPackages() []*Package {
-	return repo.Backend.GetPackages()
+	pkgs := repo.withoutExcluded(repo.Backend.GetPackages())
+	sortPackages(pkgs)
+	return pkgs
+}
+
+// WalkPackages calls fn for each package known by the repository, in no
+// particular order, without loading all of them in memory when the backend
+// supports it. Excluded packages are skipped.
+// WalkPackages stops at the first error returned by fn and returns it.
+func (repo *Repository) WalkPackages(fn func(pkg *Package) error) error {
+	type walker interface {
+		walkPackages(fn func(pkg *Package) error) error
+	}
+
+	if len(repo.excludes) > 0 {
+		walk := fn
+		fn = func(pkg *Package) error {
+			if repo.excluded(pkg) {
+				return nil
+			}
+			return walk(pkg)
+		}
+	}
+
+	if w, ok := repo.Backend.(walker); ok {
+		return w.walkPackages(fn)
+	}
+
+	for _, pkg := range repo.Backend.GetPackages() {
+		err := fn(pkg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPackages returns the packages whose name matches the shell pattern
+// (e.g. "gcc*" or "*-devel"), sorted by name and version.
+// The pattern syntax is the one of path.Match.
+// Excluded packages are left out.
+func (repo *Repository) ListPackages(pattern string) ([]*Package, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("yum: invalid package pattern %q: %w", pattern, err)
+	}
+	pkgs, err := repo.Backend.ListPackages(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return repo.withoutExcluded(pkgs), nil
+}
+
+// FindObsoleting returns the packages declaring that they obsolete the
+// package name (whatever the version constraint of that declaration),
+// sorted by name and version.
+// FindObsoleting returns an empty list if no package obsoletes name.
+// Excluded packages are left out.
+func (repo *Repository) FindObsoleting(name string) ([]*Package, error) {
+	pkgs, err := repo.Backend.FindObsoleting(name)
+	if err != nil {
+		return nil, err
+	}
+	return repo.withoutExcluded(pkgs), nil
+}
+
+// WhatRequires returns the packages requiring the capability capability,
+// sorted by name and version.
+// If capability is the name of a package of the repository, WhatRequires
+// also returns the packages requiring one of the capabilities provided by a
+// version of that package, provided that version satisfies their requirement.
+// Excluded packages are left out.
+func (repo *Repository) WhatRequires(capability string) ([]*Package, error) {
+	seen := make(map[string]bool)
+	pkgs := make(Packages, 0)
+	add := func(p *Package) {
+		if !seen[p.Key()] && p.Name() != capability && !repo.excluded(p) {
+			seen[p.Key()] = true
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	requiring, err := repo.Backend.FindRequiring(capability)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range requiring {
+		add(p)
+	}
+
+	// a missing package only means capability is not a package name.
+	providers, _ := repo.Backend.FindAllMatchingName(capability)
+	providers = repo.withoutExcluded(providers)
+	names := make(map[string]bool)
+	for _, provider := range providers {
+		for _, prov := range provider.Provides() {
+			if prov.Name() != capability {
+				names[prov.Name()] = true
+			}
+		}
+	}
+
+	for name := range names {
+		requiring, err := repo.Backend.FindRequiring(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range requiring {
+			if satisfiedBy(p, name, providers) {
+				add(p)
+			}
+		}
+	}
+
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// satisfiedBy returns whether one of the requirements of pkg on the
+// capability name is satisfied by one of the providers.
+func satisfiedBy(pkg *Package, name string, providers []*Package) bool {
+	for _, req := range pkg.Requires() {
+		if req.Name() != name {
+			continue
+		}
+		for _, provider := range providers {
+			if provider.Satisfies(req) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // setupBackendFromRemote checks which backend should be used and updates the DB files.
 func (repo *Repository) setupBackendFromRemote() error {
 	repo.msg.Debugf("setupBackendFromRemote...\n")
 	var err error
+	var errs []error // errors of the backends which could not be set up
 	var backend Backend
 
 	// get repo metadata with list of available files
@@ -100,6 +592,11 @@ func (repo *Repository) setupBackendFromRemote() error {
 		return err
 	}
 
+	err = repo.verifyRepoMD(remotedata)
+	if err != nil {
+		return err
+	}
+
 	remotemd, err := repo.checkRepoMD(remotedata)
 	if err != nil {
 		return err
@@ -115,46 +612,70 @@ func (repo *Repository) setupBackendFromRemote() error {
 		return err
 	}
 
-	for _, bname := range repo.Backends {
-		repo.msg.Debugf("checking availability of backend [%s]\n", bname)
-		ba, err := NewBackend(bname, repo)
-		if err != nil {
-			ba.Close()
-			continue
+	// probe the backends concurrently, downloading their DB if needed.
+	ctx, cancel := context.WithCancel(repo.context())
+	probes := make([]*backendProbe, len(repo.Backends))
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for i, bname := range repo.Backends {
+		probe := &backendProbe{name: bname, done: make(chan struct{})}
+		probes[i] = probe
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(probe.done)
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				probe.err = ctx.Err()
+				return
+			}
+			repo.probeBackend(ctx, probe, remotemd, localmd)
+		}()
+	}
+
+	// only the downloads of the chosen backend are committed to the cache.
+	defer func() {
+		cancel()
+		wg.Wait()
+		for _, probe := range probes {
+			if probe.fname != "" {
+				os.RemoveAll(probe.fname)
+			}
 		}
+	}()
 
-		rrepomd, ok := remotemd[ba.YumDataType()]
-		if !ok {
-			repo.msg.Warnf("remote repository does not provide [%s] DB\n", bname)
+	// pick the first backend, in order of preference, which could be loaded.
+	for _, probe := range probes {
+		<-probe.done
+		if err := repo.context().Err(); err != nil {
+			return err
+		}
+		bname := probe.name
+		if probe.err != nil {
+			errs = append(errs, repo.backendError(bname, probe.err))
 			continue
 		}
 
 		// a priori a match
-		backend = ba
+		backend = probe.backend
 		repo.Backend = backend
 
-		lrepomd, ok := localmd[ba.YumDataType()]
-		if !ok {
-			// doesn't matter, we download the DB in any case
-		}
-
-		if !repo.Backend.HasDB() || rrepomd.Timestamp.After(lrepomd.Timestamp) {
-			// we need to update the DB
-			url := repo.RepoUrl + "/" + rrepomd.Location
-			repo.msg.Debugf("updating the RPM database for %s\n", bname)
-			err = repo.Backend.GetLatestDB(url)
+		if probe.fname != "" {
+			err = repo.Backend.GetLatestDB("file://" + probe.fname)
 			if err != nil {
 				repo.msg.Warnf("problem updating RPM database for backend [%s]: %v\n", bname, err)
-				err = nil
+				errs = append(errs, repo.backendError(bname, err))
 				backend = nil
 				repo.Backend = nil
 				continue
 			}
 			// save metadata to local repomd file
-			err = ioutil.WriteFile(repo.LocalRepoMdXml, remotedata, 0644)
+			err = repo.saveMetadata(remotedata)
 			if err != nil {
 				repo.msg.Warnf("problem updating local repomd.xml file for backend [%s]: %v\n", bname, err)
-				err = nil
+				errs = append(errs, repo.backendError(bname, err))
 				backend = nil
 				repo.Backend = nil
 				continue
@@ -165,23 +686,319 @@ func (repo *Repository) setupBackendFromRemote() error {
 		err = repo.Backend.LoadDB()
 		if err != nil {
 			repo.msg.Warnf("problem loading data for backend [%s]: %v\n", bname, err)
-			err = nil
+			errs = append(errs, repo.backendError(bname, err))
+			repo.discardBackend(backend)
 			backend = nil
 			repo.Backend = nil
 			continue
 		}
 
 		// stop at first one found
+		repo.emitLoaded(bname)
+		repo.recordBackend(bname)
 		break
 	}
 
 	if backend == nil {
+		if err := repo.context().Err(); err != nil {
+			return err
+		}
 		repo.msg.Errorf("No valid backend found\n")
-		return fmt.Errorf("No valid backend found")
+		return repo.errNoBackend(errs)
 	}
 
 	repo.msg.Debugf("repository [%s] - chosen backend [%T]\n", repo.Name, repo.Backend)
-	return err
+	return nil
+}
+
+// RefreshDB downloads the DB of the current backend if the remote one is
+// newer, and reloads it.
+// Contrary to SetupBackend, the other backends of repo.Backends are not
+// probed: the repository must have been set up already.
+// The remote repository is not checked if the cached metadata is younger
+// than repo.CacheTTL.
+func (repo *Repository) RefreshDB() error {
+	if repo.Backend == nil {
+		return fmt.Errorf("%w: repository [%s] is not set up", ErrNoBackend, repo.Name)
+	}
+	if repo.Offline {
+		return fmt.Errorf("%w: can not refresh repository [%s]", ErrOffline, repo.Name)
+	}
+	if repo.cacheFresh() {
+		repo.msg.Debugf("repository [%s] - cache is fresh\n", repo.Name)
+		return nil
+	}
+
+	remotedata, err := repo.remoteMetadata()
+	if err != nil {
+		return err
+	}
+
+	err = repo.verifyRepoMD(remotedata)
+	if err != nil {
+		return err
+	}
+
+	remotemd, err := repo.checkRepoMD(remotedata)
+	if err != nil {
+		return err
+	}
+
+	localdata, err := repo.localMetadata()
+	if err != nil {
+		return err
+	}
+
+	localmd, err := repo.checkRepoMD(localdata)
+	if err != nil {
+		return err
+	}
+
+	dbtype := repo.Backend.YumDataType()
+	rmd, ok := remotemd[dbtype]
+	if !ok {
+		return fmt.Errorf("%w: no %s entry in remote repomd.xml", ErrMetadataNotFound, dbtype)
+	}
+
+	if !dbNeedsUpdate(repo.Backend, rmd, localmd[dbtype]) {
+		repo.msg.Debugf("repository [%s] - DB is up-to-date\n", repo.Name)
+		repo.touchMetadata()
+		return nil
+	}
+
+	err = repo.checkDBLocation(dbtype, rmd)
+	if err != nil {
+		return err
+	}
+
+	repo.msg.Debugf("repository [%s] - refreshing the RPM database\n", repo.Name)
+	fname, err := repo.downloadDB(repo.context(), rmd)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(fname)
+
+	err = repo.Backend.GetLatestDB("file://" + fname)
+	if err != nil {
+		return err
+	}
+
+	err = repo.saveMetadata(remotedata)
+	if err != nil {
+		return err
+	}
+
+	repo.files = nil
+	repo.groups = nil
+	repo.deltas = nil
+	repo.advisories = nil
+	err = repo.Backend.LoadDB()
+	if err != nil {
+		return err
+	}
+	repo.emitLoaded(backendName(repo.Backend))
+	repo.touchMetadata()
+	return nil
+}
+
+// maxConcurrentProbes is the maximum number of backends probed concurrently.
+const maxConcurrentProbes = 2
+
+// backendProbe holds the result of probing a backend of a remote repository.
+type backendProbe struct {
+	name    string        // name of the backend
+	backend Backend       // backend, nil if not provided by the repository
+	fname   string        // downloaded DB, empty if the cached one is up-to-date
+	err     error         // error encountered while probing the backend
+	done    chan struct{} // closed when probing is over
+}
+
+// discardBackend closes a backend which could not be loaded and removes its
+// cached DB, so it is downloaded again instead of being trusted next time.
+func (repo *Repository) discardBackend(backend Backend) {
+	type dbFiler interface {
+		dbFile() string
+	}
+
+	err := backend.Close()
+	if err != nil {
+		repo.msg.Debugf("problem closing backend: %v\n", err)
+	}
+	if f, ok := backend.(dbFiler); ok {
+		os.RemoveAll(f.dbFile())
+	}
+}
+
+// probeBackend checks the availability of the backend probe.name in the
+// remote repository and downloads its DB, if the cached one is outdated.
+func (repo *Repository) probeBackend(ctx context.Context, probe *backendProbe, remotemd, localmd map[string]RepoMD) {
+	bname := probe.name
+	repo.msg.Debugf("checking availability of backend [%s]\n", bname)
+	repo.emit(Event{Kind: EventProbeStart, Backend: bname})
+	ba, err := NewBackend(bname, repo)
+	if err != nil {
+		probe.err = err
+		return
+	}
+
+	err = repo.checkDataType(ba.YumDataType())
+	if err != nil {
+		repo.msg.Debugf("skipping backend [%s]: %v\n", bname, err)
+		probe.err = err
+		return
+	}
+
+	rrepomd, ok := remotemd[ba.YumDataType()]
+	if !ok {
+		repo.msg.Warnf("remote repository does not provide [%s] DB\n", bname)
+		probe.err = fmt.Errorf("%w: no %s entry in remote repomd.xml", ErrMetadataNotFound, ba.YumDataType())
+		return
+	}
+
+	// a missing local entry doesn't matter, we download the DB in any case
+	lrepomd := localmd[ba.YumDataType()]
+
+	if dbNeedsUpdate(ba, rrepomd, lrepomd) {
+		err = repo.checkDBLocation(ba.YumDataType(), rrepomd)
+		if err != nil {
+			repo.msg.Warnf("skipping backend [%s]: %v\n", bname, err)
+			probe.err = err
+			return
+		}
+
+		// we need to update the DB
+		repo.msg.Debugf("updating the RPM database for %s\n", bname)
+		fname, err := repo.downloadDB(ctx, rrepomd)
+		if err != nil {
+			if ctx.Err() == nil {
+				repo.msg.Errorf("problem downloading RPM database for backend [%s]: %v\n", bname, err)
+			}
+			probe.err = err
+			return
+		}
+		probe.fname = fname
+	}
+	probe.backend = ba
+}
+
+// checkDBLocation checks that the entry md of type dtype of the remote
+// repomd.xml file locates a DB which can be downloaded.
+func (repo *Repository) checkDBLocation(dtype string, md RepoMD) error {
+	loc := strings.TrimSpace(md.Location)
+	if loc == "" {
+		return fmt.Errorf("%w: %s entry of remote repomd.xml has an empty location", ErrMetadataNotFound, dtype)
+	}
+	ref, err := url.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("yum: invalid location %q of %s entry in remote repomd.xml: %w", loc, dtype, err)
+	}
+	if p := path.Base(ref.Path); ref.Path == "" || p == "." || p == "/" {
+		return fmt.Errorf("yum: invalid location %q of %s entry in remote repomd.xml: no file name", loc, dtype)
+	}
+	_, err = joinURL(repo.RepoUrl, loc)
+	if err != nil {
+		return fmt.Errorf("yum: invalid location %q of %s entry in remote repomd.xml: %w", loc, dtype, err)
+	}
+	return nil
+}
+
+// dbNeedsUpdate returns whether the DB of the backend ba, described by lmd in
+// the local metadata, is missing or older than the remote one described by rmd.
+// The revisions of the metadata are compared when both are known, the
+// timestamps of the entries otherwise: a missing timestamp can not be
+// compared, so the DB is then always considered outdated.
+func dbNeedsUpdate(ba Backend, rmd, lmd RepoMD) bool {
+	if !ba.HasDB() {
+		return true
+	}
+	if rmd.Revision != "" && lmd.Revision != "" {
+		// the revision changes whenever the repository is regenerated:
+		// it is more reliable than the timestamps of the entries.
+		if rmd.Revision != lmd.Revision {
+			return true
+		}
+	} else if rmd.Timestamp.IsZero() || lmd.Timestamp.IsZero() {
+		return true
+	} else if rmd.Timestamp.After(lmd.Timestamp) {
+		return true
+	}
+	return rmd.Checksum != lmd.Checksum || rmd.ChecksumType != lmd.ChecksumType
+}
+
+// parseTimestamp parses a timestamp of a repomd.xml file: a number of seconds
+// since the epoch, as an integer or a float.
+// parseTimestamp returns the zero time.Time for an empty timestamp.
+func parseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	sec := math.Floor(v)
+	return time.Unix(int64(sec), int64((v-sec)*1e9)), nil
+}
+
+// NeedsUpdate returns whether the DBs in the cache directory are missing or
+// outdated with respect to the remote repository.
+// NeedsUpdate only retrieves the remote repomd.xml file: no DB is downloaded.
+// The DB of the current backend is checked if the repository is set up, the
+// DBs of all the backends in repo.Backends otherwise.
+func (repo *Repository) NeedsUpdate() (bool, error) {
+	remotedata, err := repo.remoteMetadata()
+	if err != nil {
+		return false, err
+	}
+
+	remotemd, err := repo.checkRepoMD(remotedata)
+	if err != nil {
+		return false, err
+	}
+
+	localdata, err := repo.localMetadata()
+	if err != nil {
+		return false, err
+	}
+
+	localmd, err := repo.checkRepoMD(localdata)
+	if err != nil {
+		return false, err
+	}
+
+	backends := make([]Backend, 0, len(repo.Backends))
+	if repo.Backend != nil {
+		backends = append(backends, repo.Backend)
+	} else {
+		for _, bname := range repo.Backends {
+			ba, err := NewBackend(bname, repo)
+			if err != nil {
+				continue
+			}
+			backends = append(backends, ba)
+		}
+	}
+
+	found := false
+	for _, ba := range backends {
+		rmd, ok := remotemd[ba.YumDataType()]
+		if !ok {
+			continue
+		}
+		found = true
+		if dbNeedsUpdate(ba, rmd, localmd[ba.YumDataType()]) {
+			return true, nil
+		}
+	}
+
+	if !found {
+		return false, fmt.Errorf("%w: no DB for the backends of repository [%s] in remote repomd.xml", ErrMetadataNotFound, repo.Name)
+	}
+	return false, nil
 }
 
 func (repo *Repository) setupBackendFromLocal() error {
@@ -192,21 +1009,36 @@ func (repo *Repository) setupBackendFromLocal() error {
 		return err
 	}
 
+	if len(data) <= 0 {
+		if repo.Offline {
+			return fmt.Errorf("%w: %w: missing cache file [%s]", ErrOffline, ErrNoLocalCache, repo.LocalRepoMdXml)
+		}
+		return fmt.Errorf("%w: missing cache file [%s]", ErrNoLocalCache, repo.LocalRepoMdXml)
+	}
+
 	md, err := repo.checkRepoMD(data)
 	if err != nil {
 		return err
 	}
 
 	var backend Backend
-	for _, bname := range repo.Backends {
+	var errs []error // errors of the backends which could not be set up
+	for _, bname := range repo.localBackends() {
 		repo.msg.Debugf("checking availability of backend [%s]\n", bname)
 		ba, err := NewBackend(bname, repo)
 		if err != nil {
+			errs = append(errs, repo.backendError(bname, err))
+			continue
+		}
+		err = repo.checkDataType(ba.YumDataType())
+		if err != nil {
+			errs = append(errs, repo.backendError(bname, err))
 			continue
 		}
 		_ /*repomd*/, ok := md[ba.YumDataType()]
 		if !ok {
 			repo.msg.Warnf("local repository does not provide [%s] DB\n", bname)
+			errs = append(errs, fmt.Errorf("backend [%s]: %w: no %s entry in local repomd.xml", bname, ErrMetadataNotFound, ba.YumDataType()))
 			continue
 		}
 
@@ -218,39 +1050,289 @@ func (repo *Repository) setupBackendFromLocal() error {
 		err = repo.Backend.LoadDB()
 		if err != nil {
 			repo.msg.Warnf("problem loading data for backend [%s]: %v\n", bname, err)
-			err = nil
+			errs = append(errs, repo.backendError(bname, err))
 			backend = nil
 			repo.Backend = nil
 			continue
 		}
 
 		// stop at first one found.
+		repo.emitLoaded(bname)
+		repo.recordBackend(bname)
 		break
 	}
 
 	if backend == nil {
 		repo.msg.Errorf("No valid backend found\n")
-		return fmt.Errorf("No valid backend found")
+		return repo.errNoBackend(errs)
 	}
 
 	repo.msg.Debugf("repository [%s] - chosen backend [%T]\n", repo.Name, repo.Backend)
-	return err
+	return nil
+}
+
+// errNoBackend returns an ErrNoBackend error, wrapping the errors
+// encountered while setting up each of the backends, if any.
+func (repo *Repository) errNoBackend(errs []error) error {
+	if len(errs) == 0 {
+		return fmt.Errorf("%w for repository [%s]", ErrNoBackend, repo.Name)
+	}
+	return fmt.Errorf("%w for repository [%s]: %w", ErrNoBackend, repo.Name, errors.Join(errs...))
 }
 
-// remoteMetadata retrieves the repo metadata file content
+// checkDataType returns an error wrapping ErrMetadataNotFound if the data
+// type dtype is left out by repo.WantedDataTypes.
+func (repo *Repository) checkDataType(dtype string) error {
+	if len(repo.WantedDataTypes) == 0 || str_in_slice(dtype, repo.WantedDataTypes) {
+		return nil
+	}
+	return fmt.Errorf("%w: data type %s not wanted for repository [%s]", ErrMetadataNotFound, dtype, repo.Name)
+}
+
+// remoteMetadata retrieves the repo metadata file content.
+// Mirrors are tried in turn until one of them serves a valid repomd.xml file.
+// That mirror then becomes the base URL of the repository.
 func (repo *Repository) remoteMetadata() ([]byte, error) {
-	r, err := getRemoteData(repo.RepoMdUrl)
+	var err error
+	for _, mirror := range repo.mirrors() {
+		var mdurl string
+		var data []byte
+		mdurl, err = repo.repoDataURL(mirror, "repomd.xml")
+		if err == nil {
+			data, err = repo.remoteMetadataFrom(mdurl)
+		}
+		if err == nil {
+			_, err = repo.checkRepoMD(data)
+		}
+		if err != nil {
+			if repo.context().Err() != nil {
+				return nil, repo.context().Err()
+			}
+			repo.msg.Warnf("could not retrieve metadata from mirror [%s]: %v\n", redact(mirror), err)
+			continue
+		}
+		if mirror != repo.RepoUrl {
+			repo.msg.Infof("repository [%s] - using mirror [%s]\n", repo.Name, redact(mirror))
+			repo.RepoUrl = mirror
+		}
+		repo.RepoMdUrl = mdurl
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: [%s]: %w", ErrMetadataNotFound, redact(repo.RepoMdUrl), err)
+}
+
+// repoDataURL returns the URL of the file fname of the metadata directory
+// of the repository at base.
+func (repo *Repository) repoDataURL(base, fname string) (string, error) {
+	dir := strings.Trim(repo.RepoDataPath, "/")
+	if dir == "" {
+		dir = DefaultRepoDataPath
+	}
+	return joinURL(base, dir+"/"+fname)
+}
+
+// SetRepoDataPath sets the path to the metadata directory of the repository,
+// relative to its base URL, for repositories not following the conventional
+// layout (e.g. "metadata" or "repo/x86_64/repodata").
+// An empty path restores the default, DefaultRepoDataPath.
+func (repo *Repository) SetRepoDataPath(dir string) error {
+	if dir == "" {
+		dir = DefaultRepoDataPath
+	}
+	ref, err := url.Parse(dir)
+	if err != nil || ref.IsAbs() || ref.Host != "" || ref.RawQuery != "" {
+		return fmt.Errorf("yum: invalid repodata path %q for repository [%s]", dir, repo.Name)
+	}
+	old := repo.RepoDataPath
+	repo.RepoDataPath = dir
+	mdurl, err := repo.repoDataURL(repo.RepoUrl, "repomd.xml")
+	if err != nil {
+		repo.RepoDataPath = old
+		return fmt.Errorf("yum: invalid repodata path %q for repository [%s]: %w", dir, repo.Name, err)
+	}
+	repo.RepoMdUrl = mdurl
+	return nil
+}
+
+// remoteMetadataFrom retrieves the repo metadata file content from mdurl.
+// If the cached repomd.xml file is still current, the remote content is not
+// transferred again and the cached content is returned instead.
+func (repo *Repository) remoteMetadataFrom(mdurl string) ([]byte, error) {
+	r, err := repo.openRemoteData(repo.context(), mdurl, repo.metadataConditions(mdurl))
+	if err == errNotModified {
+		repo.msg.Debugf("repository [%s] - metadata [%s] not modified\n", repo.Name, redact(mdurl))
+		repo.mdetag = ""
+		etagurl, etag := repo.metadataETag()
+		if etagurl == mdurl {
+			repo.mdetag = etag
+		}
+		return repo.localMetadata()
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
 
+	data, err := repo.readMetadata(r, mdurl)
+	if err != nil {
+		return nil, err
+	}
+	repo.mdetag = r.etag
+	return data, nil
+}
+
+// readMetadata reads the repo metadata retrieved from mdurl, enforcing
+// repo.MaxMetadataBytes.
+func (repo *Repository) readMetadata(r io.Reader, mdurl string) ([]byte, error) {
+	if repo.MaxMetadataBytes > 0 {
+		r = io.LimitReader(r, repo.MaxMetadataBytes+1)
+	}
 	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, r)
+	_, err := io.Copy(buf, r)
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
-	return buf.Bytes(), err
+	if repo.MaxMetadataBytes > 0 && int64(buf.Len()) > repo.MaxMetadataBytes {
+		return nil, fmt.Errorf("yum: metadata [%s] exceeds %d bytes", redact(mdurl), repo.MaxMetadataBytes)
+	}
+	return buf.Bytes(), nil
+}
+
+// metadataConditions returns the headers of a conditional request for the
+// remote repo metadata at mdurl, from the cached repomd.xml file.
+func (repo *Repository) metadataConditions(mdurl string) http.Header {
+	fi, err := os.Stat(repo.LocalRepoMdXml)
+	if err != nil {
+		return nil
+	}
+
+	header := make(http.Header)
+	header.Set("If-Modified-Since", fi.ModTime().UTC().Format(http.TimeFormat))
+	if etagurl, etag := repo.metadataETag(); etagurl == mdurl && etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	return header
+}
+
+// metadataETag returns the URL and ETag of the cached repomd.xml file.
+func (repo *Repository) metadataETag() (string, string) {
+	data, err := ioutil.ReadFile(repo.LocalRepoMdXml + ".etag")
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+	if len(lines) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1])
+}
+
+// saveMetadata writes the repo metadata data into the cached repomd.xml file,
+// together with the ETag it was served with.
+func (repo *Repository) saveMetadata(data []byte) error {
+	err := write_file_atomic(repo.LocalRepoMdXml, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	fname := repo.LocalRepoMdXml + ".etag"
+	if repo.mdetag == "" {
+		if path_exists(fname) {
+			return os.Remove(fname)
+		}
+		return nil
+	}
+	return write_file_atomic(fname, func(w io.Writer) error {
+		_, err := io.WriteString(w, repo.RepoMdUrl+"\n"+repo.mdetag+"\n")
+		return err
+	})
+}
+
+// downloadDB downloads the DB file described by md into the cache directory and
+// verifies it against the checksum advertised in the repository metadata.
+// Mirrors are tried in turn until one of them serves a valid file.
+// downloadDB returns the absolute path to the downloaded file.
+func (repo *Repository) downloadDB(ctx context.Context, md RepoMD) (string, error) {
+	var err error
+	for _, mirror := range repo.mirrors() {
+		var fname string
+		var url string
+		url, err = joinURL(mirror, md.Location)
+		if err == nil {
+			fname, err = repo.downloadDBFrom(ctx, url, md)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			repo.msg.Warnf("could not download DB from mirror [%s]: %v\n", redact(mirror), err)
+			continue
+		}
+		return fname, nil
+	}
+	return "", err
+}
+
+func (repo *Repository) downloadDBFrom(ctx context.Context, url string, md RepoMD) (string, error) {
+	dir, err := filepath.Abs(repo.CacheDir)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(dir, "download-*-"+path.Base(md.Location))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fname := f.Name()
+
+	repo.emit(Event{Kind: EventDownloadStart, URL: redact(url)})
+	r, err := repo.download(ctx, url)
+	if err != nil {
+		os.RemoveAll(fname)
+		return "", err
+	}
+	defer r.Close()
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		os.RemoveAll(fname)
+		return "", err
+	}
+	repo.emit(Event{Kind: EventDownloadDone, URL: redact(url), Bytes: n})
+
+	err = f.Close()
+	if err != nil {
+		os.RemoveAll(fname)
+		return "", err
+	}
+
+	err = VerifyFile(fname, md.ChecksumType, md.Checksum)
+	if err != nil {
+		os.RemoveAll(fname)
+		return "", fmt.Errorf("yum: could not verify [%s]: %w", redact(url), err)
+	}
+
+	if md.OpenChecksum != "" {
+		sum, err := checksumDecompressedFile(fname, md.OpenChecksumType)
+		if err != nil {
+			os.RemoveAll(fname)
+			return "", err
+		}
+
+		if sum != md.OpenChecksum {
+			os.RemoveAll(fname)
+			return "", fmt.Errorf(
+				"%w for uncompressed [%s] (type=%s): expected=%s got=%s",
+				ErrChecksumMismatch, redact(url), md.OpenChecksumType, md.OpenChecksum, sum,
+			)
+		}
+	}
+
+	return fname, nil
 }
 
 // localMetadata retrieves the repo metadata from the repomd file
@@ -272,7 +1354,9 @@ func (repo *Repository) localMetadata() ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-// checkRepoMD parses the Repository metadata XML content
+// checkRepoMD parses the Repository metadata XML content.
+// Elements and attributes are matched on their local names, so that the
+// (default or prefixed) namespace declared by the file does not matter.
 func (repo *Repository) checkRepoMD(data []byte) (map[string]RepoMD, error) {
 
 	if len(data) <= 0 {
@@ -280,41 +1364,137 @@ func (repo *Repository) checkRepoMD(data []byte) (map[string]RepoMD, error) {
 		return nil, nil
 	}
 
-	type xmlTree struct {
-		XMLName xml.Name `xml:"repomd"`
-		Data    []struct {
-			Type     string `xml:"type,attr"`
-			Checksum string `xml:"checksum"`
-			Location struct {
-				Href string `xml:"href,attr"`
-			} `xml:"location"`
-			Timestamp float64 `xml:"timestamp"`
-		} `xml:"data"`
-	}
-
-	var tree xmlTree
-	err := xml.Unmarshal(data, &tree)
-	if err != nil {
-		return nil, err
+	type xmlData struct {
+		Type     string `xml:"type,attr"`
+		Checksum struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"checksum"`
+		OpenChecksum struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"open-checksum"`
+		Location struct {
+			Href      string `xml:"href,attr"`
+			Timestamp string `xml:"timestamp,attr"`
+		} `xml:"location"`
+		Timestamp     string `xml:"timestamp"`
+		TimestampAttr string `xml:"timestamp,attr"`
+		Size          int64  `xml:"size"`
+		OpenSize      int64  `xml:"open-size"`
+		Packages      int64  `xml:"packages"`
 	}
 
 	db := make(map[string]RepoMD)
-	for _, data := range tree.Data {
-		sec := int64(math.Floor(data.Timestamp))
-		nsec := int64((data.Timestamp - float64(sec)) * 1e9)
-		db[data.Type] = RepoMD{
-			Checksum:  data.Checksum,
-			Timestamp: time.Unix(sec, nsec),
-			Location:  data.Location.Href,
+	var malformed []string
+	var revision string
+
+	// decode the <data> entries one at a time, stopping at the end of the
+	// <repomd> element: trailing content is ignored.
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+loop:
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if depth == 0 {
+				return nil, fmt.Errorf("yum: no repomd element in repository metadata")
+			}
+			return nil, io.ErrUnexpectedEOF
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case depth == 0 && tok.Name.Local != "repomd":
+				return nil, fmt.Errorf("yum: expected element type <repomd> but have <%s>", tok.Name.Local)
+			case depth == 1 && tok.Name.Local == "revision":
+				err = dec.DecodeElement(&revision, &tok)
+				if err != nil {
+					return nil, err
+				}
+				continue loop
+			case depth == 1 && tok.Name.Local == "data":
+				var entry xmlData
+				err = dec.DecodeElement(&entry, &tok)
+				if err != nil {
+					return nil, err
+				}
+				if entry.Location.Href == "" || strings.TrimSpace(entry.Checksum.Value) == "" {
+					malformed = append(malformed, entry.Type)
+					continue loop
+				}
+				// the timestamp is usually an element, but some generators
+				// put it in an attribute of the entry or of its location.
+				stamp := entry.Timestamp
+				for _, s := range []string{entry.TimestampAttr, entry.Location.Timestamp} {
+					if strings.TrimSpace(stamp) == "" {
+						stamp = s
+					}
+				}
+				timestamp, err := parseTimestamp(stamp)
+				if err != nil {
+					return nil, fmt.Errorf("yum: invalid timestamp of %q entry: %w", entry.Type, err)
+				}
+				md := RepoMD{
+					Checksum:         strings.TrimSpace(entry.Checksum.Value),
+					ChecksumType:     entry.Checksum.Type,
+					OpenChecksum:     strings.TrimSpace(entry.OpenChecksum.Value),
+					OpenChecksumType: entry.OpenChecksum.Type,
+					Timestamp:        timestamp,
+					Location:         entry.Location.Href,
+					Size:             entry.Size,
+					OpenSize:         entry.OpenSize,
+					Packages:         entry.Packages,
+				}
+				// some repositories list the same data type more than once:
+				// keep the newest entry.
+				if old, dup := db[entry.Type]; dup && !md.Timestamp.After(old.Timestamp) {
+					repo.msg.Debugf("checkRepoMD: ignoring older duplicate %q entry [%s]\n", entry.Type, md.Location)
+					continue loop
+				}
+				repo.msg.Debugf("checkRepoMD: %q entry [%s]\n", entry.Type, md.Location)
+				db[entry.Type] = md
+				continue loop
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				break loop
+			}
+		}
+	}
+
+	// the revision may appear after the <data> entries.
+	revision = strings.TrimSpace(revision)
+	for dtype, md := range db {
+		md.Revision = revision
+		db[dtype] = md
+	}
+
+	if len(malformed) > 0 {
+		repo.msg.Warnf("repository [%s] - skipped malformed repomd.xml entries (missing location or checksum): %v\n",
+			repo.Name, malformed,
+		)
 	}
-	return db, err
+	return db, nil
 }
 
 type RepoMD struct {
-	Checksum  string
-	Timestamp time.Time
-	Location  string
+	Checksum         string
+	ChecksumType     string // type of checksum (sha, sha256, md5)
+	OpenChecksum     string // checksum of the uncompressed file, empty if unknown
+	OpenChecksumType string // type of the checksum of the uncompressed file
+	Timestamp        time.Time
+	Location         string
+	Size             int64  // size of the (compressed) file, 0 if unknown
+	OpenSize         int64  // size of the uncompressed file, 0 if unknown
+	Packages         int64  // number of packages described by the file, 0 if unknown
+	Revision         string // revision of the repository metadata, empty if unknown
 }
 
 // EOF
@@ -0,0 +1,2546 @@
+package yum
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRemote creates a file-based YUM repository under a new temporary
+// directory, populated with the metadata files of the cache directory src.
+// newTestRemote returns the temporary directory.
+func newTestRemote(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+
+	repodata := filepath.Join(dir, "repodata")
+	err = os.MkdirAll(repodata, 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+
+	fis, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatalf("could not read [%s]: %v\n", src, err)
+	}
+
+	for _, fi := range fis {
+		err = copyTestFile(filepath.Join(repodata, fi.Name()), filepath.Join(src, fi.Name()))
+		if err != nil {
+			t.Fatalf("could not create remote repo: %v\n", err)
+		}
+	}
+	return dir
+}
+
+func copyTestFile(dst, src string) error {
+	fsrc, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fsrc.Close()
+
+	fdst, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer fdst.Close()
+
+	_, err = io.Copy(fdst, fsrc)
+	if err != nil {
+		return err
+	}
+	return fdst.Close()
+}
+
+func TestRepositoryFromRemote(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	if !path_exists(repo.LocalRepoMdXml) {
+		t.Fatalf("expected local repomd.xml file [%s]\n", repo.LocalRepoMdXml)
+	}
+
+	if len(repo.GetPackages()) <= 0 {
+		t.Fatalf("expected some packages\n")
+	}
+}
+
+func TestRepositoryChecksumMismatch(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// corrupt the primary DB
+	err := ioutil.WriteFile(filepath.Join(remote, "repodata", "primary.xml.gz"), []byte("corrupted"), 0644)
+	if err != nil {
+		t.Fatalf("could not corrupt remote DB: %v\n", err)
+	}
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	_, err = NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err == nil {
+		t.Fatalf("expected an error on checksum mismatch\n")
+	}
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+	if !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("expected ErrNoBackend. got=%v\n", err)
+	}
+
+	if path_exists(filepath.Join(cachedir, "repomd.xml")) {
+		t.Fatalf("local repomd.xml should not have been updated\n")
+	}
+}
+
+func TestRepositoryBackendErrors(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// corrupt the primary DB
+	err := ioutil.WriteFile(filepath.Join(remote, "repodata", "primary.xml.gz"), []byte("corrupted"), 0644)
+	if err != nil {
+		t.Fatalf("could not corrupt remote DB: %v\n", err)
+	}
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	// the remote repository does not provide any primary_db.
+	_, err = NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositorySQLiteBackend", "RepositoryXMLBackend", "nosuchbackend"},
+		true, true,
+	)
+	if err == nil {
+		t.Fatalf("expected an error\n")
+	}
+	for _, want := range []error{ErrNoBackend, ErrMetadataNotFound, ErrChecksumMismatch} {
+		if !errors.Is(err, want) {
+			t.Fatalf("expected %v. got=%v\n", want, err)
+		}
+	}
+	for _, bname := range []string{"RepositorySQLiteBackend", "RepositoryXMLBackend", "nosuchbackend"} {
+		if !strings.Contains(err.Error(), "backend ["+bname+"]") {
+			t.Fatalf("expected error of backend [%s]. got=%v\n", bname, err)
+		}
+	}
+}
+
+func TestRemoteRetries(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	const content = "<repomd></repomd>"
+	nreqs := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nreqs++
+		if nreqs < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	repo.Retries = 1
+	_, err = repo.remoteMetadata()
+	if err == nil {
+		t.Fatalf("expected an error after exhausting retries\n")
+	}
+
+	nreqs = 0
+	repo.Retries = 2
+	data, err := repo.remoteMetadata()
+	if err != nil {
+		t.Fatalf("could not retrieve remote metadata: %v\n", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected %q. got=%q\n", content, string(data))
+	}
+}
+
+func TestRemoteHTTPError(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	code := http.StatusNotFound
+	nreqs := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nreqs++
+		http.Error(w, "oops", code)
+	}))
+	defer srv.Close()
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Retries = 1
+
+	for _, test := range []struct {
+		code  int
+		nreqs int
+	}{
+		{http.StatusNotFound, 1},
+		{http.StatusForbidden, 1},
+		{http.StatusInternalServerError, 2}, // retried
+	} {
+		code = test.code
+		nreqs = 0
+		_, err = repo.remoteMetadata()
+		if !errors.Is(err, ErrMetadataNotFound) {
+			t.Fatalf("%d: expected ErrMetadataNotFound. got=%v\n", test.code, err)
+		}
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			t.Fatalf("%d: expected an *HTTPError. got=%v\n", test.code, err)
+		}
+		if herr.StatusCode != test.code {
+			t.Fatalf("%d: invalid status code. got=%d\n", test.code, herr.StatusCode)
+		}
+		if want := "yum: GET " + srv.URL + "/repodata/repomd.xml: " + herr.Status; herr.Error() != want {
+			t.Fatalf("%d: invalid error message.\ngot= %q\nwant=%q\n", test.code, herr.Error(), want)
+		}
+		if nreqs != test.nreqs {
+			t.Fatalf("%d: invalid number of requests. got=%d. want=%d\n", test.code, nreqs, test.nreqs)
+		}
+	}
+}
+
+func TestRemoteStalledBody(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	content := strings.Repeat("0123456789", 1000)
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		io.WriteString(w, content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		// stop writing.
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Timeout = 100 * time.Millisecond
+	repo.Retries = 1
+
+	r, err := repo.getRemoteData(srv.URL + "/data")
+	if err != nil {
+		t.Fatalf("could not open remote data: %v\n", err)
+	}
+	defer r.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(r)
+		errc <- err
+	}()
+	select {
+	case err = <-errc:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("reading a stalled body did not time out\n")
+	}
+	if err == nil || !strings.Contains(err.Error(), "no data received") {
+		t.Fatalf("expected an idle timeout error. got=%v\n", err)
+	}
+}
+
+func TestRemoteResumeBody(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	content := strings.Repeat("0123456789", 1000)
+	for _, test := range []struct {
+		name  string
+		stall bool // stall instead of closing the connection
+	}{
+		{"reset", false},
+		{"stall", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			done := make(chan struct{})
+			defer close(done)
+			var ranges []string
+			var mu sync.Mutex
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				n := len(ranges)
+				mu.Unlock()
+				if n > 1 {
+					w.Header().Set("ETag", `"v1"`)
+					http.ServeContent(w, r, "data", time.Time{}, strings.NewReader(content))
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				io.WriteString(w, content[:len(content)/3])
+				w.(http.Flusher).Flush()
+				if test.stall {
+					select {
+					case <-r.Context().Done():
+					case <-done:
+					}
+					return
+				}
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("could not hijack connection: %v\n", err)
+					return
+				}
+				conn.Close()
+			}))
+			defer srv.Close()
+
+			repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+				[]string{"RepositoryXMLBackend"},
+				false, false,
+			)
+			if err != nil {
+				t.Fatalf("could not create repository: %v\n", err)
+			}
+			repo.Timeout = 100 * time.Millisecond
+			repo.Retries = 1
+
+			r, err := repo.getRemoteData(srv.URL + "/data")
+			if err != nil {
+				t.Fatalf("could not open remote data: %v\n", err)
+			}
+			defer r.Close()
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("could not read remote data: %v\n", err)
+			}
+			if string(got) != content {
+				t.Fatalf("invalid content. got %d bytes. want %d\n", len(got), len(content))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			want := []string{"", fmt.Sprintf("bytes=%d-", len(content)/3)}
+			if !reflect.DeepEqual(ranges, want) {
+				t.Fatalf("invalid requests.\ngot= %q\nwant=%q\n", ranges, want)
+			}
+		})
+	}
+}
+
+func TestRemoteMaxMetadataBytes(t *testing.T) {
+	const content = "<repomd></repomd>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repodata/repomd.xml":
+			http.Redirect(w, r, "/cdn/repomd.xml", http.StatusFound)
+		case "/cdn/repomd.xml":
+			io.WriteString(w, content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Retries = 0
+
+	for _, max := range []int64{0, int64(len(content))} {
+		repo.MaxMetadataBytes = max
+		data, err := repo.remoteMetadata()
+		if err != nil {
+			t.Fatalf("max=%d: could not retrieve remote metadata: %v\n", max, err)
+		}
+		if string(data) != content {
+			t.Fatalf("max=%d: expected %q. got=%q\n", max, content, string(data))
+		}
+	}
+
+	repo.MaxMetadataBytes = int64(len(content)) - 1
+	_, err = repo.remoteMetadata()
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected the metadata to be too large. got=%v\n", err)
+	}
+}
+
+func TestRepositoryProxy(t *testing.T) {
+	const content = "<repomd></repomd>"
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		io.WriteString(w, content)
+	}))
+	defer proxy.Close()
+
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	err = repo.SetProxy("dummy-proxy")
+	if err == nil {
+		t.Fatalf("expected an error for an invalid proxy URL\n")
+	}
+
+	err = repo.SetProxy(proxy.URL)
+	if err != nil {
+		t.Fatalf("could not set proxy: %v\n", err)
+	}
+
+	data, err := repo.remoteMetadata()
+	if err != nil {
+		t.Fatalf("could not retrieve remote metadata: %v\n", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected %q. got=%q\n", content, string(data))
+	}
+	if proxied != repo.RepoMdUrl {
+		t.Fatalf("expected request for %q through proxy. got=%q\n", repo.RepoMdUrl, proxied)
+	}
+}
+
+func TestRepositoryMirrors(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	mirrorlist := filepath.Join(cachedir, "mirrorlist")
+	err = ioutil.WriteFile(
+		mirrorlist,
+		[]byte("# list of mirrors\nfile://"+remote+"/no-such-mirror\n\nfile://"+remote+"\n"),
+		0644,
+	)
+	if err != nil {
+		t.Fatalf("could not create mirrorlist: %v\n", err)
+	}
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote+"/dead-mirror", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	err = repo.SetMirrorList("file://" + mirrorlist)
+	if err != nil {
+		t.Fatalf("could not set mirrorlist: %v\n", err)
+	}
+	if len(repo.Mirrors) != 2 {
+		t.Fatalf("expected 2 mirrors. got=%d (%v)\n", len(repo.Mirrors), repo.Mirrors)
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend: %v\n", err)
+	}
+	defer repo.Close()
+
+	if repo.RepoUrl != "file://"+remote {
+		t.Fatalf("expected mirror %q to be chosen. got=%q\n", "file://"+remote, repo.RepoUrl)
+	}
+}
+
+func TestRepositoryIndex(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	index := repo.Backend.(*RepositoryXMLBackend).Index
+	if !path_exists(index) {
+		t.Fatalf("expected index file [%s]\n", index)
+	}
+
+	// without the DB, the repository can only be loaded from the index.
+	err = os.Remove(repo.Backend.(*RepositoryXMLBackend).Primary)
+	if err != nil {
+		t.Fatalf("could not remove DB: %v\n", err)
+	}
+
+	cached, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository from index: %v\n", err)
+	}
+	defer cached.Close()
+
+	if n, exp := len(cached.GetPackages()), len(repo.GetPackages()); n != exp {
+		t.Fatalf("expected %d packages from index. got=%d\n", exp, n)
+	}
+
+	pkg := repo.GetPackages()[0]
+	p, err := cached.FindLatestMatchingName(pkg.Name(), pkg.Version(), pkg.Release())
+	if err != nil {
+		t.Fatalf("could not find package %s from index: %v\n", pkg.ID(), err)
+	}
+	got, _ := p.Info()
+	want, _ := pkg.Info()
+	if got != want {
+		t.Fatalf("invalid info of package %s from index.\ngot= %#v\nwant=%#v\n", pkg.ID(), got, want)
+	}
+
+	err = cached.RebuildIndex()
+	if err == nil {
+		t.Fatalf("expected an error rebuilding the index without DB\n")
+	}
+	if path_exists(index) {
+		t.Fatalf("expected index file [%s] to be removed\n", index)
+	}
+}
+
+func TestRepositoryOffline(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Offline = true
+
+	_, err = repo.remoteMetadata()
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+
+	err = repo.SetupBackend(true)
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+	if !errors.Is(err, ErrNoLocalCache) || !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrNoLocalCache. got=%v\n", err)
+	}
+
+	repo.Offline = false
+	err = repo.SetupBackend(false)
+	if !errors.Is(err, ErrNoLocalCache) || errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrNoLocalCache. got=%v\n", err)
+	}
+	if !strings.Contains(err.Error(), "run with update enabled") {
+		t.Fatalf("expected a hint to update the cache. got=%v\n", err)
+	}
+	repo.Offline = true
+
+	// a pre-seeded cache is all an offline repository needs.
+	err = copyTestFile(
+		filepath.Join(cachedir, "repomd.xml"),
+		"testdata/testconfig-xml/var/cache/lbyum/lcg/repomd.xml",
+	)
+	if err != nil {
+		t.Fatalf("could not seed cache: %v\n", err)
+	}
+	err = copyTestFile(
+		filepath.Join(cachedir, "primary.xml.gz"),
+		"testdata/testconfig-xml/var/cache/lbyum/lcg/primary.xml.gz",
+	)
+	if err != nil {
+		t.Fatalf("could not seed cache: %v\n", err)
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup offline repository: %v\n", err)
+	}
+	defer repo.Close()
+}
+
+func TestRepositorySignature(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skipf("no gpg command available: %v\n", err)
+	}
+
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// generate a signing key and sign the remote repomd.xml with it.
+	home := filepath.Join(remote, "gnupg")
+	err := os.Mkdir(home, 0700)
+	if err != nil {
+		t.Fatalf("could not create gpg homedir: %v\n", err)
+	}
+	gpg := func(args ...string) []byte {
+		args = append([]string{"--homedir", home, "--batch", "--quiet"}, args...)
+		out, err := exec.Command("gpg", args...).Output()
+		if err != nil {
+			t.Fatalf("gpg %v: %v\n", args, err)
+		}
+		return out
+	}
+	gpg("--passphrase", "", "--pinentry-mode", "loopback",
+		"--quick-gen-key", "lbpkr test <test@example.org>", "ed25519", "sign", "never",
+	)
+	repomd := filepath.Join(remote, "repodata", "repomd.xml")
+	gpg("--armor", "--detach-sign", "-o", repomd+".asc", repomd)
+	key := string(gpg("--armor", "--export"))
+	defer exec.Command("gpgconf", "--homedir", home, "--kill", "all").Run()
+
+	setup := func(key string) error {
+		cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+		if err != nil {
+			t.Fatalf("could not create cachedir: %v\n", err)
+		}
+		defer os.RemoveAll(cachedir)
+
+		repo, err := NewRepository(
+			"lcg", "file://"+remote, cachedir,
+			[]string{"RepositoryXMLBackend"},
+			false, true,
+		)
+		if err != nil {
+			t.Fatalf("could not create repository: %v\n", err)
+		}
+		repo.GPGKey = key
+		err = repo.SetupBackend(true)
+		if err != nil {
+			return err
+		}
+		return repo.Close()
+	}
+
+	// armored key
+	err = setup(key)
+	if err != nil {
+		t.Fatalf("expected a valid signature: %v\n", err)
+	}
+
+	// key file
+	keyfile := filepath.Join(remote, "key.asc")
+	err = ioutil.WriteFile(keyfile, []byte(key), 0644)
+	if err != nil {
+		t.Fatalf("could not write key file: %v\n", err)
+	}
+	err = setup(keyfile)
+	if err != nil {
+		t.Fatalf("expected a valid signature: %v\n", err)
+	}
+
+	// tampered metadata
+	f, err := os.OpenFile(repomd, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("could not open repomd.xml: %v\n", err)
+	}
+	_, err = f.Write([]byte("\n<!-- tampered -->\n"))
+	f.Close()
+	if err != nil {
+		t.Fatalf("could not tamper repomd.xml: %v\n", err)
+	}
+	err = setup(keyfile)
+	if !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature. got=%v\n", err)
+	}
+
+	// missing signature
+	err = os.Remove(repomd + ".asc")
+	if err != nil {
+		t.Fatalf("could not remove signature: %v\n", err)
+	}
+	err = setup(keyfile)
+	if !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature. got=%v\n", err)
+	}
+
+	// no key: verification is skipped
+	err = setup("")
+	if err != nil {
+		t.Fatalf("expected no error without a GPG key: %v\n", err)
+	}
+}
+
+func TestRepositoryNestedCacheDir(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	cachedir := filepath.Join(tmpdir, "var", "cache", "lbyum", "lcg")
+	repo, err := NewRepository("lcg", "http://dummy-url.org", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	fi, err := os.Stat(repo.CacheDir)
+	if err != nil {
+		t.Fatalf("could not stat cachedir: %v\n", err)
+	}
+	if !fi.IsDir() {
+		t.Fatalf("expected [%s] to be a directory\n", repo.CacheDir)
+	}
+
+	fname := filepath.Join(repo.CacheDir, "repomd.xml")
+	err = ioutil.WriteFile(fname, []byte("<repomd/>\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write into cachedir: %v\n", err)
+	}
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read from cachedir: %v\n", err)
+	}
+	if string(data) != "<repomd/>\n" {
+		t.Fatalf("unexpected content: %q\n", string(data))
+	}
+}
+
+func TestRepositoryContext(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) == "repomd.xml" {
+			http.ServeFile(w, r, filepath.Join(remote, "repodata", "repomd.xml"))
+			return
+		}
+		// send a partial DB and stall until the client goes away.
+		w.Write([]byte("partial content"))
+		w.(http.Flusher).Flush()
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	errc := make(chan error)
+	go func() {
+		_, err := NewRepositoryContext(
+			ctx, "lcg", srv.URL, cachedir,
+			[]string{"RepositoryXMLBackend"},
+			true, true,
+		)
+		errc <- err
+	}()
+
+	select {
+	case err = <-errc:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("cancelled repository setup did not return\n")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled. got=%v\n", err)
+	}
+
+	fis, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		t.Fatalf("could not read cachedir: %v\n", err)
+	}
+	for _, fi := range fis {
+		t.Errorf("unexpected file in cachedir: %s\n", fi.Name())
+	}
+
+	// an already cancelled context stops before any request.
+	_, err = NewRepositoryContext(
+		ctx, "lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled. got=%v\n", err)
+	}
+}
+
+func TestRepositoryMetadataNotFound(t *testing.T) {
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	_, err = NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the underlying cause to be wrapped. got=%v\n", err)
+	}
+
+	_, err = NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, false,
+	)
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+}
+
+func TestRepositoryProgress(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	fi, err := os.Stat(filepath.Join(remote, "repodata", "primary.xml.gz"))
+	if err != nil {
+		t.Fatalf("could not stat remote DB: %v\n", err)
+	}
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(remote)))
+	defer srv.Close()
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", srv.URL, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	ncalls := 0
+	read, total := int64(0), int64(0)
+	repo.Progress = func(n, tot int64) {
+		if n < read {
+			t.Errorf("progress went backwards: %d -> %d\n", read, n)
+		}
+		ncalls++
+		read, total = n, tot
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	if ncalls <= 0 {
+		t.Fatalf("expected progress to be reported\n")
+	}
+	if read != fi.Size() {
+		t.Fatalf("invalid number of bytes read. got=%d. want=%d\n", read, fi.Size())
+	}
+	if total != fi.Size() {
+		t.Fatalf("invalid total number of bytes. got=%d. want=%d\n", total, fi.Size())
+	}
+}
+
+func TestCheckRepoMDNamespaces(t *testing.T) {
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer os.RemoveAll("testdata/cachedir.tmp")
+
+	data, err := ioutil.ReadFile("testdata/repomd-ns.xml")
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		t.Fatalf("could not parse namespaced repomd.xml: %v\n", err)
+	}
+	if len(md) != 2 {
+		t.Fatalf("expected 2 entries. got=%d (%v)\n", len(md), md)
+	}
+
+	primary := md["primary"]
+	want := RepoMD{
+		Checksum:         "a630673eeff9e2537e2f10668af1ef5d32f7b7db5fbfee6700ae151acb88138b",
+		ChecksumType:     "sha256",
+		OpenChecksum:     "c89dc65d6ed6d0462c001027e25e665a599821e9082579d63df8f4e815646207",
+		OpenChecksumType: "sha256",
+		Timestamp:        primary.Timestamp,
+		Location:         "repodata/primary.xml.gz",
+		Size:             13227,
+		OpenSize:         164037,
+		Revision:         "1343662744",
+	}
+	if primary != want {
+		t.Fatalf("invalid primary entry.\ngot= %#v\nwant=%#v\n", primary, want)
+	}
+	if primary.Timestamp.Unix() != 1343662777 {
+		t.Fatalf("invalid timestamp. got=%v\n", primary.Timestamp)
+	}
+	if loc := md["filelists"].Location; loc != "repodata/filelists.xml.gz" {
+		t.Fatalf("invalid filelists location. got=%q\n", loc)
+	}
+}
+
+func TestCheckRepoMD(t *testing.T) {
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer os.RemoveAll("testdata/cachedir.tmp")
+
+	data, err := ioutil.ReadFile("testdata/testconfig-xml/var/cache/lbyum/lcg/repomd.xml")
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		t.Fatalf("could not parse repomd.xml: %v\n", err)
+	}
+
+	primary, ok := md["primary"]
+	if !ok {
+		t.Fatalf("expected a primary entry\n")
+	}
+	if primary.Size != 13227 {
+		t.Fatalf("invalid size. got=%d. want=%d\n", primary.Size, 13227)
+	}
+	if primary.OpenSize != 164037 {
+		t.Fatalf("invalid open-size. got=%d. want=%d\n", primary.OpenSize, 164037)
+	}
+	if primary.ChecksumType != "sha256" {
+		t.Fatalf("invalid checksum type. got=%q. want=%q\n", primary.ChecksumType, "sha256")
+	}
+	if want := "a630673eeff9e2537e2f10668af1ef5d32f7b7db5fbfee6700ae151acb88138b"; primary.Checksum != want {
+		t.Fatalf("invalid checksum. got=%q. want=%q\n", primary.Checksum, want)
+	}
+	if primary.OpenChecksumType != "sha256" {
+		t.Fatalf("invalid open-checksum type. got=%q. want=%q\n", primary.OpenChecksumType, "sha256")
+	}
+	if want := "c89dc65d6ed6d0462c001027e25e665a599821e9082579d63df8f4e815646207"; primary.OpenChecksum != want {
+		t.Fatalf("invalid open-checksum. got=%q. want=%q\n", primary.OpenChecksum, want)
+	}
+	if primary.Revision != "1343662744" {
+		t.Fatalf("invalid revision. got=%q. want=%q\n", primary.Revision, "1343662744")
+	}
+
+	// minimal repositories do not provide sizes nor package counts.
+	md, err = repo.checkRepoMD([]byte(`<repomd>
+  <data type="primary">
+    <checksum type="sha256">0123</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/primary.xml.gz"/>
+  </data>
+</repomd>`))
+	if err != nil {
+		t.Fatalf("could not parse minimal repomd.xml: %v\n", err)
+	}
+	primary = md["primary"]
+	if primary.Size != 0 || primary.OpenSize != 0 || primary.Packages != 0 {
+		t.Fatalf("expected zero sizes. got=%#v\n", primary)
+	}
+
+	md, err = repo.checkRepoMD([]byte(`<repomd>
+  <data type="primary">
+    <checksum type="sha256">0123</checksum>
+    <timestamp>1343662777</timestamp>
+    <size>42</size>
+    <open-size>1024</open-size>
+    <packages>7</packages>
+    <location href="repodata/primary.xml.gz"/>
+  </data>
+</repomd>`))
+	if err != nil {
+		t.Fatalf("could not parse repomd.xml: %v\n", err)
+	}
+	primary = md["primary"]
+	if primary.Size != 42 || primary.OpenSize != 1024 || primary.Packages != 7 {
+		t.Fatalf("invalid sizes. got=%#v\n", primary)
+	}
+
+	// duplicate entries: keep the newest one. malformed entries are skipped.
+	md, err = repo.checkRepoMD([]byte(`<repomd>
+  <data type="primary">
+    <checksum type="sha256">0123</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/old-primary.xml.gz"/>
+  </data>
+  <data type="primary">
+    <checksum type="sha256">4567</checksum>
+    <timestamp>1343662999</timestamp>
+    <location href="repodata/new-primary.xml.gz"/>
+  </data>
+  <data type="primary">
+    <checksum type="sha256">89ab</checksum>
+    <timestamp>1343662888</timestamp>
+    <location href="repodata/mid-primary.xml.gz"/>
+  </data>
+  <data type="filelists">
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/filelists.xml.gz"/>
+  </data>
+  <data type="other">
+    <checksum type="sha256">cdef</checksum>
+    <timestamp>1343662777</timestamp>
+  </data>
+  <data type="group_gz">
+    <checksum type="sha256">0123</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/comps.xml.gz"/>
+  </data>
+</repomd>`))
+	if err != nil {
+		t.Fatalf("could not parse repomd.xml: %v\n", err)
+	}
+	if got, want := md["primary"].Location, "repodata/new-primary.xml.gz"; got != want {
+		t.Fatalf("invalid primary location. got=%q. want=%q\n", got, want)
+	}
+	if got, want := md["primary"].Checksum, "4567"; got != want {
+		t.Fatalf("invalid primary checksum. got=%q. want=%q\n", got, want)
+	}
+	for _, typ := range []string{"filelists", "other"} {
+		if _, ok := md[typ]; ok {
+			t.Fatalf("expected malformed %q entry to be skipped\n", typ)
+		}
+	}
+	if _, ok := md["group_gz"]; !ok {
+		t.Fatalf("expected unknown %q entry to be kept\n", "group_gz")
+	}
+
+	// nested elements named like entries are ignored, the revision may
+	// come last and trailing content is ignored.
+	md, err = repo.checkRepoMD([]byte(`<repomd>
+  <data type="primary">
+    <checksum type="sha256">0123</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/primary.xml.gz"/>
+  </data>
+  <tags><data type="other"><checksum>4567</checksum><location href="other.xml.gz"/></data></tags>
+  <revision>42</revision>
+</repomd>
+<garbage`))
+	if err != nil {
+		t.Fatalf("could not parse repomd.xml with trailing content: %v\n", err)
+	}
+	if len(md) != 1 {
+		t.Fatalf("expected a single entry. got=%v\n", md)
+	}
+	if got := md["primary"].Revision; got != "42" {
+		t.Fatalf("invalid revision. got=%q. want=%q\n", got, "42")
+	}
+
+	// timestamps as integers, floats, attributes or missing.
+	for _, table := range []struct {
+		entry string
+		want  time.Time
+	}{
+		{`<timestamp>1343662777</timestamp>`, time.Unix(1343662777, 0)},
+		{`<timestamp> 1343662777.5 </timestamp>`, time.Unix(1343662777, 5e8)},
+		{`<timestamp></timestamp>`, time.Time{}},
+		{``, time.Time{}},
+		{`<location href="repodata/primary.xml.gz" timestamp="1343662777"/>`, time.Unix(1343662777, 0)},
+	} {
+		entry := table.entry
+		if !strings.Contains(entry, "<location") {
+			entry += `<location href="repodata/primary.xml.gz"/>`
+		}
+		md, err = repo.checkRepoMD([]byte(`<repomd><data type="primary"><checksum type="sha256">0123</checksum>` +
+			entry + `</data></repomd>`))
+		if err != nil {
+			t.Fatalf("%s: could not parse repomd.xml: %v\n", table.entry, err)
+		}
+		if got := md["primary"].Timestamp; !got.Equal(table.want) || got.IsZero() != table.want.IsZero() {
+			t.Fatalf("%s: invalid timestamp. got=%v. want=%v\n", table.entry, got, table.want)
+		}
+	}
+
+	for _, data := range []string{
+		`<metadata></metadata>`,
+		`<repomd><data type="primary">`,
+		`  `,
+		`<repomd><data type="primary"><checksum>0123</checksum><timestamp>yesterday</timestamp><location href="p.xml"/></data></repomd>`,
+	} {
+		_, err = repo.checkRepoMD([]byte(data))
+		if err == nil {
+			t.Fatalf("expected an error for invalid repomd.xml %q\n", data)
+		}
+	}
+}
+
+func TestRepositoryOpenChecksumMismatch(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// corrupt the checksum of the uncompressed primary DB
+	fname := filepath.Join(remote, "repodata", "repomd.xml")
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+	data = bytes.Replace(data,
+		[]byte("c89dc65d6ed6d0462c001027e25e665a599821e9082579d63df8f4e815646207"),
+		[]byte("0000000000000000000000000000000000000000000000000000000000000000"),
+		1,
+	)
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	_, err = NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+}
+
+func TestRepositoryConcurrentProbes(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// the preferred (SQLite) DB is only served once the XML one has been
+	// requested: probing the backends one after the other would time out.
+	xmlreq := make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch filepath.Base(r.URL.Path) {
+		case "primary.sqlite.bz2":
+			select {
+			case <-xmlreq:
+			case <-time.After(5 * time.Second):
+				http.Error(w, "timeout", http.StatusGatewayTimeout)
+				return
+			}
+			http.NotFound(w, r)
+		case "primary.xml.gz":
+			once.Do(func() { close(xmlreq) })
+			http.ServeFile(w, r, filepath.Join(remote, "repodata", "primary.xml.gz"))
+		default:
+			http.ServeFile(w, r, filepath.Join(remote, "repodata", filepath.Base(r.URL.Path)))
+		}
+	}))
+	defer srv.Close()
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", srv.URL, cachedir,
+		[]string{"RepositorySQLiteBackend", "RepositoryXMLBackend"},
+		false, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Retries = 0
+
+	start := time.Now()
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	if d := time.Since(start); d >= 5*time.Second {
+		t.Fatalf("backends were not probed concurrently (took %v)\n", d)
+	}
+
+	if _, ok := repo.Backend.(*RepositoryXMLBackend); !ok {
+		t.Fatalf("expected the XML backend. got=%T\n", repo.Backend)
+	}
+
+	fis, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		t.Fatalf("could not read cachedir: %v\n", err)
+	}
+	for _, fi := range fis {
+		switch fi.Name() {
+		case "repomd.xml", "repomd.xml.backend", "primary.xml.gz", "primary.xml.gz.index":
+		default:
+			t.Errorf("unexpected file in cachedir: %s\n", fi.Name())
+		}
+	}
+}
+
+func TestRepositoryClose(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository("lcg", "http://dummy-url.org", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	// closing a repository without backend is fine.
+	err = repo.Close()
+	if err != nil {
+		t.Fatalf("could not close repository: %v\n", err)
+	}
+
+	for _, fname := range []string{"repomd.xml", "primary.xml.gz"} {
+		err = copyTestFile(
+			filepath.Join(cachedir, fname),
+			filepath.Join("testdata/testconfig-xml/var/cache/lbyum/lcg", fname),
+		)
+		if err != nil {
+			t.Fatalf("could not seed cache: %v\n", err)
+		}
+	}
+
+	err = repo.SetupBackend(false)
+	if err != nil {
+		t.Fatalf("could not setup repository: %v\n", err)
+	}
+
+	// make the index unwritable while loading the DB.
+	backend := repo.Backend.(*RepositoryXMLBackend)
+	index := backend.Index
+	err = os.RemoveAll(index)
+	if err != nil {
+		t.Fatalf("could not remove index: %v\n", err)
+	}
+	backend.Index = filepath.Join(cachedir, "no-such-dir", "primary.xml.gz.index")
+	err = backend.LoadDB()
+	if err != nil {
+		t.Fatalf("could not load DB: %v\n", err)
+	}
+	backend.Index = index
+
+	// the index is flushed when closing the repository.
+	err = repo.Close()
+	if err != nil {
+		t.Fatalf("could not close repository: %v\n", err)
+	}
+	if !path_exists(index) {
+		t.Fatalf("expected index [%s] to be flushed\n", index)
+	}
+
+	err = repo.Close()
+	if err != nil {
+		t.Fatalf("could not close repository twice: %v\n", err)
+	}
+}
+
+func TestRepositoryURL(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	for _, table := range []struct {
+		url     string
+		repourl string
+		mdurl   string
+		dburl   string
+	}{
+		{
+			url:     "http://example.org/repo",
+			repourl: "http://example.org/repo",
+			mdurl:   "http://example.org/repo/repodata/repomd.xml",
+			dburl:   "http://example.org/repo/repodata/primary.xml.gz",
+		},
+		{
+			url:     "http://example.org/repo/",
+			repourl: "http://example.org/repo",
+			mdurl:   "http://example.org/repo/repodata/repomd.xml",
+			dburl:   "http://example.org/repo/repodata/primary.xml.gz",
+		},
+		{
+			url:     "http://example.org/repo//",
+			repourl: "http://example.org/repo",
+			mdurl:   "http://example.org/repo/repodata/repomd.xml",
+			dburl:   "http://example.org/repo/repodata/primary.xml.gz",
+		},
+		{
+			url:     "http://example.org",
+			repourl: "http://example.org",
+			mdurl:   "http://example.org/repodata/repomd.xml",
+			dburl:   "http://example.org/repodata/primary.xml.gz",
+		},
+		{
+			url:     "https://example.org/repo/?token=s3cr3t",
+			repourl: "https://example.org/repo?token=s3cr3t",
+			mdurl:   "https://example.org/repo/repodata/repomd.xml?token=s3cr3t",
+			dburl:   "https://example.org/repo/repodata/primary.xml.gz?token=s3cr3t",
+		},
+		{
+			url:     "file:///srv/mirror/repo/",
+			repourl: "file:///srv/mirror/repo",
+			mdurl:   "file:///srv/mirror/repo/repodata/repomd.xml",
+			dburl:   "file:///srv/mirror/repo/repodata/primary.xml.gz",
+		},
+	} {
+		repo, err := NewRepository("testrepo", table.url, cachedir,
+			[]string{"RepositoryXMLBackend"},
+			false, false,
+		)
+		if err != nil {
+			t.Fatalf("%s: could not create repository: %v\n", table.url, err)
+		}
+		if repo.RepoUrl != table.repourl {
+			t.Errorf("%s: expected RepoUrl=%q. got=%q\n", table.url, table.repourl, repo.RepoUrl)
+		}
+		if repo.RepoMdUrl != table.mdurl {
+			t.Errorf("%s: expected RepoMdUrl=%q. got=%q\n", table.url, table.mdurl, repo.RepoMdUrl)
+		}
+		dburl, err := joinURL(repo.RepoUrl, "repodata/primary.xml.gz")
+		if err != nil {
+			t.Fatalf("%s: could not build DB URL: %v\n", table.url, err)
+		}
+		if dburl != table.dburl {
+			t.Errorf("%s: expected DB URL=%q. got=%q\n", table.url, table.dburl, dburl)
+		}
+	}
+
+	_, err = NewRepository("testrepo", "http://example.org/%zz", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err == nil {
+		t.Fatalf("expected an error for an invalid URL\n")
+	}
+}
+
+func TestRepositoryLocalPath(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	// plain paths are turned into file:// URLs.
+	repo, err := NewRepository(
+		"lcg", remote+"/", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	if want := "file://" + filepath.ToSlash(remote); repo.RepoUrl != want {
+		t.Fatalf("expected RepoUrl=%q. got=%q\n", want, repo.RepoUrl)
+	}
+	if len(repo.GetPackages()) <= 0 {
+		t.Fatalf("expected some packages\n")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v\n", err)
+	}
+	rel, err := NewRepository(
+		"lcg", "testdata/mirror", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	if want := "file://" + filepath.ToSlash(filepath.Join(wd, "testdata", "mirror")); rel.RepoUrl != want {
+		t.Fatalf("expected RepoUrl=%q. got=%q\n", want, rel.RepoUrl)
+	}
+
+	// local repositories remain available offline.
+	offline, err := NewRepository(
+		"lcg", remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	offline.Offline = true
+	_, err = offline.getRemoteData(remote + "/repodata/repomd.xml")
+	if err != nil {
+		t.Fatalf("could not read local repomd.xml offline: %v\n", err)
+	}
+}
+
+// statusRecorder records the status of the responses of an HTTP handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func TestRepositoryConditionalMetadata(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	repomd, err := ioutil.ReadFile(filepath.Join(remote, "repodata", "repomd.xml"))
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+
+	etag := `"v1"`
+	modtime := time.Now().Add(-time.Hour)
+	var mu sync.Mutex
+	statuses := make([]int, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) != "repomd.xml" {
+			http.ServeFile(w, r, filepath.Join(remote, "repodata", filepath.Base(r.URL.Path)))
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			mu.Lock()
+			statuses = append(statuses, rec.status)
+			mu.Unlock()
+		}()
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(rec, r, "repomd.xml", modtime, bytes.NewReader(repomd))
+	}))
+	defer srv.Close()
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	setup := func() {
+		repo, err := NewRepository(
+			"lcg", srv.URL, cachedir,
+			[]string{"RepositoryXMLBackend"},
+			true, true,
+		)
+		if err != nil {
+			t.Fatalf("could not create repository: %v\n", err)
+		}
+		defer repo.Close()
+		if len(repo.GetPackages()) <= 0 {
+			t.Fatalf("expected some packages\n")
+		}
+	}
+
+	laststatus := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return statuses[len(statuses)-1]
+	}
+
+	// first retrieval: the ETag is stored alongside repomd.xml.
+	setup()
+	if st := laststatus(); st != http.StatusOK {
+		t.Fatalf("expected status %d. got=%d\n", http.StatusOK, st)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cachedir, "repomd.xml.etag"))
+	if err != nil {
+		t.Fatalf("expected an ETag file: %v\n", err)
+	}
+	if !bytes.Contains(data, []byte(etag)) {
+		t.Fatalf("expected ETag %s. got=%q\n", etag, data)
+	}
+
+	// second retrieval: If-None-Match.
+	setup()
+	if st := laststatus(); st != http.StatusNotModified {
+		t.Fatalf("expected status %d. got=%d\n", http.StatusNotModified, st)
+	}
+
+	// third retrieval: If-Modified-Since.
+	etag = ""
+	err = os.Remove(filepath.Join(cachedir, "repomd.xml.etag"))
+	if err != nil {
+		t.Fatalf("could not remove ETag file: %v\n", err)
+	}
+	setup()
+	if st := laststatus(); st != http.StatusNotModified {
+		t.Fatalf("expected status %d. got=%d\n", http.StatusNotModified, st)
+	}
+
+	// modified metadata is retrieved again.
+	modtime = time.Now().Add(time.Hour)
+	setup()
+	if st := laststatus(); st != http.StatusOK {
+		t.Fatalf("expected status %d. got=%d\n", http.StatusOK, st)
+	}
+}
+
+func TestRepositoryNeedsUpdate(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	// empty cache.
+	update, err := repo.NeedsUpdate()
+	if err != nil {
+		t.Fatalf("could not check for updates: %v\n", err)
+	}
+	if !update {
+		t.Fatalf("expected an empty cache to need an update\n")
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend: %v\n", err)
+	}
+	defer repo.Close()
+
+	update, err = repo.NeedsUpdate()
+	if err != nil {
+		t.Fatalf("could not check for updates: %v\n", err)
+	}
+	if update {
+		t.Fatalf("expected an up-to-date cache\n")
+	}
+
+	// newer timestamps within the same revision are ignored.
+	fname := filepath.Join(remote, "repodata", "repomd.xml")
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+	data = bytes.Replace(data, []byte("<timestamp>1343662777</timestamp>"), []byte("<timestamp>1343669999</timestamp>"), -1)
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	update, err = repo.NeedsUpdate()
+	if err != nil {
+		t.Fatalf("could not check for updates: %v\n", err)
+	}
+	if update {
+		t.Fatalf("expected an up-to-date cache within the same revision\n")
+	}
+
+	// publish a new revision.
+	data = bytes.Replace(data, []byte("<revision>1343662744</revision>"), []byte("<revision>1343669999</revision>"), 1)
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	before, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		t.Fatalf("could not read cachedir: %v\n", err)
+	}
+
+	update, err = repo.NeedsUpdate()
+	if err != nil {
+		t.Fatalf("could not check for updates: %v\n", err)
+	}
+	if !update {
+		t.Fatalf("expected an outdated cache\n")
+	}
+
+	// nothing was downloaded.
+	after, err := ioutil.ReadDir(cachedir)
+	if err != nil {
+		t.Fatalf("could not read cachedir: %v\n", err)
+	}
+	if len(before) != len(after) {
+		t.Fatalf("expected the cache to be left untouched. got=%d files, want=%d\n", len(after), len(before))
+	}
+	for i := range before {
+		if before[i].Name() != after[i].Name() || !before[i].ModTime().Equal(after[i].ModTime()) {
+			t.Fatalf("expected [%s] to be left untouched\n", before[i].Name())
+		}
+	}
+
+	repo.Backends = []string{"nosuchbackend"}
+	repo.Backend = nil
+	_, err = repo.NeedsUpdate()
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+}
+
+func TestRemoteContentEncoding(t *testing.T) {
+	const content = "<repomd></repomd>"
+
+	// the server encodes its responses whatever the request.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, content)
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer os.RemoveAll("testdata/cachedir.tmp")
+
+	data, err := repo.remoteMetadata()
+	if err != nil {
+		t.Fatalf("could not retrieve remote metadata: %v\n", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected %q. got=%q\n", content, string(data))
+	}
+
+	// the transport does not decode content it did not ask to be encoded.
+	for _, enc := range []string{"gzip", "identity"} {
+		r, err := repo.openRemoteData(repo.context(), repo.RepoMdUrl, http.Header{"Accept-Encoding": []string{enc}})
+		if err != nil {
+			t.Fatalf("accept-encoding=%s: could not retrieve remote metadata: %v\n", enc, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("accept-encoding=%s: could not read remote metadata: %v\n", enc, err)
+		}
+		if string(data) != content {
+			t.Fatalf("accept-encoding=%s: expected %q. got=%q\n", enc, content, string(data))
+		}
+	}
+}
+
+func TestRepositoryWalkPackages(t *testing.T) {
+	for _, repo := range []*Repository{
+		newTestXMLRepository(t, "testdata/primary.xml"),
+		newTestMemoryRepository(t),
+	} {
+		defer repo.Close()
+
+		var names []string
+		err := repo.WalkPackages(func(pkg *Package) error {
+			names = append(names, pkg.ID())
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: could not walk packages: %v\n", repo.Name, err)
+		}
+		if got, want := len(names), len(repo.GetPackages()); got != want {
+			t.Fatalf("%s: walked %d packages. want=%d\n", repo.Name, got, want)
+		}
+
+		stop := errors.New("stop")
+		n := 0
+		err = repo.WalkPackages(func(pkg *Package) error {
+			n++
+			return stop
+		})
+		if err != stop {
+			t.Fatalf("%s: expected the error of the walk function. got=%v\n", repo.Name, err)
+		}
+		if n != 1 {
+			t.Fatalf("%s: walk did not stop after an error (%d calls)\n", repo.Name, n)
+		}
+	}
+}
+
+func TestRepositoryRefreshDB(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	// up-to-date DB.
+	err = repo.RefreshDB()
+	if err != nil {
+		t.Fatalf("could not refresh DB: %v\n", err)
+	}
+	if _, err := repo.FindLatestMatchingName("foo", "", ""); err == nil {
+		t.Fatalf("expected no package foo before the refresh\n")
+	}
+
+	// publish a new revision of the primary DB.
+	src, err := ioutil.ReadFile("testdata/primary.xml")
+	if err != nil {
+		t.Fatalf("could not read primary DB: %v\n", err)
+	}
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	_, err = zw.Write(src)
+	if err != nil {
+		t.Fatalf("could not compress primary DB: %v\n", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatalf("could not compress primary DB: %v\n", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(remote, "repodata", "primary.xml.gz"), buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("could not write primary DB: %v\n", err)
+	}
+
+	fname := filepath.Join(remote, "repodata", "repomd.xml")
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	for _, r := range [][2]string{
+		{"<revision>1343662744</revision>", "<revision>1343669999</revision>"},
+		{"a630673eeff9e2537e2f10668af1ef5d32f7b7db5fbfee6700ae151acb88138b", hex.EncodeToString(sum[:])},
+		{`<open-checksum type="sha256">c89dc65d6ed6d0462c001027e25e665a599821e9082579d63df8f4e815646207</open-checksum>`, ""},
+	} {
+		data = bytes.Replace(data, []byte(r[0]), []byte(r[1]), 1)
+	}
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	backend := repo.Backend
+	err = repo.RefreshDB()
+	if err != nil {
+		t.Fatalf("could not refresh DB: %v\n", err)
+	}
+	if repo.Backend != backend {
+		t.Fatalf("expected the backend to be kept\n")
+	}
+	if _, err := repo.FindLatestMatchingName("foo", "", ""); err != nil {
+		t.Fatalf("expected package foo after the refresh: %v\n", err)
+	}
+
+	local, err := ioutil.ReadFile(repo.LocalRepoMdXml)
+	if err != nil {
+		t.Fatalf("could not read local repomd.xml: %v\n", err)
+	}
+	if !bytes.Equal(local, data) {
+		t.Fatalf("expected the local repomd.xml to be updated\n")
+	}
+
+	repo.Offline = true
+	err = repo.RefreshDB()
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline. got=%v\n", err)
+	}
+}
+
+// roundTripFunc is a http.RoundTripper calling itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fct roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fct(req)
+}
+
+func TestRepositoryTransport(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "http://dummy-url.org/lcg", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	// serve the remote repository without any HTTP server.
+	var mu sync.Mutex
+	var paths []string
+	repo.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+		data, err := ioutil.ReadFile(filepath.Join(remote, strings.TrimPrefix(req.URL.Path, "/lcg/")))
+		if err != nil {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(strings.NewReader("not found")),
+				Request:    req,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}))
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend: %v\n", err)
+	}
+	if len(repo.GetPackages()) <= 0 {
+		t.Fatalf("expected some packages\n")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, want := range []string{"/lcg/repodata/repomd.xml", "/lcg/repodata/primary.xml.gz"} {
+		found := false
+		for _, p := range paths {
+			found = found || p == want
+		}
+		if !found {
+			t.Fatalf("expected a request for [%s]. got=%v\n", want, paths)
+		}
+	}
+
+	repo.SetTransport(nil)
+	if repo.httpClient() != repo.client {
+		t.Fatalf("expected the default client to be restored\n")
+	}
+}
+
+func TestRepositoryProbeInvalidLocation(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "http://dummy-url.org", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	for _, loc := range []string{"", "  ", "repodata/", "%zz/primary.xml.gz"} {
+		probe := &backendProbe{name: "RepositoryXMLBackend"}
+		remotemd := map[string]RepoMD{
+			"primary": {Checksum: "0123", ChecksumType: "sha256", Location: loc},
+		}
+		repo.probeBackend(context.Background(), probe, remotemd, nil)
+		if probe.err == nil {
+			t.Fatalf("location %q: expected an error\n", loc)
+		}
+		if probe.backend != nil || probe.fname != "" {
+			t.Fatalf("location %q: expected the backend to be skipped\n", loc)
+		}
+		if strings.TrimSpace(loc) == "" && !errors.Is(probe.err, ErrMetadataNotFound) {
+			t.Fatalf("location %q: expected ErrMetadataNotFound. got=%v\n", loc, probe.err)
+		}
+	}
+}
+
+func TestRepositoryRepoDataPath(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// move the metadata to a non-standard directory.
+	err := os.MkdirAll(filepath.Join(remote, "export"), 0755)
+	if err != nil {
+		t.Fatalf("could not create metadata directory: %v\n", err)
+	}
+	err = os.Rename(filepath.Join(remote, "repodata"), filepath.Join(remote, "export", "meta"))
+	if err != nil {
+		t.Fatalf("could not move metadata directory: %v\n", err)
+	}
+	fname := filepath.Join(remote, "export", "meta", "repomd.xml")
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+	data = bytes.Replace(data, []byte(`href="repodata/`), []byte(`href="export/meta/`), -1)
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	err = repo.SetupBackend(true)
+	if err == nil {
+		t.Fatalf("expected an error with the default repodata path\n")
+	}
+
+	err = repo.SetRepoDataPath("/export/meta/")
+	if err != nil {
+		t.Fatalf("could not set repodata path: %v\n", err)
+	}
+	if want := "file://" + remote + "/export/meta/repomd.xml"; repo.RepoMdUrl != want {
+		t.Fatalf("invalid repomd URL. got=%q. want=%q\n", repo.RepoMdUrl, want)
+	}
+
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend: %v\n", err)
+	}
+	if len(repo.GetPackages()) <= 0 {
+		t.Fatalf("expected some packages\n")
+	}
+
+	err = repo.SetRepoDataPath("http://example.org/repodata")
+	if err == nil {
+		t.Fatalf("expected an error for an absolute repodata path\n")
+	}
+}
+
+func TestRepositorySQLiteFallback(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	// publish a corrupt (but correctly checksummed) primary_db.
+	db := []byte("this is not a SQLite database")
+	err := ioutil.WriteFile(filepath.Join(remote, "repodata", "primary.sqlite.bz2"), db, 0644)
+	if err != nil {
+		t.Fatalf("could not write remote DB: %v\n", err)
+	}
+
+	fname := filepath.Join(remote, "repodata", "repomd.xml")
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read repomd.xml: %v\n", err)
+	}
+	sum := sha256.Sum256(db)
+	entry := fmt.Sprintf(`  <data type="primary_db">
+    <location href="repodata/primary.sqlite.bz2"/>
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662784.0</timestamp>
+    <size>%d</size>
+    <database_version>10</database_version>
+  </data>
+</repomd>`, hex.EncodeToString(sum[:]), len(db))
+	data = bytes.Replace(data, []byte("</repomd>"), []byte(entry), 1)
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositorySQLiteBackend", "RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("expected a fallback on the XML backend: %v\n", err)
+	}
+	defer repo.Close()
+
+	if _, ok := repo.Backend.(*RepositoryXMLBackend); !ok {
+		t.Fatalf("expected the XML backend. got=%T\n", repo.Backend)
+	}
+	for _, name := range []string{"primary.sqlite.bz2", "primary.sqlite"} {
+		if path_exists(filepath.Join(cachedir, name)) {
+			t.Fatalf("expected the corrupt DB [%s] to be removed from the cache\n", name)
+		}
+	}
+	_, err = repo.FindLatestMatchingName("AIDA_3.2.1_common", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+}
+
+func TestRepositoryGetPackagesOrder(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	// add a package differing only by its arch, to exercise the last key.
+	pkgs := repo.Backend.GetPackages()
+	for _, pkg := range pkgs {
+		if pkg.ID() == "foo-1.5-2" {
+			dup := NewPackage(pkg.Name(), pkg.Version(), pkg.Release(), pkg.Epoch())
+			dup.SetArch("aarch64")
+			pkgs = append(pkgs, dup)
+		}
+	}
+	repo.Backend = NewMemoryBackend(pkgs)
+
+	want := []string{
+		"bar-1.0-1.x86_64", "baz-1.0-1.noarch", "conflicted-1.0-1.noarch",
+		"cyc-a-1.0-1.noarch", "cyc-b-1.0-1.noarch",
+		"foo-1.5-1.x86_64", "foo-1.5-2.aarch64", "foo-1.5-2.i686", "foo-2.0-1.x86_64",
+		"needsfile-1.0-1.noarch", "qux-1.0-1.noarch",
+	}
+	for i := 0; i < 10; i++ {
+		var got []string
+		for _, pkg := range repo.GetPackages() {
+			got = append(got, pkg.ID()+"."+pkg.Arch())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected order:\nwant=%v\ngot= %v\n", want, got)
+		}
+	}
+}
+
+func TestRepositoryCACert(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	srv := httptest.NewUnstartedServer(http.FileServer(http.Dir(remote)))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0) // silence handshake errors
+	srv.StartTLS()
+	defer srv.Close()
+
+	cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	certfile := filepath.Join(remote, "ca.pem")
+	err := ioutil.WriteFile(certfile, []byte(cert), 0644)
+	if err != nil {
+		t.Fatalf("could not write CA certificate: %v\n", err)
+	}
+
+	for _, table := range []struct {
+		name     string
+		cacert   string
+		insecure bool
+		ok       bool
+	}{
+		{name: "untrusted", ok: false},
+		{name: "ca-file", cacert: certfile, ok: true},
+		{name: "ca-pem", cacert: cert, ok: true},
+		{name: "invalid-ca", cacert: filepath.Join(remote, "repodata", "repomd.xml"), ok: false},
+		{name: "insecure", insecure: true, ok: true},
+	} {
+		t.Run(table.name, func(t *testing.T) {
+			cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+			if err != nil {
+				t.Fatalf("could not create cachedir: %v\n", err)
+			}
+			defer os.RemoveAll(cachedir)
+
+			repo, err := NewRepository(
+				"lcg", srv.URL, cachedir,
+				[]string{"RepositoryXMLBackend"},
+				false, false,
+			)
+			if err != nil {
+				t.Fatalf("could not create repository: %v\n", err)
+			}
+			defer repo.Close()
+
+			msg := &recordLogger{}
+			repo.SetLogger(msg)
+			repo.CACert = table.cacert
+			repo.InsecureSkipVerify = table.insecure
+
+			err = repo.SetupBackend(true)
+			switch {
+			case table.ok && err != nil:
+				t.Fatalf("could not set up backend: %v\n", err)
+			case !table.ok && err == nil:
+				t.Fatalf("expected a TLS error\n")
+			}
+			if err != nil && strings.Contains(strings.Join(msg.msgs, ""), "retrying") {
+				t.Fatalf("TLS errors should not be retried: %q\n", msg.msgs)
+			}
+
+			warned := strings.Contains(strings.Join(msg.msgs, ""), "verification is disabled")
+			if warned != table.insecure {
+				t.Fatalf("insecure=%v: unexpected warnings %q\n", table.insecure, msg.msgs)
+			}
+		})
+	}
+}
+
+func TestRepositoryFindAllMatchingRequire(t *testing.T) {
+	repo := newTestMemoryRepository(t)
+	defer repo.Close()
+
+	// an alternative implementation of libz.
+	zng := NewPackage("zlib-ng-compat", "2.1", "1", "0")
+	zng.SetArch("x86_64")
+	zng.AddProvides(NewProvides("libz", "1.3", "", "", "EQ", nil))
+	repo.Backend = NewMemoryBackend(append(repo.GetPackages(), zng))
+
+	for _, table := range []struct {
+		req      string
+		expected []string
+	}{
+		{"libz", []string{"zlib-1.2-1", "zlib-ng-compat-2.1-1"}},
+		{"zlib", []string{"zlib-1.2-1", "zlib-1.1-1"}},
+		{"app", []string{"app-1.0-1"}},
+	} {
+		pkgs, err := repo.FindAllMatchingRequire(table.req)
+		if err != nil {
+			t.Fatalf("%s: could not find providers: %v\n", table.req, err)
+		}
+		got := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			got = append(got, pkg.ID())
+		}
+		if !reflect.DeepEqual(got, table.expected) {
+			t.Fatalf("%s: expected %v. got=%v\n", table.req, table.expected, got)
+		}
+	}
+
+	_, err := repo.FindAllMatchingRequire("nosuchcap")
+	if err == nil {
+		t.Fatalf("expected an error for a capability without provider\n")
+	}
+}
+
+func TestDBNeedsUpdateTimestamps(t *testing.T) {
+	ba := NewMemoryBackend(nil)
+	old := time.Unix(1343662777, 0)
+	for _, table := range []struct {
+		name   string
+		remote RepoMD
+		local  RepoMD
+		want   bool
+	}{
+		{"same", RepoMD{Checksum: "0123", Timestamp: old}, RepoMD{Checksum: "0123", Timestamp: old}, false},
+		{"newer", RepoMD{Checksum: "0123", Timestamp: old.Add(time.Second)}, RepoMD{Checksum: "0123", Timestamp: old}, true},
+		{"missing-remote", RepoMD{Checksum: "0123"}, RepoMD{Checksum: "0123", Timestamp: old}, true},
+		{"missing-local", RepoMD{Checksum: "0123", Timestamp: old}, RepoMD{Checksum: "0123"}, true},
+		{"same-revision", RepoMD{Checksum: "0123", Revision: "42"}, RepoMD{Checksum: "0123", Revision: "42"}, false},
+	} {
+		if got := dbNeedsUpdate(ba, table.remote, table.local); got != table.want {
+			t.Fatalf("%s: expected needs-update=%v. got=%v\n", table.name, table.want, got)
+		}
+	}
+}
+
+func TestRepositoryWithCacheDir(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	repo, err := NewRepository(
+		"lcg", "file://"+remote, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		true, true,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	repo.Timeout = 42 * time.Second
+	repo.Pin("app", "1.0", "")
+
+	other, err := ioutil.TempDir("", "lbpkr-test-cache-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(other)
+	other = filepath.Join(other, "run-1")
+
+	clone := repo.WithCacheDir(other)
+	defer clone.Close()
+
+	if clone.CacheDir != other {
+		t.Fatalf("invalid cache dir. got=%q. want=%q\n", clone.CacheDir, other)
+	}
+	if want := filepath.Join(other, "repomd.xml"); clone.LocalRepoMdXml != want {
+		t.Fatalf("invalid local repomd.xml. got=%q. want=%q\n", clone.LocalRepoMdXml, want)
+	}
+	if clone.Backend != nil {
+		t.Fatalf("expected no backend for the copy\n")
+	}
+	if clone.Name != repo.Name || clone.RepoUrl != repo.RepoUrl || clone.Timeout != repo.Timeout {
+		t.Fatalf("configuration not copied: %#v\n", clone)
+	}
+	if _, ok := clone.pins["app"]; !ok {
+		t.Fatalf("pins not copied\n")
+	}
+	clone.Unpin("app")
+	if _, ok := repo.pins["app"]; !ok {
+		t.Fatalf("pins of the copy should not be shared\n")
+	}
+
+	err = clone.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend of the copy: %v\n", err)
+	}
+	if !path_exists(clone.LocalRepoMdXml) {
+		t.Fatalf("expected local repomd.xml file [%s]\n", clone.LocalRepoMdXml)
+	}
+	if got, want := len(clone.GetPackages()), len(repo.GetPackages()); got != want {
+		t.Fatalf("invalid number of packages. got=%d. want=%d\n", got, want)
+	}
+}
+
+func TestPackageProvenance(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if got, want := pkg.RepositoryName(), "testrepo"; got != want {
+		t.Fatalf("invalid repository. got=%q. want=%q\n", got, want)
+	}
+	if got, want := pkg.BackendName(), "RepositoryXMLBackend"; got != want {
+		t.Fatalf("invalid backend. got=%q. want=%q\n", got, want)
+	}
+
+	mem := newTestMemoryRepository(t)
+	defer mem.Close()
+
+	pkg, err = mem.FindLatestMatchingName("app", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if got, want := pkg.BackendName(), "memoryBackend"; got != want {
+		t.Fatalf("invalid backend. got=%q. want=%q\n", got, want)
+	}
+
+	pkg = NewPackage("foo", "1.0", "1", "0")
+	if pkg.RepositoryName() != "" || pkg.BackendName() != "" {
+		t.Fatalf("expected no provenance for a new package\n")
+	}
+}
+
+func TestRepositoryFindLatestMatchingConstraint(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		constraint string
+		want       string // empty for no match
+	}{
+		{"", "foo-2.0-1"},
+		{">=2.0", "foo-2.0-1"},
+		{">=1.0,<2.0", "foo-1.5-2"},
+		{">= 1.0, < 2.0", "foo-1.5-2"},
+		{"=1.5-1", "foo-1.5-1"},
+		{"==0:1.5-1", "foo-1.5-1"},
+		{"<=1.5-1", "foo-1.5-1"},
+		{">2.0", ""},
+		{"<1.0", ""},
+	} {
+		pkg, err := repo.FindLatestMatchingConstraint("foo", table.constraint)
+		if table.want == "" {
+			if err == nil {
+				t.Errorf("%q: expected no match. got=%s\n", table.constraint, pkg.ID())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: could not find package: %v\n", table.constraint, err)
+			continue
+		}
+		if pkg.ID() != table.want {
+			t.Errorf("%q: invalid package. got=%s. want=%s\n", table.constraint, pkg.ID(), table.want)
+		}
+	}
+
+	for _, constraint := range []string{"~1.0", "1.0", ">=", ">=1.0,2.0"} {
+		_, err := repo.FindLatestMatchingConstraint("foo", constraint)
+		if err == nil || !strings.Contains(err.Error(), "invalid constraint") {
+			t.Errorf("%q: expected an invalid constraint error. got=%v\n", constraint, err)
+		}
+	}
+
+	repo.Pin("foo", "1.5", "1")
+	defer repo.Unpin("foo")
+	pkg, err := repo.FindLatestMatchingConstraint("foo", ">=1.0")
+	if err != nil {
+		t.Fatalf("could not find pinned package: %v\n", err)
+	}
+	if pkg.ID() != "foo-1.5-1" {
+		t.Fatalf("expected the pinned version. got=%s\n", pkg.ID())
+	}
+	_, err = repo.FindLatestMatchingConstraint("foo", ">=2.0")
+	if err == nil {
+		t.Fatalf("expected no match for a constraint excluding the pinned version\n")
+	}
+}
+
+func TestRepositoryWantedDataTypes(t *testing.T) {
+	remote := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(remote)
+
+	cachedir, err := ioutil.TempDir("", "lbpkr-test-wanted-")
+	if err != nil {
+		t.Fatalf("could not create cachedir: %v\n", err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	var mu sync.Mutex
+	var reqs []string
+	files := http.FileServer(http.Dir(remote))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r.URL.Path)
+		mu.Unlock()
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	requests := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := reqs
+		reqs = nil
+		return out
+	}
+
+	repo, err := NewRepository("lcg", srv.URL, cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Retries = 0
+
+	repo.WantedDataTypes = []string{"filelists"}
+	err = repo.SetupBackend(true)
+	if !errors.Is(err, ErrNoBackend) || !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrNoBackend and ErrMetadataNotFound. got=%v\n", err)
+	}
+	if got, want := requests(), []string{"/repodata/repomd.xml"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid requests.\ngot= %v\nwant=%v\n", got, want)
+	}
+
+	repo.WantedDataTypes = []string{"primary"}
+	err = repo.SetupBackend(true)
+	if err != nil {
+		t.Fatalf("could not setup backend: %v\n", err)
+	}
+	defer repo.Close()
+	requests()
+
+	_, err = repo.fileListsDB()
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+	if got := requests(); len(got) != 0 {
+		t.Fatalf("expected no requests for unwanted data types. got=%v\n", got)
+	}
+}
+
+func TestRepositoryUnsatisfiableRequire(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	req := NewRequires("foo", "99.0", "", "", "GE", "")
+	pkg, err := repo.FindLatestMatchingRequire(req)
+	if err == nil {
+		t.Fatalf("expected an error for an unsatisfiable requirement. got=%v\n", pkg)
+	}
+
+	repo.Pin("foo", "1.5", "1")
+	_, err = repo.FindLatestMatchingRequire(req)
+	if err == nil {
+		t.Fatalf("expected an error for an unsatisfiable requirement on a pinned package\n")
+	}
+	repo.Unpin("foo")
+
+	needy := NewPackage("needy", "1.0", "1", "0")
+	needy.SetArch("noarch")
+	needy.AddRequires(req)
+	pkgs, err := repo.RequiredPackages(needy)
+	if err == nil {
+		t.Fatalf("expected an error for a missing requirement. got=%v\n", pkgNames(pkgs))
+	}
+
+	// file requirements not satisfied by the provides of the backend are
+	// looked up in the filelists metadata.
+	tool := NewPackage("tool", "2.0", "1", "0")
+	tool.SetArch("noarch")
+	oldtool := NewPackage("oldtool", "1.0", "1", "0")
+	oldtool.SetArch("noarch")
+	oldtool.AddProvides(NewProvides("/opt/tool", "1.0", "1", "0", "EQ", nil))
+
+	repo = newTestMemoryRepository(t)
+	repo.Backend = NewMemoryBackend([]*Package{tool, oldtool})
+	repo.files = map[string][]*Package{"/opt/tool": {tool}}
+	pkg, err = repo.FindLatestMatchingRequire(NewRequires("/opt/tool", "2.0", "", "", "GE", ""))
+	if err != nil {
+		t.Fatalf("could not find file provider: %v\n", err)
+	}
+	if pkg != tool {
+		t.Fatalf("expected %s. got=%s\n", tool.ID(), pkg.ID())
+	}
+}
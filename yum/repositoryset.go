@@ -0,0 +1,109 @@
+package yum
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultPriority is the default priority of a repository, as for yum.
+const DefaultPriority = 99
+
+// RepositorySet queries several repositories as one.
+// Packages are only looked up in repositories of lower priority (i.e. of
+// higher Priority value) when no repository of higher priority provides them.
+// Among repositories of the same priority, the latest version wins.
+// The repository a package was found in is given by Package.Repository.
+type RepositorySet struct {
+	repos []*Repository
+}
+
+// NewRepositorySet returns a new set of repositories.
+func NewRepositorySet(repos ...*Repository) *RepositorySet {
+	set := &RepositorySet{
+		repos: make([]*Repository, 0, len(repos)),
+	}
+	set.Add(repos...)
+	return set
+}
+
+// Add adds repos to the set.
+func (set *RepositorySet) Add(repos ...*Repository) {
+	set.repos = append(set.repos, repos...)
+}
+
+// Repositories returns the repositories of the set, by priority.
+// Repositories of the same priority are returned in the order they were added.
+func (set *RepositorySet) Repositories() []*Repository {
+	repos := make([]*Repository, len(set.repos))
+	copy(repos, set.repos)
+	sort.SliceStable(repos, func(i, j int) bool {
+		return repos[i].Priority < repos[j].Priority
+	})
+	return repos
+}
+
+// FindLatestMatchingName locates a package by name in the set of repositories
+// and returns the latest available version from the repositories with the
+// highest priority providing that package.
+func (set *RepositorySet) FindLatestMatchingName(name, version, release string) (*Package, error) {
+	return set.find(func(repo *Repository) (*Package, error) {
+		return repo.FindLatestMatchingName(name, version, release)
+	})
+}
+
+// FindLatestMatchingRequire locates a package providing a given functionality
+// in the set of repositories, like FindLatestMatchingName.
+func (set *RepositorySet) FindLatestMatchingRequire(requirement *Requires) (*Package, error) {
+	return set.find(func(repo *Repository) (*Package, error) {
+		return repo.FindLatestMatchingRequire(requirement)
+	})
+}
+
+// RequiredPackages returns the list of all packages needed to install pkg,
+// including pkg itself, looking up dependencies across the whole set.
+// See Repository.RequiredPackages for the ordering and error conventions.
+func (set *RepositorySet) RequiredPackages(pkg *Package) ([]*Package, error) {
+	r := newResolver(set)
+	r.add(pkg)
+	return r.result()
+}
+
+// find returns the latest package found by fct in the repositories with the
+// highest priority.
+func (set *RepositorySet) find(fct func(repo *Repository) (*Package, error)) (*Package, error) {
+	repos := set.Repositories()
+	if len(repos) <= 0 {
+		return nil, fmt.Errorf("yum: no repository in set")
+	}
+
+	var err error
+	for i := 0; i < len(repos); {
+		// repositories [i, j) share the same priority.
+		j := i
+		found := make(Packages, 0)
+		for ; j < len(repos) && repos[j].Priority == repos[i].Priority; j++ {
+			pkg, e := fct(repos[j])
+			if e != nil {
+				if err == nil {
+					err = e
+				}
+				continue
+			}
+			found = append(found, pkg)
+		}
+
+		if len(found) > 0 {
+			// among equal versions, the first repository added wins.
+			sort.Stable(found)
+			latest := found[len(found)-1]
+			for _, pkg := range found {
+				if RpmEvrCompare(pkg.Epoch(), pkg.Version(), pkg.Release(), latest.Epoch(), latest.Version(), latest.Release()) == 0 {
+					return pkg, nil
+				}
+			}
+			return latest, nil
+		}
+		i = j
+	}
+	return nil, err
+}
@@ -0,0 +1,133 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepositorySet(t *testing.T) {
+	base := newTestXMLRepository(t, "testdata/primary.xml")
+	defer base.Close()
+	base.Name = "base"
+
+	updates := newTestXMLRepository(t, "testdata/primary-updates.xml")
+	defer updates.Close()
+	updates.Name = "updates"
+
+	for _, table := range []struct {
+		name     string
+		base     int
+		updates  int
+		pkg      string
+		expected string
+		repo     string
+	}{
+		{"same-priority", 10, 10, "foo", "foo-3.0-1", "updates"},
+		{"base-first", 10, 20, "foo", "foo-2.0-1", "base"},
+		{"updates-first", 20, 10, "foo", "foo-3.0-1", "updates"},
+		{"fallback", 10, 20, "extra", "extra-1.0-1", "updates"},
+		{"fallback", 20, 10, "bar", "bar-1.0-1", "base"},
+	} {
+		base.Priority = table.base
+		updates.Priority = table.updates
+		set := NewRepositorySet(base, updates)
+
+		pkg, err := set.FindLatestMatchingName(table.pkg, "", "")
+		if err != nil {
+			t.Fatalf("%s: could not find package %q: %v\n", table.name, table.pkg, err)
+		}
+		if pkg.ID() != table.expected {
+			t.Fatalf("%s: expected %s. got=%s\n", table.name, table.expected, pkg.ID())
+		}
+		if pkg.Repository().Name != table.repo {
+			t.Fatalf("%s: expected package from [%s]. got=[%s]\n", table.name, table.repo, pkg.Repository().Name)
+		}
+
+		req := NewRequires("libfoo.so", "", "", "", "", "")
+		pkg, err = set.FindLatestMatchingRequire(req)
+		if err != nil {
+			t.Fatalf("%s: could not find provider of libfoo.so: %v\n", table.name, err)
+		}
+		want := "foo-3.0-1"
+		if table.base < table.updates {
+			want = "foo-2.0-1"
+		}
+		if pkg.ID() != want {
+			t.Fatalf("%s: expected %s to provide libfoo.so. got=%s\n", table.name, want, pkg.ID())
+		}
+	}
+
+	base.Priority = DefaultPriority
+	updates.Priority = DefaultPriority
+	set := NewRepositorySet()
+	set.Add(updates, base)
+
+	if got := set.Repositories(); !reflect.DeepEqual(got, []*Repository{updates, base}) {
+		t.Fatalf("invalid repositories order\n")
+	}
+
+	_, err := set.FindLatestMatchingName("nosuchpkg", "", "")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown package\n")
+	}
+
+	// dependencies are resolved across repositories.
+	pkg, err := set.FindLatestMatchingName("extra", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	pkgs, err := set.RequiredPackages(pkg)
+	if err != nil {
+		t.Fatalf("could not resolve requirements: %v\n", err)
+	}
+	ids := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		ids = append(ids, p.ID())
+	}
+	if want := []string{"bar-1.0-1", "foo-3.0-1", "extra-1.0-1"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected %v. got=%v\n", want, ids)
+	}
+
+	_, err = NewRepositorySet().FindLatestMatchingName("foo", "", "")
+	if err == nil {
+		t.Fatalf("expected an error for an empty set\n")
+	}
+}
+
+func TestRepositorySetTies(t *testing.T) {
+	base := newTestXMLRepository(t, "testdata/primary.xml")
+	defer base.Close()
+	base.Name = "base"
+
+	mirror := newTestXMLRepository(t, "testdata/primary.xml")
+	defer mirror.Close()
+	mirror.Name = "mirror"
+
+	for _, table := range []struct {
+		name   string
+		base   int
+		mirror int
+		order  []*Repository
+		repo   string
+	}{
+		{"same-priority", 10, 10, []*Repository{base, mirror}, "base"},
+		{"same-priority-reversed", 10, 10, []*Repository{mirror, base}, "mirror"},
+		{"mirror-first", 10, 5, []*Repository{base, mirror}, "mirror"},
+		{"base-first", 5, 10, []*Repository{mirror, base}, "base"},
+	} {
+		base.Priority = table.base
+		mirror.Priority = table.mirror
+		set := NewRepositorySet(table.order...)
+
+		pkg, err := set.FindLatestMatchingName("foo", "", "")
+		if err != nil {
+			t.Fatalf("%s: could not find package: %v\n", table.name, err)
+		}
+		if pkg.ID() != "foo-2.0-1" {
+			t.Fatalf("%s: expected foo-2.0-1. got=%s\n", table.name, pkg.ID())
+		}
+		if pkg.Repository().Name != table.repo {
+			t.Fatalf("%s: expected package from [%s]. got=[%s]\n", table.name, table.repo, pkg.Repository().Name)
+		}
+	}
+}
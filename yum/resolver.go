@@ -0,0 +1,222 @@
+package yum
+
+import (
+	"fmt"
+	"strings"
+)
+
+// finder locates the package providing a given functionality.
+type finder interface {
+	FindLatestMatchingRequire(requirement *Requires) (*Package, error)
+}
+
+// MissingRequire is a requirement of a package which could not be satisfied.
+type MissingRequire struct {
+	Package  *Package // package declaring the requirement
+	Requires *Requires
+}
+
+// UnresolvedError is returned when some requirements could not be satisfied.
+type UnresolvedError struct {
+	Missing []MissingRequire
+}
+
+func (e *UnresolvedError) Error() string {
+	reqs := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		reqs = append(reqs, fmt.Sprintf("%s requires %s", m.Package.ID(), m.Requires.ID()))
+	}
+	return "yum: unresolved requirements: " + strings.Join(reqs, ", ")
+}
+
+// ConflictError is returned when two packages of a resolved set conflict.
+type ConflictError struct {
+	Package     *Package // package declaring the conflict
+	Conflicting *Package // package conflicting with Package
+	Capability  string   // conflicting capability
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("yum: package %s conflicts with %s (capability %s)",
+		e.Package.ID(), e.Conflicting.ID(), e.Capability,
+	)
+}
+
+// resolver computes the set of packages needed to install a list of packages.
+type resolver struct {
+	finder     finder
+	recommends bool               // whether to install the recommended packages
+	state      map[string]int     // visit state of packages, by key
+	selected   []*Package         // packages already selected, in order of selection
+	order      []*Package         // selected packages, dependencies first
+	edges      []depEdge          // requirements between selected packages
+	parents    map[string]depEdge // edge through which each package was first selected, by key (requested packages have none)
+	missing    []MissingRequire
+}
+
+// depEdge is a requirement of a package, satisfied by another package.
+type depEdge struct {
+	from     *Package // package declaring the requirement
+	to       *Package // package satisfying the requirement
+	requires *Requires
+	weak     bool // whether requires is a recommendation
+}
+
+// visit states of packages
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+// newResolver returns a resolver looking up the packages of repo, honoring
+// repo.ExcludeRecommends.
+func (repo *Repository) newResolver() *resolver {
+	r := newResolver(repo)
+	r.recommends = !repo.ExcludeRecommends
+	return r
+}
+
+func newResolver(f finder) *resolver {
+	return &resolver{
+		finder:     f,
+		recommends: true,
+		state:      make(map[string]int),
+		selected:   make([]*Package, 0),
+		order:      make([]*Package, 0),
+		edges:      make([]depEdge, 0),
+		parents:    make(map[string]depEdge),
+		missing:    make([]MissingRequire, 0),
+	}
+}
+
+// add adds pkg and all its dependencies to the resolved set.
+func (r *resolver) add(pkg *Package) {
+	if r.state[pkg.Key()] != unvisited {
+		return
+	}
+	r.state[pkg.Key()] = visiting
+	r.selected = append(r.selected, pkg)
+
+	for _, req := range pkg.Requires() {
+		if str_in_slice(req.Name(), IGNORED_PACKAGES) {
+			continue
+		}
+
+		if p := r.provider(req); p != nil {
+			r.edges = append(r.edges, depEdge{from: pkg, to: p, requires: req})
+			continue
+		}
+
+		p, err := r.finder.FindLatestMatchingRequire(req)
+		if err != nil || p == nil {
+			r.missing = append(r.missing, MissingRequire{Package: pkg, Requires: req})
+			continue
+		}
+
+		// a package being visited is part of a cycle: the cycle is broken here.
+		r.follow(depEdge{from: pkg, to: p, requires: req})
+	}
+
+	// recommended packages are installed when available: a missing one is
+	// not an error.
+	if r.recommends {
+		for _, req := range pkg.Recommends() {
+			if p := r.provider(req); p != nil {
+				r.edges = append(r.edges, depEdge{from: pkg, to: p, requires: req, weak: true})
+				continue
+			}
+			p, err := r.finder.FindLatestMatchingRequire(req)
+			if err != nil || p == nil {
+				continue
+			}
+			r.follow(depEdge{from: pkg, to: p, requires: req, weak: true})
+		}
+	}
+
+	r.state[pkg.Key()] = visited
+	r.order = append(r.order, pkg)
+}
+
+// follow records the edge and adds the package it leads to, with all its
+// dependencies, to the resolved set.
+func (r *resolver) follow(edge depEdge) {
+	r.edges = append(r.edges, edge)
+	if r.state[edge.to.Key()] == unvisited {
+		r.parents[edge.to.Key()] = edge
+	}
+	r.add(edge.to)
+}
+
+// provider returns the first already selected package satisfying req, if any.
+func (r *resolver) provider(req *Requires) *Package {
+	for _, pkg := range r.selected {
+		if pkg.Satisfies(req) {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// conflict returns the first conflict found among the selected packages, if any.
+// Two packages conflict when one of them declares a conflict matched by the
+// other one, or when they are different versions of the same package for the
+// same architecture. Multilib packages (e.g. glibc.x86_64 and glibc.i686)
+// may be installed together.
+func (r *resolver) conflict() *ConflictError {
+	for i, pkg := range r.order {
+		for _, other := range r.order[i+1:] {
+			if pkg.Name() == other.Name() && pkg.Arch() == other.Arch() {
+				return &ConflictError{Package: pkg, Conflicting: other, Capability: pkg.Name()}
+			}
+			if c := conflicts(pkg, other); c != nil {
+				return &ConflictError{Package: pkg, Conflicting: other, Capability: c.ID()}
+			}
+			if c := conflicts(other, pkg); c != nil {
+				return &ConflictError{Package: other, Conflicting: pkg, Capability: c.ID()}
+			}
+		}
+	}
+	return nil
+}
+
+// conflicts returns the conflict entry of pkg matched by other, if any.
+func conflicts(pkg, other *Package) *Requires {
+	for _, c := range pkg.Conflicts() {
+		if other.Satisfies(c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// result returns the resolved packages, dependencies first.
+// result returns a *ConflictError if some of these packages conflict, or
+// an *UnresolvedError if some requirements could not be satisfied.
+func (r *resolver) result() ([]*Package, error) {
+	pkgs := make([]*Package, len(r.order))
+	copy(pkgs, r.order)
+	if err := r.conflict(); err != nil {
+		return pkgs, err
+	}
+	if len(r.missing) > 0 {
+		return pkgs, &UnresolvedError{Missing: r.missing}
+	}
+	return pkgs, nil
+}
+
+// RequiredPackages returns the list of all packages needed to install pkg,
+// including pkg itself.
+// The list is topologically ordered: dependencies come before the packages
+// requiring them. Dependency cycles are broken arbitrarily.
+// If some requirements could not be satisfied, RequiredPackages returns the
+// packages it could resolve together with an *UnresolvedError.
+// If some of the resolved packages conflict, RequiredPackages returns them
+// together with a *ConflictError.
+// The packages recommended by the resolved packages are included, unless
+// repo.ExcludeRecommends is set. Suggested packages are never included.
+func (repo *Repository) RequiredPackages(pkg *Package) ([]*Package, error) {
+	r := repo.newResolver()
+	r.add(pkg)
+	return r.result()
+}
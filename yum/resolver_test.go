@@ -0,0 +1,202 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func pkgNames(pkgs []*Package) []string {
+	names := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name())
+	}
+	return names
+}
+
+func TestRepositoryRequiredPackages(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		name     string
+		expected []string
+		missing  []string
+	}{
+		{
+			name:     "bar",
+			expected: []string{"bar"},
+		},
+		{
+			name:     "foo",
+			expected: []string{"bar", "foo"},
+		},
+		{
+			name:     "cyc-a",
+			expected: []string{"cyc-b", "cyc-a"},
+		},
+		{
+			name:     "qux",
+			expected: []string{"bar", "foo", "qux"},
+			missing:  []string{"nosuchlib"},
+		},
+	} {
+		pkg, err := repo.FindLatestMatchingName(table.name, "", "")
+		if err != nil {
+			t.Fatalf("could not find package %q: %v\n", table.name, err)
+		}
+
+		pkgs, err := repo.RequiredPackages(pkg)
+		if len(table.missing) > 0 {
+			uerr, ok := err.(*UnresolvedError)
+			if !ok {
+				t.Fatalf("%s: expected an *UnresolvedError. got=%v\n", table.name, err)
+			}
+			missing := make([]string, 0, len(uerr.Missing))
+			for _, m := range uerr.Missing {
+				missing = append(missing, m.Requires.Name())
+			}
+			if !reflect.DeepEqual(missing, table.missing) {
+				t.Fatalf("%s: expected missing=%v. got=%v\n", table.name, table.missing, missing)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: could not resolve requirements: %v\n", table.name, err)
+		}
+
+		names := pkgNames(pkgs)
+		if !reflect.DeepEqual(names, table.expected) {
+			t.Fatalf("%s: expected %v. got=%v\n", table.name, table.expected, names)
+		}
+	}
+}
+
+func TestRepositoryConflicts(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	pkg, err := repo.FindLatestMatchingName("conflicted", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+
+	_, err = repo.RequiredPackages(pkg)
+	cerr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("expected a *ConflictError. got=%v\n", err)
+	}
+
+	if cerr.Package.Name() != "foo" || cerr.Conflicting.Name() != "baz" {
+		t.Fatalf("expected foo to conflict with baz. got=%v\n", cerr)
+	}
+}
+
+func TestRepositoryMultilibConflicts(t *testing.T) {
+	mkpkg := func(name, version, arch string, provides ...string) *Package {
+		pkg := NewPackage(name, version, "1", "0")
+		pkg.SetArch(arch)
+		pkg.SetLocation(name + "-" + version + "-1." + arch + ".rpm")
+		pkg.AddProvides(NewProvides(name, version, "1", "0", "EQ", nil))
+		for _, p := range provides {
+			pkg.AddProvides(NewProvides(p, version, "1", "0", "EQ", nil))
+		}
+		return pkg
+	}
+
+	app := mkpkg("app", "1.0", "x86_64")
+	app.AddRequires(NewRequires("libc.so.6()(64bit)", "2.17", "", "", "GE", ""))
+	app.AddRequires(NewRequires("libc.so.6", "", "", "", "", ""))
+
+	old := mkpkg("old", "1.0", "x86_64")
+	old.AddRequires(NewRequires("glibc", "2.12", "1", "0", "EQ", ""))
+	old.AddRequires(NewRequires("app", "", "", "", "", ""))
+
+	repo, err := NewRepository("memory", "http://dummy-url.org", "testdata/cachedir.tmp",
+		nil, false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.Backend = NewMemoryBackend([]*Package{
+		app, old,
+		mkpkg("glibc", "2.17", "x86_64", "libc.so.6()(64bit)"),
+		mkpkg("glibc", "2.17", "i686", "libc.so.6"),
+		mkpkg("glibc", "2.12", "x86_64", "libc.so.6()(64bit)"),
+	})
+	defer repo.Close()
+
+	// the 64bit and 32bit glibc may be installed together.
+	pkgs, err := repo.RequiredPackages(app)
+	if err != nil {
+		t.Fatalf("could not resolve requirements: %v\n", err)
+	}
+	var ids []string
+	for _, pkg := range pkgs {
+		ids = append(ids, pkg.NVRA())
+	}
+	want := []string{"glibc-2.17-1.x86_64", "glibc-2.17-1.i686", "app-1.0-1.x86_64"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("invalid packages.\ngot= %v\nwant=%v\n", ids, want)
+	}
+
+	// two versions of the 64bit glibc may not.
+	_, err = repo.RequiredPackages(old)
+	cerr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("expected a *ConflictError. got=%v\n", err)
+	}
+	if cerr.Package.NVRA() != "glibc-2.12-1.x86_64" || cerr.Conflicting.NVRA() != "glibc-2.17-1.x86_64" {
+		t.Fatalf("expected glibc-2.12 to conflict with glibc-2.17. got=%v\n", cerr)
+	}
+}
+
+func TestRepositoryWhatRequires(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	updates := newTestXMLRepository(t, "testdata/primary-updates.xml")
+	defer updates.Close()
+
+	libz := NewPackage("zlib", "1.2", "1", "0")
+	libz.AddProvides(NewProvides("libz", "1.2", "", "0", "EQ", nil))
+	mkpkg := func(name, flags, version string) *Package {
+		pkg := NewPackage(name, "1.0", "1", "0")
+		pkg.AddRequires(NewRequires("libz", version, "", "0", flags, ""))
+		return pkg
+	}
+	memory, err := NewRepository("memory", "http://dummy-url.org", "testdata/cachedir.tmp", nil, false, false)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	memory.Backend = NewMemoryBackend([]*Package{
+		libz, mkpkg("old", "GE", "1.0"), mkpkg("new", "GE", "2.0"),
+	})
+	defer memory.Close()
+
+	for _, table := range []struct {
+		repo       *Repository
+		capability string
+		want       []string
+	}{
+		{repo, "bar", []string{"foo-1.5-1", "foo-1.5-2", "foo-2.0-1"}},
+		{repo, "foo", []string{"conflicted-1.0-1", "qux-1.0-1"}},
+		{repo, "/usr/bin/bar", []string{"needsfile-1.0-1"}},
+		{repo, "nosuchpkg", []string{}},
+		// extra requires libfoo.so, provided by foo.
+		{updates, "foo", []string{"extra-1.0-1"}},
+		{updates, "libfoo.so", []string{"extra-1.0-1"}},
+		// only zlib-1.2 is available: new requires libz >= 2.0.
+		{memory, "zlib", []string{"old-1.0-1"}},
+		{memory, "libz", []string{"new-1.0-1", "old-1.0-1"}},
+	} {
+		pkgs, err := table.repo.WhatRequires(table.capability)
+		if err != nil {
+			t.Fatalf("%s: could not find requiring packages: %v\n", table.capability, err)
+		}
+		ids := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.ID())
+		}
+		if !reflect.DeepEqual(ids, table.want) {
+			t.Fatalf("%s: expected %v. got=%v\n", table.capability, table.want, ids)
+		}
+	}
+}
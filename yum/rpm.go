@@ -2,7 +2,7 @@ package yum
 
 import (
 	"fmt"
-	"strconv"
+	"sort"
 	"strings"
 )
 
@@ -74,6 +74,8 @@ func (rpm *rpmBase) ID() string {
 	return fmt.Sprintf("%s-%s-%s", str(rpm.name), str(rpm.version), str(rpm.release))
 }
 
+// ProvideMatches returns whether p satisfies rpm, when rpm is seen as a
+// requirement (name, flags and EVR range).
 func (rpm *rpmBase) ProvideMatches(p RPM) bool {
 
 	if p.Name() != rpm.Name() {
@@ -84,6 +86,11 @@ func (rpm *rpmBase) ProvideMatches(p RPM) bool {
 		return true
 	}
 
+	// an unversioned provide satisfies any version of the requirement
+	if p.Version() == "" {
+		return true
+	}
+
 	switch rpm.Flags() {
 	case "EQ", "eq", "==":
 		return RPMEqual(p, rpm)
@@ -102,11 +109,64 @@ func (rpm *rpmBase) ProvideMatches(p RPM) bool {
 	return false
 }
 
+// constraintOps maps the operators of version constraints to RPM flags,
+// longest operators first.
+var constraintOps = []struct{ op, flags string }{
+	{">=", "GE"},
+	{"<=", "LE"},
+	{"==", "EQ"},
+	{">", "GT"},
+	{"<", "LT"},
+	{"=", "EQ"},
+}
+
+// ParseConstraint parses the comma-separated version constraints of
+// constraint (e.g. ">=1.2,<2.0") on the package name into requirements.
+// Each constraint is an operator (>=, <=, >, <, = or ==) followed by a
+// [epoch:]version[-release]. An empty constraint matches any version.
+func ParseConstraint(name, constraint string) ([]*Requires, error) {
+	var reqs []*Requires
+	for _, c := range strings.Split(constraint, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		flags := ""
+		for _, op := range constraintOps {
+			if strings.HasPrefix(c, op.op) {
+				flags = op.flags
+				c = strings.TrimSpace(c[len(op.op):])
+				break
+			}
+		}
+		if flags == "" {
+			return nil, fmt.Errorf("yum: invalid constraint %q: missing operator", constraint)
+		}
+
+		epoch, version, release := "", c, ""
+		if i := strings.Index(version, ":"); i >= 0 {
+			epoch, version = version[:i], version[i+1:]
+		}
+		if i := strings.LastIndex(version, "-"); i >= 0 {
+			version, release = version[:i], version[i+1:]
+		}
+		if version == "" {
+			return nil, fmt.Errorf("yum: invalid constraint %q: missing version", constraint)
+		}
+		reqs = append(reqs, NewRequires(name, version, release, epoch, flags, ""))
+	}
+	return reqs, nil
+}
+
 func RPMEqual(i, j RPM) bool {
 	if i.Name() != j.Name() {
 		return false
 	}
-	if i.Version() != j.Version() {
+	if rpmEpochCompare(i.Epoch(), j.Epoch()) != 0 {
+		return false
+	}
+	if rpmvercmp(i.Version(), j.Version()) != 0 {
 		return false
 	}
 
@@ -115,7 +175,7 @@ func RPMEqual(i, j RPM) bool {
 		return true
 	}
 
-	return i.Release() == j.Release()
+	return rpmvercmp(i.Release(), j.Release()) == 0
 }
 
 func RPMLessThan(i, j RPM) bool {
@@ -123,34 +183,152 @@ func RPMLessThan(i, j RPM) bool {
 		return i.Name() < j.Name()
 	}
 
-	if i.Version() != j.Version() {
-		ii := i.StandardVersion()
-		jj := j.StandardVersion()
-		n := len(ii)
-		if n > len(jj) {
-			n = len(jj)
+	// if i or j misses a releases number, ignore release number
+	if i.Release() == "" || j.Release() == "" {
+		return RpmEvrCompare(i.Epoch(), i.Version(), "", j.Epoch(), j.Version(), "") < 0
+	}
+
+	return RpmEvrCompare(
+		i.Epoch(), i.Version(), i.Release(),
+		j.Epoch(), j.Version(), j.Release(),
+	) < 0
+}
+
+// RpmEvrCompare compares the epoch:version-release e1:v1-r1 with e2:v2-r2,
+// following the RPM version comparison algorithm.
+// RpmEvrCompare returns -1 if e1:v1-r1 is older than e2:v2-r2,
+// 0 if they are the same and +1 if e1:v1-r1 is newer than e2:v2-r2.
+// A missing epoch is treated as a 0 epoch.
+func RpmEvrCompare(e1, v1, r1, e2, v2, r2 string) int {
+	if c := rpmEpochCompare(e1, e2); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(v1, v2); c != 0 {
+		return c
+	}
+	return rpmvercmp(r1, r2)
+}
+
+// rpmEpochCompare compares two epochs, a missing epoch being a 0 epoch.
+func rpmEpochCompare(e1, e2 string) int {
+	epoch := func(e string) string {
+		e = strings.TrimLeft(e, "0")
+		if e == "" {
+			return "0"
 		}
-		for k := 0; k < n; k++ {
-			iiv, ierr := strconv.Atoi(ii[k])
-			jjv, jerr := strconv.Atoi(jj[k])
-			if ierr == nil && jerr == nil {
-				if iiv != jjv {
-					return iiv < jjv
-				}
-			} else {
-				if ii[k] != jj[k] {
-					return ii[k] < jj[k]
+		return e
+	}
+	return rpmvercmp(epoch(e1), epoch(e2))
+}
+
+// rpmvercmp compares two version (or release) strings a and b, as rpm does:
+// the strings are split into alternating segments of digits and letters,
+// compared segment by segment.
+// Numeric segments are compared numerically and are newer than alphabetic ones.
+// A tilde (~) sorts before everything, even the end of the string (pre-releases),
+// a caret (^) sorts after the end of the string but before anything else (post-releases).
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	isdigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	isalpha := func(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
+	isalnum := func(c byte) bool { return isdigit(c) || isalpha(c) }
+	span := func(s string, pred func(c byte) bool) int {
+		i := 0
+		for i < len(s) && pred(s[i]) {
+			i++
+		}
+		return i
+	}
+	separator := func(c byte) bool { return !isalnum(c) && c != '~' && c != '^' }
+
+	one, two := a, b
+	for len(one) > 0 || len(two) > 0 {
+		one = one[span(one, separator):]
+		two = two[span(two, separator):]
+
+		// handle the tilde separator, it sorts before everything else
+		if strings.HasPrefix(one, "~") || strings.HasPrefix(two, "~") {
+			if !strings.HasPrefix(one, "~") {
+				return +1
+			}
+			if !strings.HasPrefix(two, "~") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		// handle the caret separator: it sorts after the end of the string
+		// but before anything else.
+		if strings.HasPrefix(one, "^") || strings.HasPrefix(two, "^") {
+			if len(one) == 0 {
+				return -1
+			}
+			if len(two) == 0 {
+				return +1
+			}
+			if !strings.HasPrefix(one, "^") {
+				return +1
+			}
+			if !strings.HasPrefix(two, "^") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		if len(one) == 0 || len(two) == 0 {
+			break
+		}
+
+		// grab the first completely alpha or completely numeric segment
+		pred := isalpha
+		isnum := isdigit(one[0])
+		if isnum {
+			pred = isdigit
+		}
+		n1 := span(one, pred)
+		n2 := span(two, pred)
+		seg1, seg2 := one[:n1], two[:n2]
+		one, two = one[n1:], two[n2:]
+
+		// segments of different types: numeric segments are newer.
+		if len(seg2) == 0 {
+			if isnum {
+				return +1
+			}
+			return -1
+		}
+
+		if isnum {
+			// throw away leading zeros.
+			// then, whichever number has more digits wins.
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+			if len(seg1) != len(seg2) {
+				if len(seg1) > len(seg2) {
+					return +1
 				}
+				return -1
 			}
 		}
-		return i.Version() < j.Version()
+
+		if c := strings.Compare(seg1, seg2); c != 0 {
+			return c
+		}
 	}
 
-	// if i or j misses a releases number, ignore release number
-	if i.Release() == "" || j.Release() == "" {
-		return i.Version() < j.Version()
+	// whichever version still has characters left over wins.
+	switch {
+	case len(one) == 0 && len(two) == 0:
+		return 0
+	case len(one) == 0:
+		return -1
 	}
-	return i.Release() < j.Release()
+	return +1
 }
 
 // Provides represents a functionality provided by a RPM package
@@ -191,6 +369,15 @@ func NewRequires(name, version, release, epoch, flags string, pre string) *Requi
 	}
 }
 
+// PackageInfo holds the descriptive metadata of a package.
+type PackageInfo struct {
+	Summary     string
+	Description string
+	Packager    string
+	URL         string // URL of the upstream project
+	License     string
+}
+
 // Package represents a RPM package in a YUM repository
 type Package struct {
 	rpmBase
@@ -198,9 +385,19 @@ type Package struct {
 	group      string
 	arch       string
 	location   string
+	size       int64 // size of the RPM file, 0 if unknown
+	isize      int64 // size of the installed package, 0 if unknown
+	checksum   string
+	chksumType string       // type of checksum (sha, sha256, md5)
+	info       *PackageInfo // descriptive metadata, nil if loaded on demand
 	requires   []*Requires
 	provides   []*Provides
+	obsoletes  []*Requires // packages obsoleted by this package
+	conflicts  []*Requires // packages conflicting with this package
+	recommends []*Requires // weak dependencies, installed unless excluded
+	suggests   []*Requires // weak dependencies, never installed automatically
 	repository *Repository
+	backend    string // name of the type of the backend which loaded the package
 }
 
 // NewPackage creates a new RPM package
@@ -259,6 +456,118 @@ func (pkg *Package) Location() string {
 	return pkg.location
 }
 
+// NEVRA returns the canonical name-[epoch:]version-release.arch string of
+// the package. The epoch is left out when empty or 0.
+func (pkg *Package) NEVRA() string {
+	version := pkg.Version()
+	if epoch := pkg.Epoch(); epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+	return fmt.Sprintf("%s-%s-%s.%s", pkg.Name(), version, pkg.Release(), pkg.Arch())
+}
+
+// NVRA returns the name-version-release.arch string of the package, as used
+// for the names of RPM files.
+func (pkg *Package) NVRA() string {
+	return fmt.Sprintf("%s-%s-%s.%s", pkg.Name(), pkg.Version(), pkg.Release(), pkg.Arch())
+}
+
+// Key returns a string identifying the package, e.g. to deduplicate the
+// packages found in several repositories or mirrors: packages with the same
+// name, epoch, version, release and arch have the same key. A 0 epoch is the
+// same as no epoch.
+func (pkg *Package) Key() string {
+	return pkg.NEVRA()
+}
+
+// Equal returns whether pkg and other are the same package, according to
+// their keys.
+func (pkg *Package) Equal(other *Package) bool {
+	if pkg == nil || other == nil {
+		return pkg == other
+	}
+	return pkg == other || pkg.Key() == other.Key()
+}
+
+// ParseNEVRA splits a name-[epoch:]version-release.arch string, as returned
+// by Package.NEVRA, into its components.
+// epoch is empty if s has no epoch.
+func ParseNEVRA(s string) (name, epoch, version, release, arch string, err error) {
+	invalid := func(why string) error {
+		return fmt.Errorf("yum: invalid NEVRA %q: %s", s, why)
+	}
+
+	rest := s
+	i := strings.LastIndex(rest, ".")
+	if i < 0 || i < strings.LastIndex(rest, "-") {
+		return "", "", "", "", "", invalid("missing architecture")
+	}
+	rest, arch = rest[:i], rest[i+1:]
+
+	i = strings.LastIndex(rest, "-")
+	if i < 0 {
+		return "", "", "", "", "", invalid("missing release")
+	}
+	rest, release = rest[:i], rest[i+1:]
+
+	i = strings.LastIndex(rest, "-")
+	if i < 0 {
+		return "", "", "", "", "", invalid("missing version")
+	}
+	name, version = rest[:i], rest[i+1:]
+
+	if i := strings.Index(version, ":"); i >= 0 {
+		epoch, version = version[:i], version[i+1:]
+		if epoch == "" {
+			return "", "", "", "", "", invalid("empty epoch")
+		}
+	}
+
+	if name == "" || version == "" || release == "" || arch == "" {
+		return "", "", "", "", "", invalid("empty component")
+	}
+	return name, epoch, version, release, arch, nil
+}
+
+// Size returns the size of the RPM file of the package, 0 if unknown.
+func (pkg *Package) Size() int64 {
+	return pkg.size
+}
+
+// InstallSize returns the size of the installed package, 0 if unknown.
+func (pkg *Package) InstallSize() int64 {
+	return pkg.isize
+}
+
+// Checksum returns the checksum of the RPM file of the package, empty if unknown.
+func (pkg *Package) Checksum() string {
+	return pkg.checksum
+}
+
+// ChecksumType returns the type of the checksum of the RPM file of the package.
+func (pkg *Package) ChecksumType() string {
+	return pkg.chksumType
+}
+
+// Info returns the descriptive metadata of the package (summary,
+// description, license, ...).
+// Backends keeping these large fields out of memory load them on demand.
+func (pkg *Package) Info() (PackageInfo, error) {
+	type infoLoader interface {
+		loadInfo(pkg *Package) (PackageInfo, error)
+	}
+
+	if pkg.info != nil {
+		return *pkg.info, nil
+	}
+	if pkg.repository != nil {
+		if l, ok := pkg.repository.Backend.(infoLoader); ok {
+			return l.loadInfo(pkg)
+		}
+	}
+	return PackageInfo{}, nil
+}
+
 func (pkg *Package) Requires() []*Requires {
 	return pkg.requires
 }
@@ -267,12 +576,133 @@ func (pkg *Package) Provides() []*Provides {
 	return pkg.provides
 }
 
+// Obsoletes returns the list of packages this package obsoletes.
+func (pkg *Package) Obsoletes() []*Requires {
+	return pkg.obsoletes
+}
+
+// Conflicts returns the list of packages this package conflicts with.
+func (pkg *Package) Conflicts() []*Requires {
+	return pkg.conflicts
+}
+
+// Recommends returns the weak dependencies of this package, installed with it
+// unless the repository excludes them.
+func (pkg *Package) Recommends() []*Requires {
+	return pkg.recommends
+}
+
+// Suggests returns the weak dependencies of this package which are never
+// installed automatically.
+func (pkg *Package) Suggests() []*Requires {
+	return pkg.suggests
+}
+
+// SetArch sets the architecture of the package.
+func (pkg *Package) SetArch(arch string) {
+	pkg.arch = arch
+}
+
+// SetInfo sets the descriptive metadata of the package.
+func (pkg *Package) SetInfo(info PackageInfo) {
+	pkg.info = &info
+}
+
+// SetLocation sets the location of the RPM file of the package, relative to
+// the base URL of its repository.
+func (pkg *Package) SetLocation(location string) {
+	pkg.location = location
+}
+
+// AddRequires adds reqs to the requirements of the package.
+func (pkg *Package) AddRequires(reqs ...*Requires) {
+	pkg.requires = append(pkg.requires, reqs...)
+}
+
+// AddProvides adds provs to the capabilities provided by the package.
+func (pkg *Package) AddProvides(provs ...*Provides) {
+	for _, prov := range provs {
+		prov.Package = pkg
+	}
+	pkg.provides = append(pkg.provides, provs...)
+}
+
+// AddObsoletes adds obs to the packages obsoleted by the package.
+func (pkg *Package) AddObsoletes(obs ...*Requires) {
+	pkg.obsoletes = append(pkg.obsoletes, obs...)
+}
+
+// AddConflicts adds conflicts to the packages the package conflicts with.
+func (pkg *Package) AddConflicts(conflicts ...*Requires) {
+	pkg.conflicts = append(pkg.conflicts, conflicts...)
+}
+
+// AddRecommends adds recs to the weak dependencies recommended by the package.
+func (pkg *Package) AddRecommends(recs ...*Requires) {
+	pkg.recommends = append(pkg.recommends, recs...)
+}
+
+// AddSuggests adds sugs to the weak dependencies suggested by the package.
+func (pkg *Package) AddSuggests(sugs ...*Requires) {
+	pkg.suggests = append(pkg.suggests, sugs...)
+}
+
+// ProvidesCapability returns whether pkg provides the capability name,
+// whatever its version.
+func (pkg *Package) ProvidesCapability(name string) bool {
+	if pkg.Name() == name {
+		return true
+	}
+	for _, prov := range pkg.provides {
+		if prov.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfies returns whether pkg (or one of the capabilities it provides)
+// satisfies the requirement req, taking into account its flags and EVR range.
+func (pkg *Package) Satisfies(req *Requires) bool {
+	if req.ProvideMatches(pkg) {
+		return true
+	}
+	for _, prov := range pkg.provides {
+		if req.ProvideMatches(prov) {
+			return true
+		}
+	}
+	return false
+}
+
 func (pkg *Package) Repository() *Repository {
 	return pkg.repository
 }
 
+// RepositoryName returns the name of the repository providing the package,
+// empty if unknown.
+func (pkg *Package) RepositoryName() string {
+	if pkg.repository == nil {
+		return ""
+	}
+	return pkg.repository.Name
+}
+
+// BackendName returns the name of the type of the backend which loaded the
+// package (e.g. RepositorySQLiteBackend), empty if unknown.
+func (pkg *Package) BackendName() string {
+	return pkg.backend
+}
+
 func (pkg *Package) Url() string {
-	return pkg.repository.RepoUrl + "/" + pkg.location
+	if pkg.repository == nil {
+		return pkg.location
+	}
+	url, err := joinURL(pkg.repository.RepoUrl, pkg.location)
+	if err != nil {
+		return pkg.repository.RepoUrl + "/" + pkg.location
+	}
+	return url
 }
 
 type Packages []*Package
@@ -292,6 +722,24 @@ func (p Packages) Less(i, j int) bool {
 	return RPMLessThan(pi, pj)
 }
 
+// sortPackages sorts pkgs by name, then epoch:version-release, then arch.
+func sortPackages(pkgs []*Package) {
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		pi, pj := pkgs[i], pkgs[j]
+		if pi.Name() != pj.Name() {
+			return pi.Name() < pj.Name()
+		}
+		c := RpmEvrCompare(
+			pi.Epoch(), pi.Version(), pi.Release(),
+			pj.Epoch(), pj.Version(), pj.Release(),
+		)
+		if c != 0 {
+			return c < 0
+		}
+		return pi.Arch() < pj.Arch()
+	})
+}
+
 type RPMSlice []RPM
 
 func (p RPMSlice) Len() int {
@@ -211,4 +211,276 @@ func TestOrderWithDifferentName(t *testing.T) {
 	}
 }
 
+func TestRpmVerCmp(t *testing.T) {
+	// test cases taken from rpm's rpmvercmp.at
+	for _, table := range []struct {
+		a, b string
+		exp  int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1", "2.0", 1},
+
+		{"2.0.1a", "2.0.1a", 0},
+		{"2.0.1a", "2.0.1", 1},
+		{"2.0.1", "2.0.1a", -1},
+
+		{"5.5p1", "5.5p1", 0},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p2", "5.5p1", 1},
+
+		{"5.5p10", "5.5p10", 0},
+		{"5.5p1", "5.5p10", -1},
+		{"5.5p10", "5.5p1", 1},
+
+		{"10xyz", "10.1xyz", -1},
+		{"10.1xyz", "10xyz", 1},
+
+		{"xyz10", "xyz10", 0},
+		{"xyz10", "xyz10.1", -1},
+		{"xyz10.1", "xyz10", 1},
+
+		{"xyz.4", "xyz.4", 0},
+		{"xyz.4", "8", -1},
+		{"8", "xyz.4", 1},
+		{"xyz.4", "2", -1},
+		{"2", "xyz.4", 1},
+
+		{"5.5p2", "5.6p1", -1},
+		{"5.6p1", "5.5p2", 1},
+
+		{"5.6p1", "6.5p1", -1},
+		{"6.5p1", "5.6p1", 1},
+
+		{"6.0.rc1", "6.0", 1},
+		{"6.0", "6.0.rc1", -1},
+
+		{"10b2", "10a1", 1},
+		{"10a2", "10b2", -1},
+
+		{"1.0aa", "1.0aa", 0},
+		{"1.0a", "1.0aa", -1},
+		{"1.0aa", "1.0a", 1},
+
+		{"10.0001", "10.0001", 0},
+		{"10.0001", "10.1", 0},
+		{"10.1", "10.0001", 0},
+		{"10.0001", "10.0039", -1},
+		{"10.0039", "10.0001", 1},
+
+		{"4.999.9", "5.0", -1},
+		{"5.0", "4.999.9", 1},
+
+		{"20101121", "20101121", 0},
+		{"20101121", "20101122", -1},
+		{"20101122", "20101121", 1},
+
+		{"2_0", "2_0", 0},
+		{"2.0", "2_0", 0},
+		{"2_0", "2.0", 0},
+
+		{"a", "a", 0},
+		{"a+", "a+", 0},
+		{"a+", "a_", 0},
+		{"a_", "a+", 0},
+		{"+a", "+a", 0},
+		{"+a", "_a", 0},
+		{"_a", "+a", 0},
+		{"+_", "+_", 0},
+		{"_+", "+_", 0},
+		{"_+", "_+", 0},
+		{"+", "_", 0},
+		{"_", "+", 0},
+
+		{"1.0~rc1", "1.0~rc1", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0~rc1", 1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc2", "1.0~rc1", 1},
+		{"1.0~rc1~git123", "1.0~rc1~git123", 0},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0~rc1", "1.0~rc1~git123", 1},
+
+		{"1.0^", "1.0^", 0},
+		{"1.0^", "1.0", 1},
+		{"1.0", "1.0^", -1},
+		{"1.0^git1", "1.0^git1", 0},
+		{"1.0^git1", "1.0", 1},
+		{"1.0", "1.0^git1", -1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0^git2", "1.0^git1", 1},
+		{"1.0^git1", "1.01", -1},
+		{"1.01", "1.0^git1", 1},
+		{"1.0^20160101", "1.0^20160101", 0},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0.1", "1.0^20160101", 1},
+		{"1.0^20160101^git1", "1.0^20160101^git1", 0},
+		{"1.0^20160102", "1.0^20160101^git1", 1},
+		{"1.0^20160101^git1", "1.0^20160102", -1},
+		{"1.0~rc1^git1", "1.0~rc1^git1", 0},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0~rc1", "1.0~rc1^git1", -1},
+		{"1.0^git1~pre", "1.0^git1~pre", 0},
+		{"1.0^git1", "1.0^git1~pre", 1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+
+		{"1.9", "1.10", -1},
+		{"1.10", "1.9", 1},
+	} {
+		o := rpmvercmp(table.a, table.b)
+		if o != table.exp {
+			t.Errorf("rpmvercmp(%q, %q): expected %d. got=%d\n", table.a, table.b, table.exp, o)
+		}
+	}
+}
+
+func TestRpmEvrCompare(t *testing.T) {
+	for _, table := range []struct {
+		e1, v1, r1 string
+		e2, v2, r2 string
+		exp        int
+	}{
+		{"", "1.0", "1", "0", "1.0", "1", 0},
+		{"1", "1.0", "1", "0", "2.0", "1", 1},
+		{"0", "2.0", "1", "1", "1.0", "1", -1},
+		{"0", "1.0", "9", "0", "1.0", "10", -1},
+		{"0", "1.10", "1", "0", "1.9", "1", 1},
+		{"0", "1.0~rc1", "1", "0", "1.0", "1", -1},
+	} {
+		o := RpmEvrCompare(table.e1, table.v1, table.r1, table.e2, table.v2, table.r2)
+		if o != table.exp {
+			t.Errorf("RpmEvrCompare(%s:%s-%s, %s:%s-%s): expected %d. got=%d\n",
+				table.e1, table.v1, table.r1,
+				table.e2, table.v2, table.r2,
+				table.exp, o,
+			)
+		}
+	}
+}
+
+func TestPackageSatisfies(t *testing.T) {
+	pkg := NewPackage("glibc", "2.17", "1", "0")
+	pkg.provides = append(pkg.provides,
+		NewProvides("glibc", "2.17", "1", "0", "EQ", pkg),
+		NewProvides("libc.so.6(GLIBC_2.14)(64bit)", "", "", "", "", pkg),
+		NewProvides("config(glibc)", "2.17", "1", "0", "EQ", pkg),
+	)
+
+	for _, table := range []struct {
+		req *Requires
+		exp bool
+	}{
+		{NewRequires("glibc", "", "", "", "EQ", ""), true},
+		{NewRequires("glibc", "2.17", "", "", "EQ", ""), true},
+		{NewRequires("glibc", "2.17", "2", "", "EQ", ""), false},
+		{NewRequires("glibc", "2.12", "", "", "GE", ""), true},
+		{NewRequires("glibc", "2.18", "", "", "GE", ""), false},
+		{NewRequires("glibc", "3.0", "", "", "LT", ""), true},
+		{NewRequires("libc.so.6(GLIBC_2.14)(64bit)", "", "", "", "EQ", ""), true},
+		{NewRequires("libc.so.6(GLIBC_2.14)(64bit)", "1.0", "", "", "GE", ""), true},
+		{NewRequires("libc.so.6(GLIBC_2.18)(64bit)", "", "", "", "EQ", ""), false},
+		{NewRequires("config(glibc)", "2.17", "1", "", "LE", ""), true},
+		{NewRequires("config(glibc)", "2.17", "1", "", "LT", ""), false},
+	} {
+		o := pkg.Satisfies(table.req)
+		if o != table.exp {
+			t.Errorf("%s satisfies %s %s %s: expected %v. got=%v\n",
+				pkg.ID(), table.req.Name(), table.req.Flags(), table.req.Version(), table.exp, o,
+			)
+		}
+	}
+
+	if !pkg.ProvidesCapability("libc.so.6(GLIBC_2.14)(64bit)") {
+		t.Errorf("expected %s to provide libc.so.6(GLIBC_2.14)(64bit)\n", pkg.ID())
+	}
+	if pkg.ProvidesCapability("libm.so.6") {
+		t.Errorf("expected %s to NOT provide libm.so.6\n", pkg.ID())
+	}
+}
+
 // EOF
+
+func TestPackageNEVRA(t *testing.T) {
+	for _, table := range []struct {
+		pkg   *Package
+		nevra string
+		nvra  string
+	}{
+		{NewPackage("glibc", "2.17", "1.el7", "0"), "glibc-2.17-1.el7.x86_64", "glibc-2.17-1.el7.x86_64"},
+		{NewPackage("glibc", "2.17", "1.el7", ""), "glibc-2.17-1.el7.x86_64", "glibc-2.17-1.el7.x86_64"},
+		{NewPackage("perl-libs", "5.16.3", "299.el7", "4"), "perl-libs-4:5.16.3-299.el7.x86_64", "perl-libs-5.16.3-299.el7.x86_64"},
+	} {
+		table.pkg.arch = "x86_64"
+		if got := table.pkg.NEVRA(); got != table.nevra {
+			t.Errorf("invalid NEVRA. got=%q. want=%q\n", got, table.nevra)
+		}
+		if got := table.pkg.NVRA(); got != table.nvra {
+			t.Errorf("invalid NVRA. got=%q. want=%q\n", got, table.nvra)
+		}
+
+		name, epoch, version, release, arch, err := ParseNEVRA(table.pkg.NEVRA())
+		if err != nil {
+			t.Errorf("could not parse %q: %v\n", table.pkg.NEVRA(), err)
+			continue
+		}
+		want := table.pkg.Epoch()
+		if want == "0" {
+			want = ""
+		}
+		if name != table.pkg.Name() || epoch != want || version != table.pkg.Version() ||
+			release != table.pkg.Release() || arch != table.pkg.Arch() {
+			t.Errorf("invalid round-trip for %q: name=%q epoch=%q version=%q release=%q arch=%q\n",
+				table.pkg.NEVRA(), name, epoch, version, release, arch,
+			)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"glibc",
+		"glibc-2.17-1",
+		"glibc-2.17.x86_64",
+		"glibc-:2.17-1.x86_64",
+		"-2.17-1.x86_64",
+		"glibc-2.17-1.",
+	} {
+		_, _, _, _, _, err := ParseNEVRA(s)
+		if err == nil {
+			t.Errorf("expected an error parsing %q\n", s)
+		}
+	}
+}
+
+func TestPackageEqual(t *testing.T) {
+	newPkg := func(epoch, arch string) *Package {
+		pkg := NewPackage("foo", "1.0", "1", epoch)
+		pkg.arch = arch
+		return pkg
+	}
+
+	for _, table := range []struct {
+		a, b  *Package
+		equal bool
+	}{
+		{newPkg("0", "x86_64"), newPkg("0", "x86_64"), true},
+		{newPkg("0", "x86_64"), newPkg("", "x86_64"), true},
+		{newPkg("1", "x86_64"), newPkg("0", "x86_64"), false},
+		{newPkg("0", "x86_64"), newPkg("0", "i686"), false},
+		{newPkg("0", "x86_64"), nil, false},
+		{nil, nil, true},
+	} {
+		if got := table.a.Equal(table.b); got != table.equal {
+			t.Errorf("%v.Equal(%v): got=%v. want=%v\n", table.a, table.b, got, table.equal)
+		}
+		if table.a == nil || table.b == nil {
+			continue
+		}
+		if got := table.a.Key() == table.b.Key(); got != table.equal {
+			t.Errorf("keys %q and %q: equal=%v. want=%v\n", table.a.Key(), table.b.Key(), got, table.equal)
+		}
+	}
+}
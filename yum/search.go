@@ -0,0 +1,73 @@
+package yum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Search returns the packages whose name, summary or description contains
+// term, ignoring case.
+// Packages are sorted by relevance: packages named term first, then packages
+// whose name contains term, then the other ones. Packages of equal relevance
+// are sorted by name and version.
+// Excluded packages are left out.
+func (repo *Repository) Search(term string) ([]*Package, error) {
+	type searcher interface {
+		search(term string) ([]*Package, error)
+	}
+
+	if term == "" {
+		return nil, fmt.Errorf("yum: empty search term")
+	}
+
+	var pkgs Packages
+	if s, ok := repo.Backend.(searcher); ok {
+		found, err := s.search(term)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = repo.withoutExcluded(found)
+	} else {
+		pkgs = make(Packages, 0)
+		lterm := strings.ToLower(term)
+		err := repo.WalkPackages(func(pkg *Package) error {
+			if strings.Contains(strings.ToLower(pkg.Name()), lterm) {
+				pkgs = append(pkgs, pkg)
+				return nil
+			}
+			info, err := pkg.Info()
+			if err != nil {
+				return err
+			}
+			if strings.Contains(strings.ToLower(info.Summary), lterm) ||
+				strings.Contains(strings.ToLower(info.Description), lterm) {
+				pkgs = append(pkgs, pkg)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(pkgs)
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return searchRank(pkgs[i], term) < searchRank(pkgs[j], term)
+	})
+	return pkgs, nil
+}
+
+// searchRank returns the relevance of pkg for the search term, lower ranks
+// being more relevant.
+func searchRank(pkg *Package, term string) int {
+	name := strings.ToLower(pkg.Name())
+	term = strings.ToLower(term)
+	switch {
+	case name == term:
+		return 0
+	case strings.Contains(name, term):
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,38 @@
+package yum
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepositorySearch(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		term     string
+		expected []string
+	}{
+		{"FOO", []string{"foo-1.5-1", "foo-1.5-2", "foo-2.0-1", "bar-1.0-1", "baz-1.0-1"}},
+		{"cyc", []string{"cyc-a-1.0-1", "cyc-b-1.0-1"}},
+		{"missing library", []string{"qux-1.0-1"}},
+		{"no-such-term", []string{}},
+	} {
+		pkgs, err := repo.Search(table.term)
+		if err != nil {
+			t.Fatalf("search %q: %v\n", table.term, err)
+		}
+		got := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			got = append(got, pkg.ID())
+		}
+		if !reflect.DeepEqual(got, table.expected) {
+			t.Fatalf("search %q: expected %v. got=%v\n", table.term, table.expected, got)
+		}
+	}
+
+	_, err := repo.Search("")
+	if err == nil {
+		t.Fatalf("expected an error for an empty search term\n")
+	}
+}
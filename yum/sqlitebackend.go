@@ -1,19 +1,24 @@
 package yum
 
 import (
-	"compress/bzip2"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
-	"github.com/gonuts/logger"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// sqliteDBVersion is the version of the YUM SQLite schema supported by the
+// backend.
+const sqliteDBVersion = 10
+
 // RepositorySQLiteBackend is Backend querying YUM SQLite repositories
 type RepositorySQLiteBackend struct {
 	Name         string
@@ -23,7 +28,8 @@ type RepositorySQLiteBackend struct {
 	Primary      string
 	Repository   *Repository
 	db           *sql.DB
-	msg          *logger.Logger
+	tables       map[string]bool // tables of the DB (weak dependencies are not always there)
+	msg          Logger
 }
 
 func NewRepositorySQLiteBackend(repo *Repository) (*RepositorySQLiteBackend, error) {
@@ -54,6 +60,7 @@ func (repo *RepositorySQLiteBackend) Close() error {
 		if err != nil {
 			repo.msg.Errorf("problem disconnecting db: %v\n", err)
 		}
+		repo.db = nil
 	}
 	repo.msg.Debugf("removing [%s]...\n", repo.Primary)
 	if path_exists(repo.Primary) {
@@ -79,7 +86,7 @@ func (repo *RepositorySQLiteBackend) GetLatestDB(url string) error {
 	defer tmp.Close()
 	defer os.RemoveAll(tmp.Name())
 
-	r, err := getRemoteData(url)
+	r, err := repo.Repository.download(repo.Repository.context(), url)
 	if err != nil {
 		return err
 	}
@@ -90,12 +97,6 @@ func (repo *RepositorySQLiteBackend) GetLatestDB(url string) error {
 	}
 
 	repo.msg.Debugf("decompressing latest version of SQLite DB\n")
-	dbfile, err := os.Create(repo.Primary)
-	if err != nil {
-		return err
-	}
-	defer dbfile.Close()
-
 	err = tmp.Sync()
 	if err != nil {
 		return err
@@ -105,21 +106,22 @@ func (repo *RepositorySQLiteBackend) GetLatestDB(url string) error {
 		return err
 	}
 
-	err = repo.decompress(dbfile, tmp)
+	err = write_file_atomic(repo.Primary, func(w io.Writer) error {
+		return repo.decompress(w, tmp, url)
+	})
 	if err != nil {
 		return err
 	}
 
 	// copy tmp file content to repo.PrimaryCompr
 	_, err = tmp.Seek(0, 0)
-	out, err := os.Create(repo.PrimaryCompr)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, tmp)
-	return err
+	return write_file_atomic(repo.PrimaryCompr, func(w io.Writer) error {
+		_, err := io.Copy(w, tmp)
+		return err
+	})
 }
 
 // Check whether the DB is there
@@ -127,6 +129,16 @@ func (repo *RepositorySQLiteBackend) HasDB() bool {
 	return path_exists(repo.PrimaryCompr)
 }
 
+// dbFile returns the path to the DB, as downloaded, in the cache directory.
+func (repo *RepositorySQLiteBackend) dbFile() string {
+	return repo.PrimaryCompr
+}
+
+// cacheFiles returns the files of the backend in the cache directory.
+func (repo *RepositorySQLiteBackend) cacheFiles() []string {
+	return []string{repo.PrimaryCompr, repo.Primary}
+}
+
 // Load loads the DB
 func (repo *RepositorySQLiteBackend) LoadDB() error {
 	var err error
@@ -142,16 +154,75 @@ func (repo *RepositorySQLiteBackend) LoadDB() error {
 	if err != nil {
 		return err
 	}
+
+	// sql.Open does not touch the file: make sure it is a DB we can query.
+	tables, err := sqliteTables(db)
+	if err == nil {
+		err = checkSQLiteSchema(db, tables)
+	}
+	if err != nil {
+		db.Close()
+		os.RemoveAll(repo.Primary)
+		return fmt.Errorf("yum: invalid SQLite DB [%s]: %w", repo.Primary, err)
+	}
+
+	if repo.db != nil {
+		// reloading the DB, e.g. after a refresh.
+		repo.db.Close()
+	}
 	repo.db = db
+	repo.tables = tables
 	return err
 }
 
+// checkSQLiteSchema checks db is a YUM SQLite DB whose schema version, as
+// recorded in its db_info table, is supported by the backend.
+// tables are the tables of db (see sqliteTables).
+func checkSQLiteSchema(db *sql.DB, tables map[string]bool) error {
+	if !tables["db_info"] {
+		return fmt.Errorf("%w: no db_info table", ErrUnsupportedSchema)
+	}
+
+	var version int
+	err := db.QueryRow("select dbversion from db_info").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: empty db_info table", ErrUnsupportedSchema)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read schema version: %w", err)
+	}
+	if version != sqliteDBVersion {
+		return fmt.Errorf("%w: version %d (supported: %d)", ErrUnsupportedSchema, version, sqliteDBVersion)
+	}
+	return nil
+}
+
+// sqliteTables returns the names of the tables of db.
+func sqliteTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("select name from sqlite_master where type = 'table'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
 // FindLatestMatchingName locates a package by name, returns the latest available version.
 func (repo *RepositorySQLiteBackend) FindLatestMatchingName(name, version, release string) (*Package, error) {
 	var pkg *Package
 	var err error
 
-	pkgs, err := repo.loadPackagesByName(name, version)
+	pkgs, keys, err := repo.loadPackagesByName(name, version)
 	if err != nil {
 		return nil, err
 	}
@@ -170,9 +241,36 @@ func (repo *RepositorySQLiteBackend) FindLatestMatchingName(name, version, relea
 
 	sort.Sort(matching)
 	pkg = matching[len(matching)-1].(*Package)
+	err = repo.loadDeps(keys[pkg], pkg)
+	if err != nil {
+		return nil, err
+	}
 	return pkg, nil
 }
 
+// FindAllMatchingName locates all the packages with a given name, sorted from
+// the newest to the oldest version.
+func (repo *RepositorySQLiteBackend) FindAllMatchingName(name string) ([]*Package, error) {
+	pkgs, keys, err := repo.loadPackagesByName(name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) <= 0 {
+		return nil, fmt.Errorf("no such package %q", name)
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Stable(sort.Reverse(Packages(pkgs)))
+	return pkgs, nil
+}
+
 // FindLatestMatchingRequire locates a package providing a given functionality.
 func (repo *RepositorySQLiteBackend) FindLatestMatchingRequire(requirement *Requires) (*Package, error) {
 	var pkg *Package
@@ -202,7 +300,7 @@ func (repo *RepositorySQLiteBackend) FindLatestMatchingRequire(requirement *Requ
 	// now look-up the matching package
 	sort.Sort(matching)
 	prov := matching[len(matching)-1].(*Provides)
-	pkgs, err := repo.loadPackagesProviding(prov)
+	pkgs, keys, err := repo.loadPackagesProviding(prov)
 	if err != nil {
 		return nil, err
 	}
@@ -219,6 +317,10 @@ func (repo *RepositorySQLiteBackend) FindLatestMatchingRequire(requirement *Requ
 
 	sort.Sort(matching)
 	pkg = matching[len(matching)-1].(*Package)
+	err = repo.loadDeps(keys[pkg], pkg)
+	if err != nil {
+		return nil, err
+	}
 
 	repo.msg.Debugf("found %d version matching - returning latest: %s.%s-%s\n", len(matching), pkg.Name(), pkg.Version(), pkg.Release())
 	return pkg, err
@@ -226,7 +328,7 @@ func (repo *RepositorySQLiteBackend) FindLatestMatchingRequire(requirement *Requ
 
 // GetPackages returns all the packages known by a YUM repository
 func (repo *RepositorySQLiteBackend) GetPackages() []*Package {
-	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href from packages"
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, pkgid, checksum_type from packages"
 	stmt, err := repo.db.Prepare(query)
 	if err != nil {
 		repo.msg.Errorf("db-error: %v\n", err)
@@ -276,9 +378,387 @@ func (repo *RepositorySQLiteBackend) GetPackages() []*Package {
 	return pkgs
 }
 
+// walkPackages calls fn for each package known by the backend, while
+// iterating over the rows of the packages table, until fn returns an error.
+func (repo *RepositorySQLiteBackend) walkPackages(fn func(pkg *Package) error) error {
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, pkgid, checksum_type from packages"
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("yum: could not query packages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pkg, err := repo.newPackageFromScan(rows)
+		if err != nil {
+			return fmt.Errorf("yum: could not load package: %w", err)
+		}
+		err = fn(pkg)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("yum: could not iterate over packages: %w", err)
+	}
+	return rows.Close()
+}
+
+// findProviders returns the packages named name or providing the
+// capability name, whatever their version.
+func (repo *RepositorySQLiteBackend) findProviders(name string) ([]*Package, error) {
+	query := `select distinct p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href, p.size_package, p.size_installed, p.pkgid, p.checksum_type
+             from packages p left join provides r on p.pkgkey = r.pkgkey
+             where r.name = ?1 or p.name = ?1`
+	rows, err := repo.db.Query(query, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgs := make([]*Package, 0)
+	keys := make(map[*Package]int)
+	for rows.Next() {
+		pkg, pkgkey, err := repo.scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pkgs, nil
+}
+
+// loadInfo loads the descriptive metadata of pkg, kept out of the packages
+// loaded by the other queries as these columns may be large.
+func (repo *RepositorySQLiteBackend) loadInfo(pkg *Package) (PackageInfo, error) {
+	var info PackageInfo
+	var summary, descr, packager, url, license sql.NullString
+	err := repo.db.QueryRow(
+		"select summary, description, rpm_packager, url, rpm_license from packages"+
+			" where name = ? and version = ? and release = ? and epoch = ? and arch = ?",
+		pkg.Name(), pkg.Version(), pkg.Release(), pkg.Epoch(), pkg.Arch(),
+	).Scan(&summary, &descr, &packager, &url, &license)
+	if err != nil {
+		return info, fmt.Errorf("yum: could not load info of package %s: %w", pkg.ID(), err)
+	}
+
+	info.Summary = summary.String
+	info.Description = descr.String
+	info.Packager = packager.String
+	info.URL = url.String
+	info.License = license.String
+	return info, nil
+}
+
+// counts returns the number of packages, provides and requires entries.
+func (repo *RepositorySQLiteBackend) counts() (int64, int64, int64, error) {
+	var n [3]int64
+	for i, table := range []string{"packages", "provides", "requires"} {
+		err := repo.db.QueryRow("select count(*) from " + table).Scan(&n[i])
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return n[0], n[1], n[2], nil
+}
+
+// ListPackages returns the packages whose name matches the shell pattern,
+// sorted by name and version.
+// The pattern is translated into a LIKE clause to only load candidate packages.
+func (repo *RepositorySQLiteBackend) ListPackages(pattern string) ([]*Package, error) {
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, pkgid, checksum_type" +
+		" from packages where name like ? escape '\\'"
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(globToLike(pattern))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgs := make(Packages, 0)
+	keys := make(map[*Package]int)
+	for rows.Next() {
+		pkg, pkgkey, err := repo.scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		// LIKE is case-insensitive and only approximates character classes.
+		ok, err := path.Match(pattern, pkg.Name())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// search returns the packages whose name, summary or description contains
+// term, ignoring case.
+// As LIKE, search only ignores the case of ASCII characters.
+func (repo *RepositorySQLiteBackend) search(term string) ([]*Package, error) {
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, pkgid, checksum_type" +
+		" from packages where name like ?1 escape '\\' or summary like ?1 escape '\\' or description like ?1 escape '\\'"
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query("%" + escapeLike(term) + "%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgs := make(Packages, 0)
+	keys := make(map[*Package]int)
+	for rows.Next() {
+		pkg, pkgkey, err := repo.scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pkgs, nil
+}
+
+// FindObsoleting returns the packages obsoleting the package name,
+// sorted by name and version.
+func (repo *RepositorySQLiteBackend) FindObsoleting(name string) ([]*Package, error) {
+	query := `select distinct p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href, p.size_package, p.size_installed, p.pkgid, p.checksum_type
+             from packages p, obsoletes o
+             where p.pkgkey = o.pkgkey
+             and o.name = ?`
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgs := make(Packages, 0)
+	keys := make(map[*Package]int)
+	for rows.Next() {
+		pkg, pkgkey, err := repo.scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// FindRequiring returns the packages requiring the capability name,
+// sorted by name and version.
+func (repo *RepositorySQLiteBackend) FindRequiring(name string) ([]*Package, error) {
+	query := `select distinct p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href, p.size_package, p.size_installed, p.pkgid, p.checksum_type
+             from packages p, requires r
+             where p.pkgkey = r.pkgkey
+             and r.name = ?`
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgs := make(Packages, 0)
+	keys := make(map[*Package]int)
+	for rows.Next() {
+		pkg, pkgkey, err := repo.scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		err = repo.loadDeps(keys[pkg], pkg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// escapeLike escapes the wildcards of s for a LIKE pattern using '\' as
+// escape character.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// globToLike translates a shell pattern into a LIKE pattern, using '\' as
+// escape character.
+// Character classes are translated into the '_' wildcard: the resulting
+// LIKE pattern matches a superset of the names matched by the shell pattern.
+func globToLike(pattern string) string {
+	like := make([]byte, 0, len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			like = append(like, '%')
+		case '?':
+			like = append(like, '_')
+		case '[':
+			// skip the character class
+			j := i + 1
+			if j < len(pattern) && pattern[j] == '^' {
+				j++
+			}
+			if j < len(pattern) && pattern[j] == ']' {
+				j++
+			}
+			for j < len(pattern) && pattern[j] != ']' {
+				if pattern[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j
+			like = append(like, '_')
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				c = pattern[i]
+			}
+			fallthrough
+		default:
+			if c == '%' || c == '_' || c == '\\' {
+				like = append(like, '\\')
+			}
+			like = append(like, c)
+		}
+	}
+	return string(like)
+}
+
 func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Package, error) {
+	pkg, pkgkey, err := repo.scanPackage(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.loadDeps(pkgkey, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// scanPackage creates a Package from the current row, without loading its dependencies.
+// scanPackage also returns the package key of that package.
+func (repo *RepositorySQLiteBackend) scanPackage(rows *sql.Rows) (*Package, int, error) {
 	var pkg Package
 	pkg.repository = repo.Repository
+	pkg.backend = "RepositorySQLiteBackend"
 	var pkgkey int
 	var name []byte
 	var version []byte
@@ -287,6 +767,10 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 	var group []byte
 	var arch []byte
 	var location []byte
+	var size sql.NullInt64
+	var isize sql.NullInt64
+	var checksum []byte
+	var chksumType []byte
 	err := rows.Scan(
 		&pkgkey,
 		&name,
@@ -296,10 +780,14 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 		&group,
 		&arch,
 		&location,
+		&size,
+		&isize,
+		&checksum,
+		&chksumType,
 	)
 	if err != nil {
 		repo.msg.Errorf("scan error: %v\n", err)
-		return nil, err
+		return nil, 0, err
 	}
 
 	pkg.rpmBase.name = string(name)
@@ -309,20 +797,60 @@ func (repo *RepositorySQLiteBackend) newPackageFromScan(rows *sql.Rows) (*Packag
 	pkg.group = string(group)
 	pkg.arch = string(arch)
 	pkg.location = string(location)
+	pkg.size = size.Int64
+	pkg.isize = isize.Int64
+	pkg.checksum = string(checksum)
+	pkg.chksumType = string(chksumType)
+
+	return &pkg, pkgkey, nil
+}
 
-	err = repo.loadRequires(pkgkey, &pkg)
+// loadDeps loads the requires, provides, obsoletes, conflicts and weak
+// dependencies of package pkgkey.
+func (repo *RepositorySQLiteBackend) loadDeps(pkgkey int, pkg *Package) error {
+	var err error
+	err = repo.loadRequires(pkgkey, pkg)
 	if err != nil {
 		repo.msg.Errorf("load-requires error: %v\n", err)
-		return nil, err
+		return err
 	}
 
-	err = repo.loadProvides(pkgkey, &pkg)
+	err = repo.loadProvides(pkgkey, pkg)
 	if err != nil {
 		repo.msg.Errorf("load-provides error: %v\n", err)
-		return nil, err
+		return err
+	}
+
+	pkg.obsoletes, err = repo.loadRelations("obsoletes", pkgkey)
+	if err != nil {
+		repo.msg.Errorf("load-obsoletes error: %v\n", err)
+		return err
+	}
+
+	pkg.conflicts, err = repo.loadRelations("conflicts", pkgkey)
+	if err != nil {
+		repo.msg.Errorf("load-conflicts error: %v\n", err)
+		return err
+	}
+
+	// weak dependencies are only stored by recent metadata generators.
+	if repo.tables["recommends"] {
+		pkg.recommends, err = repo.loadRelations("recommends", pkgkey)
+		if err != nil {
+			repo.msg.Errorf("load-recommends error: %v\n", err)
+			return err
+		}
 	}
 
-	return &pkg, nil
+	if repo.tables["suggests"] {
+		pkg.suggests, err = repo.loadRelations("suggests", pkgkey)
+		if err != nil {
+			repo.msg.Errorf("load-suggests error: %v\n", err)
+			return err
+		}
+	}
+
+	return err
 }
 
 func (repo *RepositorySQLiteBackend) loadProvides(pkgkey int, pkg *Package) error {
@@ -450,11 +978,67 @@ func (repo *RepositorySQLiteBackend) loadRequires(pkgkey int, pkg *Package) erro
 	return err
 }
 
-func (repo *RepositorySQLiteBackend) loadPackagesByName(name, version string) ([]*Package, error) {
+// loadRelations loads the entries of the table (obsoletes, conflicts,
+// recommends, suggests) for package pkgkey
+func (repo *RepositorySQLiteBackend) loadRelations(table string, pkgkey int) ([]*Requires, error) {
+	var err error
+	stmt, err := repo.db.Prepare(
+		"select name, version, release, epoch, flags from " + table + " where pkgkey=?",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(pkgkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rels := make([]*Requires, 0)
+	for rows.Next() {
+		var rel Requires
+		var name []byte
+		var version []byte
+		var release []byte
+		var epoch []byte
+		var flags []byte
+		err = rows.Scan(
+			&name, &version, &release,
+			&epoch, &flags,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rel.rpmBase.name = string(name)
+		rel.rpmBase.version = string(version)
+		rel.rpmBase.release = string(release)
+		rel.rpmBase.epoch = string(epoch)
+		rel.rpmBase.flags = string(flags)
+		if rel.rpmBase.flags == "" {
+			rel.rpmBase.flags = "EQ"
+		}
+		rels = append(rels, &rel)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return rels, err
+}
+
+// loadPackagesByName loads the packages named name (with version, if not empty).
+// The dependencies of these packages are not loaded: loadPackagesByName returns
+// the package keys to load them on demand instead.
+func (repo *RepositorySQLiteBackend) loadPackagesByName(name, version string) ([]*Package, map[*Package]int, error) {
 	var err error
 	pkgs := make([]*Package, 0)
+	keys := make(map[*Package]int)
 	args := []interface{}{name}
-	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href" +
+	query := "select pkgkey, name, version, release, epoch, rpm_group, arch, location_href, size_package, size_installed, pkgid, checksum_type" +
 		" from packages where name = ?"
 	if version != "" {
 		query += " and version = ?"
@@ -464,40 +1048,41 @@ func (repo *RepositorySQLiteBackend) loadPackagesByName(name, version string) ([
 	stmt, err := repo.db.Prepare(query)
 	if err != nil {
 		repo.msg.Errorf("loadpkgbyname-prepare error: %v\n", err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer stmt.Close()
 
 	rows, err := stmt.Query(args...)
 	if err != nil {
 		repo.msg.Errorf("loadpkgbyname-query error: %v\n", err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		pkg, err := repo.newPackageFromScan(rows)
+		pkg, pkgkey, err := repo.scanPackage(rows)
 		if err != nil {
 			repo.msg.Errorf("loadpkgbyname-scan error: %v\n", err)
-			return nil, err
+			return nil, nil, err
 		}
 		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
 	}
 	err = rows.Err()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = rows.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = stmt.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return pkgs, err
+	return pkgs, keys, err
 }
 
 func (repo *RepositorySQLiteBackend) findProvidesByName(name string) ([]*Provides, error) {
@@ -560,19 +1145,24 @@ func (repo *RepositorySQLiteBackend) findProvidesByName(name string) ([]*Provide
 	return provides, err
 }
 
-func (repo *RepositorySQLiteBackend) loadPackagesProviding(prov *Provides) ([]*Package, error) {
+// loadPackagesProviding loads the packages providing prov.
+// The dependencies of these packages are not loaded: loadPackagesProviding returns
+// the package keys to load them on demand instead.
+func (repo *RepositorySQLiteBackend) loadPackagesProviding(prov *Provides) ([]*Package, map[*Package]int, error) {
 	pkgs := make([]*Package, 0)
+	keys := make(map[*Package]int)
 	var err error
 
 	args := []interface{}{
 		prov.Name(),
 		prov.Version(),
 	}
-	query := `select p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href
+	// unversioned provides (e.g. files) have a NULL version.
+	query := `select p.pkgkey, p.name, p.version, p.release, p.epoch, p.rpm_group, p.arch, p.location_href, p.size_package, p.size_installed, p.pkgid, p.checksum_type
              from packages p, provides r
              where p.pkgkey = r.pkgkey
              and r.name = ?
-             and r.version = ?`
+             and ifnull(r.version, '') = ?`
 	if prov.Release() != "" {
 		query += " and r.release = ?"
 		args = append(args, prov.Release())
@@ -580,80 +1170,78 @@ func (repo *RepositorySQLiteBackend) loadPackagesProviding(prov *Provides) ([]*P
 
 	stmt, err := repo.db.Prepare(query)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer stmt.Close()
 
 	rows, err := stmt.Query(args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		pkg, err := repo.newPackageFromScan(rows)
+		pkg, pkgkey, err := repo.scanPackage(rows)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		pkgs = append(pkgs, pkg)
+		keys[pkg] = pkgkey
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = rows.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	err = stmt.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return pkgs, err
+	return pkgs, keys, err
 }
 
-// decompress decompresses src into dst
-func (repo *RepositorySQLiteBackend) decompress(dst io.Writer, src io.Reader) error {
-	var err error
-	r := bzip2.NewReader(src)
+// decompress decompresses src (named name) into dst
+func (repo *RepositorySQLiteBackend) decompress(dst io.Writer, src io.Reader, name string) error {
+	r, err := decompress(src, name)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
 	_, err = io.Copy(dst, r)
 	return err
 }
 
 // decompress2 decompresses src into dst
 func (repo *RepositorySQLiteBackend) decompress2(dst string, src string) error {
-	fdst, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer fdst.Close()
-
 	fsrc, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer fsrc.Close()
 
-	err = repo.decompress(fdst, fsrc)
-	if err != nil {
-		return err
-	}
-
-	err = fdst.Sync()
-	if err != nil {
-		return err
-	}
+	return write_file_atomic(dst, func(w io.Writer) error {
+		return repo.decompress(w, fsrc, src)
+	})
+}
 
-	return err
+func (repo *RepositorySQLiteBackend) setLogger(msg Logger) {
+	repo.msg = msg
 }
 
 func init() {
 	g_backends["RepositorySQLiteBackend"] = func(repo *Repository) (Backend, error) {
 		return NewRepositorySQLiteBackend(repo)
 	}
+	g_backends["primary_db"] = g_backends["RepositorySQLiteBackend"]
 }
 
 // EOF
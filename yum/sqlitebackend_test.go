@@ -0,0 +1,240 @@
+package yum
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestSQLiteRepository creates a repository using the backend registered
+// as name, loading a copy of the SQLite DB fname from a new temporary cache
+// directory. Callers should remove repo.CacheDir after use.
+func newTestSQLiteRepository(t *testing.T, name, fname string) *Repository {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-sqlite-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", tmpdir,
+		[]string{name},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	backend, err := NewBackend(name, repo)
+	if err != nil {
+		t.Fatalf("could not create backend: %v\n", err)
+	}
+	// the backend removes its uncompressed DB when closed.
+	err = copyTestFile(backend.(*RepositorySQLiteBackend).Primary, fname)
+	if err != nil {
+		t.Fatalf("could not copy DB [%s]: %v\n", fname, err)
+	}
+
+	repo.Backend = backend
+	err = repo.Backend.LoadDB()
+	if err != nil {
+		t.Fatalf("could not load DB [%s]: %v\n", fname, err)
+	}
+	return repo
+}
+
+func TestSQLiteBackendRequires(t *testing.T) {
+	repo := newTestSQLiteRepository(t, "RepositorySQLiteBackend", "testdata/primary.sqlite")
+	defer os.RemoveAll(repo.CacheDir)
+	defer repo.Close()
+
+	if dt := repo.Backend.YumDataType(); dt != "primary_db" {
+		t.Fatalf("expected data type %q. got=%q\n", "primary_db", dt)
+	}
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find foo: %v\n", err)
+	}
+	if pkg.NVRA() != "foo-2.0-1.x86_64" {
+		t.Fatalf("expected foo-2.0-1.x86_64. got=%s\n", pkg.NVRA())
+	}
+
+	// the dependencies are only loaded for the returned package.
+	var reqs []string
+	for _, req := range pkg.Requires() {
+		reqs = append(reqs, req.Name())
+	}
+	if want := []string{"bar", "libbaz.so.3()(64bit)"}; !reflect.DeepEqual(reqs, want) {
+		t.Fatalf("invalid requires.\ngot= %v\nwant=%v\n", reqs, want)
+	}
+	if n := len(pkg.Obsoletes()); n != 1 {
+		t.Fatalf("expected 1 obsoletes entry. got=%d\n", n)
+	}
+
+	prov, err := repo.FindLatestMatchingRequire(NewRequires("libbaz.so.3()(64bit)", "", "", "", "", ""))
+	if err != nil {
+		t.Fatalf("could not find provider of libbaz.so.3()(64bit): %v\n", err)
+	}
+	if prov.NVRA() != "libbaz-3.0-1.x86_64" {
+		t.Fatalf("expected libbaz-3.0-1.x86_64. got=%s\n", prov.NVRA())
+	}
+
+	pkgs, err := repo.RequiredPackages(pkg)
+	if err != nil {
+		t.Fatalf("could not resolve requirements: %v\n", err)
+	}
+	if names, want := pkgNames(pkgs), []string{"bar", "libbaz", "foo"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("invalid packages.\ngot= %v\nwant=%v\n", names, want)
+	}
+
+	// foo-1.0 requires a library nobody provides.
+	old, err := repo.FindLatestMatchingName("foo", "1.0", "")
+	if err != nil {
+		t.Fatalf("could not find foo-1.0: %v\n", err)
+	}
+	_, err = repo.RequiredPackages(old)
+	if _, ok := err.(*UnresolvedError); !ok {
+		t.Fatalf("expected an *UnresolvedError. got=%v\n", err)
+	}
+}
+
+func TestSQLiteBackendSchema(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-sqlite-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	newer := filepath.Join(tmpdir, "newer.sqlite")
+	db, err := sql.Open("sqlite3", newer)
+	if err != nil {
+		t.Fatalf("could not open DB: %v\n", err)
+	}
+	for _, stmt := range []string{
+		"create table db_info (dbversion integer, checksum text)",
+		"insert into db_info values (11, 'abc')",
+	} {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			t.Fatalf("could not create DB: %v\n", err)
+		}
+	}
+	db.Close()
+
+	// the schema is checked whatever the name the backend is registered as.
+	for _, name := range []string{"RepositorySQLiteBackend", "primary_db"} {
+		t.Run(name, func(t *testing.T) {
+			repo := newTestSQLiteRepository(t, name, "testdata/primary.sqlite")
+			defer os.RemoveAll(repo.CacheDir)
+			defer repo.Close()
+
+			backend := repo.Backend.(*RepositorySQLiteBackend)
+			err := copyTestFile(backend.Primary, newer)
+			if err != nil {
+				t.Fatalf("could not copy DB: %v\n", err)
+			}
+
+			err = backend.LoadDB()
+			if !errors.Is(err, ErrUnsupportedSchema) {
+				t.Fatalf("expected ErrUnsupportedSchema. got=%v\n", err)
+			}
+			if path_exists(backend.Primary) {
+				t.Fatalf("unsupported DB [%s] was not removed\n", backend.Primary)
+			}
+		})
+	}
+}
+
+func TestGlobToLike(t *testing.T) {
+	for _, table := range []struct {
+		glob string
+		like string
+	}{
+		{"gcc", "gcc"},
+		{"gcc*", "gcc%"},
+		{"*-devel", "%-devel"},
+		{"lib?", "lib_"},
+		{"lib[ab]c", "lib_c"},
+		{"lib[^ab]c*", "lib_c%"},
+		{"lib[]]c", "lib_c"},
+		{"my_pkg", `my\_pkg`},
+		{"100%", `100\%`},
+		{`a\*b`, "a*b"},
+		{`a\\b`, `a\\b`},
+	} {
+		like := globToLike(table.glob)
+		if like != table.like {
+			t.Errorf("%q: expected %q. got=%q\n", table.glob, table.like, like)
+		}
+	}
+}
+
+func TestCheckSQLiteSchema(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "lbpkr-test-sqlite-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	for i, table := range []struct {
+		name  string
+		stmts []string
+		ok    bool
+	}{
+		{
+			name: "supported",
+			stmts: []string{
+				"create table db_info (dbversion integer, checksum text)",
+				"insert into db_info values (10, 'abc')",
+			},
+			ok: true,
+		},
+		{
+			name: "newer",
+			stmts: []string{
+				"create table db_info (dbversion integer, checksum text)",
+				"insert into db_info values (11, 'abc')",
+			},
+		},
+		{
+			name:  "empty",
+			stmts: []string{"create table db_info (dbversion integer, checksum text)"},
+		},
+		{
+			name:  "not-yum",
+			stmts: []string{"create table foo (bar text)"},
+		},
+	} {
+		t.Run(table.name, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", filepath.Join(tmpdir, fmt.Sprintf("db-%d.sqlite", i)))
+			if err != nil {
+				t.Fatalf("could not open DB: %v\n", err)
+			}
+			defer db.Close()
+
+			for _, stmt := range table.stmts {
+				_, err = db.Exec(stmt)
+				if err != nil {
+					t.Fatalf("could not create DB: %v\n", err)
+				}
+			}
+
+			tables, err := sqliteTables(db)
+			if err != nil {
+				t.Fatalf("could not list tables: %v\n", err)
+			}
+
+			err = checkSQLiteSchema(db, tables)
+			switch {
+			case table.ok && err != nil:
+				t.Fatalf("unexpected error: %v\n", err)
+			case !table.ok && !errors.Is(err, ErrUnsupportedSchema):
+				t.Fatalf("expected ErrUnsupportedSchema. got=%v\n", err)
+			}
+		})
+	}
+}
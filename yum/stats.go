@@ -0,0 +1,57 @@
+package yum
+
+import (
+	"fmt"
+	"time"
+)
+
+// RepoStats describes the content of a repository.
+type RepoStats struct {
+	Backend   string    // type of the active backend
+	Packages  int64     // number of packages
+	Provides  int64     // number of provides entries
+	Requires  int64     // number of requires entries
+	Timestamp time.Time // timestamp of the DB of the active backend, from repomd.xml
+}
+
+// Stats returns statistics about the content of the repository.
+func (repo *Repository) Stats() RepoStats {
+	var stats RepoStats
+	if repo.Backend == nil {
+		return stats
+	}
+	stats.Backend = fmt.Sprintf("%T", repo.Backend)
+
+	data, err := repo.localMetadata()
+	if err == nil && len(data) > 0 {
+		md, err := repo.checkRepoMD(data)
+		if err == nil {
+			stats.Timestamp = md[repo.Backend.YumDataType()].Timestamp
+		}
+	}
+
+	type counter interface {
+		counts() (packages, provides, requires int64, err error)
+	}
+
+	if c, ok := repo.Backend.(counter); ok {
+		stats.Packages, stats.Provides, stats.Requires, err = c.counts()
+		if err == nil {
+			return stats
+		}
+		repo.msg.Warnf("repository [%s] - could not count packages: %v\n", repo.Name, err)
+	}
+
+	stats.Packages, stats.Provides, stats.Requires = countPackages(repo.Backend.GetPackages())
+	return stats
+}
+
+// countPackages returns the number of packages, provides and requires entries of pkgs.
+func countPackages(pkgs []*Package) (int64, int64, int64) {
+	var provides, requires int64
+	for _, pkg := range pkgs {
+		provides += int64(len(pkg.provides))
+		requires += int64(len(pkg.requires))
+	}
+	return int64(len(pkgs)), provides, requires
+}
@@ -0,0 +1,96 @@
+package yum
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead is the maximum number of bytes read at once from a
+// throttled download, so the rate is enforced smoothly.
+const maxThrottledRead = 32 * 1024
+
+// rateLimiter is a token bucket shared by the downloads of a repository.
+// The bucket holds at most one second worth of bytes.
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64   // available bytes (negative when in debt)
+	last   time.Time // last time the bucket was refilled
+}
+
+// wait consumes n bytes from the bucket, refilled at rate bytes per second,
+// and blocks until the bucket is no longer in debt or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int, rate int64) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = float64(rate)
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+	}
+	if l.tokens > float64(rate) {
+		l.tokens = float64(rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader is an io.ReadCloser limiting the rate at which its
+// content is read to repo.MaxBytesPerSec.
+type throttledReader struct {
+	ctx  context.Context
+	r    io.ReadCloser
+	repo *Repository
+}
+
+// throttle returns r, rate-limited to repo.MaxBytesPerSec if set.
+func (repo *Repository) throttle(ctx context.Context, r io.ReadCloser) io.ReadCloser {
+	if repo.MaxBytesPerSec <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, repo: repo}
+}
+
+func (r *throttledReader) Read(data []byte) (int, error) {
+	rate := r.repo.MaxBytesPerSec
+	if rate <= 0 {
+		return r.r.Read(data)
+	}
+	chunk := rate
+	if chunk > maxThrottledRead {
+		chunk = maxThrottledRead
+	}
+	if int64(len(data)) > chunk {
+		data = data[:chunk]
+	}
+	n, err := r.r.Read(data)
+	if n > 0 {
+		if werr := r.repo.limiter.wait(r.ctx, n, rate); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *throttledReader) Close() error {
+	return r.r.Close()
+}
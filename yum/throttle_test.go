@@ -0,0 +1,81 @@
+package yum
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRepositoryMaxBytesPerSec(t *testing.T) {
+	const rate = 20000
+	content := bytes.Repeat([]byte("0123456789"), 3000) // 30000 bytes
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	repo, err := NewRepository("testrepo", srv.URL, "testdata/cachedir.tmp",
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	repo.MaxBytesPerSec = rate
+
+	// the first second worth of bytes comes for free, the rest is throttled.
+	start := time.Now()
+	r, err := repo.download(context.Background(), srv.URL+"/primary.xml")
+	if err != nil {
+		t.Fatalf("could not download: %v\n", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("could not read download: %v\n", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("invalid content (%d bytes)\n", len(data))
+	}
+	if dt := time.Since(start); dt < 400*time.Millisecond {
+		t.Fatalf("download not throttled: %d bytes in %v\n", len(data), dt)
+	}
+
+	// package downloads share the same limit.
+	dir, err := ioutil.TempDir("", "lbpkr-test-throttle-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "foo.rpm")
+	err = ioutil.WriteFile(fname, content[:20000], 0644)
+	if err != nil {
+		t.Fatalf("could not create partial download: %v\n", err)
+	}
+
+	start = time.Now()
+	err = repo.downloadResume(context.Background(), srv.URL+"/foo.rpm", fname, nil)
+	if err != nil {
+		t.Fatalf("could not download: %v\n", err)
+	}
+	if dt := time.Since(start); dt < 800*time.Millisecond {
+		t.Fatalf("package download not throttled: %v\n", dt)
+	}
+
+	// no limit.
+	repo.MaxBytesPerSec = 0
+	start = time.Now()
+	err = repo.downloadResume(context.Background(), srv.URL+"/foo.rpm", fname, nil)
+	if err != nil {
+		t.Fatalf("could not download: %v\n", err)
+	}
+	if dt := time.Since(start); dt > 400*time.Millisecond {
+		t.Fatalf("unthrottled download took %v\n", dt)
+	}
+}
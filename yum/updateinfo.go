@@ -0,0 +1,190 @@
+package yum
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Advisory describes an update advisory (e.g. a security fix), from the
+// updateinfo metadata of a repository.
+type Advisory struct {
+	ID         string // e.g. RHSA-2020:1234
+	Title      string
+	Type       string // security, bugfix, enhancement or newpackage
+	Severity   string // e.g. Critical, Important, Moderate or Low. empty if unknown
+	Issued     string // date the advisory was issued, as recorded in the metadata
+	Packages   []AdvisoryPackage
+	References []AdvisoryReference
+}
+
+// AdvisoryPackage is a package fixing the issues of an Advisory.
+type AdvisoryPackage struct {
+	Name     string
+	Arch     string
+	Epoch    string
+	Version  string
+	Release  string
+	Filename string
+}
+
+// AdvisoryReference is a reference of an Advisory to an external tracker
+// (e.g. a CVE or a bug report).
+type AdvisoryReference struct {
+	ID    string
+	Type  string // e.g. cve, bugzilla or self
+	Href  string
+	Title string
+}
+
+// CVEs returns the IDs of the CVEs fixed by the advisory.
+func (adv *Advisory) CVEs() []string {
+	var cves []string
+	for _, ref := range adv.References {
+		if ref.Type == "cve" {
+			cves = append(cves, ref.ID)
+		}
+	}
+	return cves
+}
+
+// Advisories returns the update advisories of the repository, from its
+// updateinfo metadata.
+// The updateinfo metadata is only loaded (and downloaded if needed) on first
+// use.
+func (repo *Repository) Advisories() ([]*Advisory, error) {
+	if repo.advisories != nil {
+		return repo.advisories, nil
+	}
+
+	fname, err := repo.metadataFile("updateinfo")
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not retrieve advisories of repository [%s]: %w", repo.Name, err)
+	}
+
+	advisories, err := loadAdvisories(fname)
+	if err != nil {
+		return nil, fmt.Errorf("yum: could not load advisories [%s]: %w", fname, err)
+	}
+	repo.advisories = advisories
+	return repo.advisories, nil
+}
+
+// AdvisoriesForPackage returns the update advisories of the repository
+// listing a package named name.
+func (repo *Repository) AdvisoriesForPackage(name string) ([]*Advisory, error) {
+	advisories, err := repo.Advisories()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Advisory
+	for _, adv := range advisories {
+		for _, pkg := range adv.Packages {
+			if pkg.Name == name {
+				out = append(out, adv)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// loadAdvisories parses the updateinfo XML file fname.
+func loadAdvisories(fname string) ([]*Advisory, error) {
+	type xmlUpdate struct {
+		Type     string `xml:"type,attr"`
+		ID       string `xml:"id"`
+		Title    string `xml:"title"`
+		Severity string `xml:"severity"`
+		Issued   struct {
+			Date string `xml:"date,attr"`
+		} `xml:"issued"`
+		References []struct {
+			ID    string `xml:"id,attr"`
+			Type  string `xml:"type,attr"`
+			Href  string `xml:"href,attr"`
+			Title string `xml:"title,attr"`
+		} `xml:"references>reference"`
+		Packages []struct {
+			Name     string `xml:"name,attr"`
+			Arch     string `xml:"arch,attr"`
+			Epoch    string `xml:"epoch,attr"`
+			Version  string `xml:"version,attr"`
+			Release  string `xml:"release,attr"`
+			Filename string `xml:"filename"`
+		} `xml:"pkglist>collection>package"`
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	advisories := make([]*Advisory, 0)
+
+	// decode the metadata one advisory at a time.
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "update" {
+			continue
+		}
+
+		var xupd xmlUpdate
+		err = dec.DecodeElement(&xupd, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		adv := &Advisory{
+			ID:         strings.TrimSpace(xupd.ID),
+			Title:      strings.TrimSpace(xupd.Title),
+			Type:       xupd.Type,
+			Severity:   strings.TrimSpace(xupd.Severity),
+			Issued:     xupd.Issued.Date,
+			Packages:   make([]AdvisoryPackage, 0, len(xupd.Packages)),
+			References: make([]AdvisoryReference, 0, len(xupd.References)),
+		}
+		for _, p := range xupd.Packages {
+			adv.Packages = append(adv.Packages, AdvisoryPackage{
+				Name:     p.Name,
+				Arch:     p.Arch,
+				Epoch:    p.Epoch,
+				Version:  p.Version,
+				Release:  p.Release,
+				Filename: strings.TrimSpace(p.Filename),
+			})
+		}
+		for _, ref := range xupd.References {
+			adv.References = append(adv.References, AdvisoryReference{
+				ID:    ref.ID,
+				Type:  ref.Type,
+				Href:  ref.Href,
+				Title: ref.Title,
+			})
+		}
+		advisories = append(advisories, adv)
+	}
+
+	return advisories, nil
+}
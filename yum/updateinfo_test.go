@@ -0,0 +1,101 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepositoryAdvisories(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+	defer os.RemoveAll(repo.CacheDir)
+
+	_, err := repo.Advisories()
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+
+	remote, err := ioutil.TempDir("", "lbpkr-test-remote-")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	defer os.RemoveAll(remote)
+
+	err = os.MkdirAll(filepath.Join(remote, "repodata"), 0755)
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	err = copyTestFile(filepath.Join(remote, "repodata", "updateinfo.xml"), "testdata/updateinfo.xml")
+	if err != nil {
+		t.Fatalf("could not create remote repo: %v\n", err)
+	}
+	repo.RepoUrl = "file://" + remote
+
+	sum, err := checksumFile("testdata/updateinfo.xml", "sha256")
+	if err != nil {
+		t.Fatalf("could not compute checksum: %v\n", err)
+	}
+	err = ioutil.WriteFile(repo.LocalRepoMdXml, []byte(fmt.Sprintf(`<repomd>
+  <data type="updateinfo">
+    <checksum type="sha256">%s</checksum>
+    <timestamp>1343662777</timestamp>
+    <location href="repodata/updateinfo.xml"/>
+  </data>
+</repomd>`, sum)), 0644)
+	if err != nil {
+		t.Fatalf("could not write repomd.xml: %v\n", err)
+	}
+
+	advisories, err := repo.Advisories()
+	if err != nil {
+		t.Fatalf("could not load advisories: %v\n", err)
+	}
+	if len(advisories) != 2 {
+		t.Fatalf("expected 2 advisories. got=%d\n", len(advisories))
+	}
+
+	adv := advisories[0]
+	if adv.ID != "TEST-2012:0001" || adv.Type != "security" || adv.Severity != "Important" ||
+		adv.Title != "Important: foo security update" || adv.Issued != "2012-07-30 00:00:00" {
+		t.Fatalf("invalid advisory: %#v\n", adv)
+	}
+	if want := []string{"CVE-2012-1234", "CVE-2012-5678"}; !reflect.DeepEqual(adv.CVEs(), want) {
+		t.Fatalf("invalid CVEs. got=%v. want=%v\n", adv.CVEs(), want)
+	}
+	want := AdvisoryPackage{
+		Name: "foo", Arch: "x86_64", Epoch: "0", Version: "2.0", Release: "1",
+		Filename: "foo-2.0-1.x86_64.rpm",
+	}
+	if len(adv.Packages) != 2 || adv.Packages[0] != want {
+		t.Fatalf("invalid packages.\ngot= %#v\nwant=%#v\n", adv.Packages, want)
+	}
+	if cves := advisories[1].CVEs(); len(cves) != 0 || advisories[1].Severity != "" {
+		t.Fatalf("invalid advisory: %#v\n", advisories[1])
+	}
+
+	for _, table := range []struct {
+		name string
+		ids  []string
+	}{
+		{"foo", []string{"TEST-2012:0001"}},
+		{"bar", []string{"TEST-2012:0002"}},
+		{"baz", nil},
+	} {
+		advs, err := repo.AdvisoriesForPackage(table.name)
+		if err != nil {
+			t.Fatalf("%s: could not find advisories: %v\n", table.name, err)
+		}
+		var ids []string
+		for _, adv := range advs {
+			ids = append(ids, adv.ID)
+		}
+		if !reflect.DeepEqual(ids, table.ids) {
+			t.Fatalf("%s: invalid advisories. got=%v. want=%v\n", table.name, ids, table.ids)
+		}
+	}
+}
@@ -1,10 +1,26 @@
 package yum
 
 import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
-	"net/http"
-	"net/url"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 func path_exists(name string) bool {
@@ -27,25 +43,299 @@ func str_in_slice(str string, slice []string) bool {
 	return false
 }
 
-func getRemoteData(rpath string) (io.ReadCloser, error) {
-	url, err := url.Parse(rpath)
+// magic numbers of the compression formats found in YUM repositories
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns a reader streaming the decompressed content of r.
+// The compression format is detected from the magic bytes of the stream,
+// falling back on the suffix of name (a file name or URL).
+// Uncompressed streams are passed through untouched.
+func decompress(r io.Reader, name string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.Peek(len(magicXz))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(hdr, magicGzip):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(hdr, magicBzip2):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(hdr, magicXz):
+		return newXzReader(br)
+	case bytes.HasPrefix(hdr, magicZstd):
+		return nil, fmt.Errorf("yum: unsupported compression format (zstd) for [%s]", name)
+	}
+
+	switch ext := path.Ext(name); ext {
+	case ".gz", ".bz2", ".xz":
+		// the suffix lies: the content is not compressed.
+		return br, nil
+	case ".zst", ".zck", ".lz4", ".lzma":
+		return nil, fmt.Errorf("yum: unsupported compression format (%s) for [%s]",
+			strings.TrimPrefix(ext, "."), name,
+		)
+	}
+	return br, nil
+}
+
+// xzReader decompresses a xz stream through the xz command.
+type xzReader struct {
+	cmd    *exec.Cmd
+	pipe   io.ReadCloser
+	stderr bytes.Buffer // diagnostics of the xz command
+	done   bool
+}
+
+func newXzReader(r io.Reader) (*xzReader, error) {
+	bin, err := exec.LookPath("xz")
+	if err != nil {
+		return nil, fmt.Errorf("yum: xz decompression needs the 'xz' command: %v", err)
+	}
+	xz := &xzReader{cmd: exec.Command(bin, "--decompress", "--stdout")}
+	xz.cmd.Stdin = r
+	xz.cmd.Stderr = &xz.stderr
+	xz.pipe, err = xz.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = xz.cmd.Start()
 	if err != nil {
 		return nil, err
 	}
+	return xz, nil
+}
 
-	switch url.Scheme {
-	case "file":
-		f, err := os.Open(url.Path)
-		if err != nil {
-			return nil, err
+func (xz *xzReader) Read(data []byte) (int, error) {
+	n, err := xz.pipe.Read(data)
+	if err == io.EOF && !xz.done {
+		xz.done = true
+		if werr := xz.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("yum: xz decompression failed: %v: %s",
+				werr, strings.TrimSpace(xz.stderr.String()),
+			)
 		}
-		return f, nil
+	}
+	return n, err
+}
 
-	default:
-		resp, err := http.Get(rpath)
-		if err != nil {
-			return nil, err
-		}
-		return resp.Body, nil
+// Close releases the resources held by the xz command.
+func (xz *xzReader) Close() error {
+	if xz.done {
+		return nil
+	}
+	xz.done = true
+	xz.pipe.Close()
+	xz.cmd.Process.Kill()
+	xz.cmd.Wait()
+	return nil
+}
+
+// newHasher returns a hash.Hash for the checksum type algo, as used in
+// repomd.xml and primary metadata files: sha (an alias of sha1), sha1,
+// sha224, sha256, sha384, sha512 or md5 (legacy).
+// All the verifications of checksums go through newHasher.
+func newHasher(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "sha", "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("yum: unknown checksum type %q", algo)
+}
+
+// checksumFile returns the hex-encoded checksum of type algo for the file fname.
+func checksumFile(fname, algo string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return checksumReader(f, algo)
+}
+
+// checksumReader returns the hex-encoded checksum of the content of r,
+// using the checksum type algo.
+func checksumReader(r io.Reader, algo string) (string, error) {
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumDecompressedFile returns the checksum of the decompressed content of fname.
+func checksumDecompressedFile(fname, algo string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	r, err := decompress(f, fname)
+	if err != nil {
+		return "", err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	return checksumReader(r, algo)
+}
+
+// checksumKey identifies a version of a file for the checksum cache.
+type checksumKey struct {
+	path  string
+	algo  string
+	size  int64
+	mtime time.Time
+}
+
+// maxCachedChecksums is the maximum number of entries of the checksum cache.
+const maxCachedChecksums = 1024
+
+// checksums caches the checksums computed by cachedChecksumFile.
+var checksums = struct {
+	sync.Mutex
+	m map[checksumKey]string
+}{m: make(map[checksumKey]string)}
+
+// cachedChecksumFile returns the hex-encoded checksum of type algo for the
+// file fname, reusing the checksum previously computed for the same path,
+// size and modification time, if any.
+func cachedChecksumFile(fname, algo string) (string, error) {
+	if abs, err := filepath.Abs(fname); err == nil {
+		fname = abs
+	}
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return "", err
+	}
+	key := checksumKey{path: fname, algo: algo, size: fi.Size(), mtime: fi.ModTime()}
+
+	checksums.Lock()
+	sum, ok := checksums.m[key]
+	checksums.Unlock()
+	if ok {
+		return sum, nil
+	}
+
+	sum, err = checksumFile(fname, algo)
+	if err != nil {
+		return "", err
+	}
+
+	// do not cache the checksum of a file modified while it was computed.
+	fi, err = os.Stat(fname)
+	if err != nil || fi.Size() != key.size || !fi.ModTime().Equal(key.mtime) {
+		return sum, nil
+	}
+
+	checksums.Lock()
+	if len(checksums.m) >= maxCachedChecksums {
+		checksums.m = make(map[checksumKey]string)
+	}
+	checksums.m[key] = sum
+	checksums.Unlock()
+	return sum, nil
+}
+
+// VerifyFile checks that the checksum of type algo (sha, sha1, sha224,
+// sha256, sha384, sha512 or md5) of the file fname is sum.
+// VerifyFile returns an error wrapping ErrChecksumMismatch if it is not.
+// Checksums are cached for the duration of the process, by path, size and
+// modification time of the file: verifying an unmodified file again is cheap.
+func VerifyFile(fname, algo, sum string) error {
+	got, err := cachedChecksumFile(fname, algo)
+	if err != nil {
+		return err
+	}
+	if got != sum {
+		return fmt.Errorf(
+			"%w for [%s] (type=%s): expected=%s got=%s",
+			ErrChecksumMismatch, fname, algo, sum, got,
+		)
+	}
+	return nil
+}
+
+// write_file_atomic creates the file fname with the content written by fct.
+// The content is first written to a temporary file of the same directory
+// which is then renamed to fname, so fname is never left half-written,
+// even if the process is interrupted.
+func write_file_atomic(fname string, fct func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.RemoveAll(tmp)
+	defer f.Close()
+
+	err = fct(f)
+	if err != nil {
+		return err
+	}
+
+	err = f.Chmod(0644)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, fname)
+}
+
+// copy_file atomically copies the content of the file src to dst.
+func copy_file(dst, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return write_file_atomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, f)
+		return err
+	})
+}
+
+// same_file returns whether the files a and b exist and are the same file.
+func same_file(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
 	}
+	return os.SameFile(fa, fb)
 }
@@ -0,0 +1,244 @@
+package yum
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	const content = "<metadata>some content</metadata>"
+
+	gz := new(bytes.Buffer)
+	w := gzip.NewWriter(gz)
+	_, err := w.Write([]byte(content))
+	if err != nil {
+		t.Fatalf("could not gzip content: %v\n", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("could not gzip content: %v\n", err)
+	}
+
+	tables := []struct {
+		name string
+		data []byte
+	}{
+		{"primary.xml", []byte(content)},
+		{"primary.xml.gz", gz.Bytes()},
+		{"primary.xml.bin", gz.Bytes()},
+		{"primary.xml.gz", []byte(content)},
+	}
+
+	if _, err := exec.LookPath("xz"); err == nil {
+		cmd := exec.Command("xz", "--compress", "--stdout")
+		cmd.Stdin = bytes.NewReader([]byte(content))
+		xz, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("could not xz content: %v\n", err)
+		}
+		tables = append(tables, struct {
+			name string
+			data []byte
+		}{"primary.sqlite.xz", xz})
+	}
+
+	for _, table := range tables {
+		r, err := decompress(bytes.NewReader(table.data), table.name)
+		if err != nil {
+			t.Fatalf("could not decompress [%s]: %v\n", table.name, err)
+		}
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("could not read [%s]: %v\n", table.name, err)
+		}
+		if string(out) != content {
+			t.Fatalf("%s: expected %q. got=%q\n", table.name, content, string(out))
+		}
+	}
+
+	_, err = decompress(bytes.NewReader([]byte(content)), "primary.xml.zst")
+	if err == nil {
+		t.Fatalf("expected an error for an unsupported compression format\n")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lbpkr-test-atomic-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "repomd.xml")
+	write := func(content string, err error) error {
+		return write_file_atomic(fname, func(w io.Writer) error {
+			_, werr := io.WriteString(w, content)
+			if werr != nil {
+				return werr
+			}
+			return err
+		})
+	}
+
+	err = write("old content", nil)
+	if err != nil {
+		t.Fatalf("could not write file: %v\n", err)
+	}
+
+	// an interrupted write leaves the previous content untouched.
+	err = write("new", fmt.Errorf("interrupted"))
+	if err == nil {
+		t.Fatalf("expected an error\n")
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read file: %v\n", err)
+	}
+	if string(data) != "old content" {
+		t.Fatalf("expected %q. got=%q\n", "old content", string(data))
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("could not read tmpdir: %v\n", err)
+	}
+	if len(fis) != 1 {
+		t.Fatalf("expected temporary files to be removed. got=%d files\n", len(fis))
+	}
+	if perm := fis[0].Mode().Perm(); perm != 0644 {
+		t.Fatalf("invalid permissions. got=%v. want=%v\n", perm, os.FileMode(0644))
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lbpkr-test-verify-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const (
+		content = "some content"
+		sum     = "290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56"
+	)
+
+	fname := filepath.Join(dir, "file.txt")
+	err = ioutil.WriteFile(fname, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("could not write file: %v\n", err)
+	}
+
+	err = VerifyFile(fname, "sha256", sum)
+	if err != nil {
+		t.Fatalf("could not verify file: %v\n", err)
+	}
+
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatalf("could not stat file: %v\n", err)
+	}
+	key := checksumKey{path: fname, algo: "sha256", size: fi.Size(), mtime: fi.ModTime()}
+	checksums.Lock()
+	cached := checksums.m[key]
+	checksums.Unlock()
+	if cached != sum {
+		t.Fatalf("expected the checksum to be cached. got=%q\n", cached)
+	}
+
+	err = VerifyFile(fname, "sha256", "0123")
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+
+	// a modified file is checksummed again.
+	err = ioutil.WriteFile(fname, []byte(content+" modified"), 0644)
+	if err != nil {
+		t.Fatalf("could not write file: %v\n", err)
+	}
+	err = VerifyFile(fname, "sha256", sum)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch for a modified file. got=%v\n", err)
+	}
+
+	err = VerifyFile(fname, "crc32", sum)
+	if err == nil {
+		t.Fatalf("expected an error for an unknown checksum type\n")
+	}
+}
+
+func TestDecompressXz(t *testing.T) {
+	if _, err := exec.LookPath("xz"); err != nil {
+		t.Skipf("no xz command: %v\n", err)
+	}
+
+	// DBs compressed with xz are transparently loaded by the backends.
+	repo := newTestXMLRepository(t, "testdata/primary.xml.xz")
+	defer repo.Close()
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find package from xz DB: %v\n", err)
+	}
+	if pkg.ID() != "foo-2.0-1" {
+		t.Fatalf("expected foo-2.0-1. got=%s\n", pkg.ID())
+	}
+
+	data, err := ioutil.ReadFile("testdata/primary.xml.xz")
+	if err != nil {
+		t.Fatalf("could not read xz fixture: %v\n", err)
+	}
+
+	// truncated streams are reported, with the diagnostics of xz.
+	r, err := decompress(bytes.NewReader(data[:len(data)/2]), "primary.xml.xz")
+	if err != nil {
+		t.Fatalf("could not decompress truncated fixture: %v\n", err)
+	}
+	_, err = ioutil.ReadAll(r)
+	if err == nil {
+		t.Fatalf("expected an error decompressing a truncated xz stream\n")
+	}
+	if !strings.Contains(err.Error(), "xz decompression failed") {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	// test vectors for "abc", from FIPS 180-2 and RFC 1321.
+	for _, table := range []struct {
+		algo string
+		sum  string
+	}{
+		{"sha", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha1", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha224", "23097d223405d8228642a477bda255b32aadbce4bda0b3f7e36c9da7"},
+		{"sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"SHA256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha384", "cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7"},
+		{"sha512", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{"md5", "900150983cd24fb0d6963f7d28e17f72"},
+	} {
+		sum, err := checksumReader(strings.NewReader("abc"), table.algo)
+		if err != nil {
+			t.Errorf("%s: could not compute checksum: %v\n", table.algo, err)
+			continue
+		}
+		if sum != table.sum {
+			t.Errorf("%s: invalid checksum.\ngot= %s\nwant=%s\n", table.algo, sum, table.sum)
+		}
+	}
+
+	_, err := newHasher("crc32")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown checksum type\n")
+	}
+}
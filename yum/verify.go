@@ -0,0 +1,76 @@
+package yum
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// VerifyCache checks the integrity of the cache directory of the repository:
+// the checksum of each file of the cached repomd.xml file which is present
+// in the cache directory is computed again and compared with the one
+// recorded in repomd.xml.
+// Nothing is downloaded.
+// VerifyCache reports all the corrupted files, with an error wrapping
+// ErrChecksumMismatch for each of them.
+func (repo *Repository) VerifyCache() error {
+	data, err := repo.localMetadata()
+	if err != nil {
+		return err
+	}
+	if len(data) <= 0 {
+		return fmt.Errorf("%w: no cached repomd.xml for repository [%s]", ErrMetadataNotFound, repo.Name)
+	}
+
+	md, err := repo.checkRepoMD(data)
+	if err != nil {
+		return fmt.Errorf("yum: invalid cached repomd.xml for repository [%s]: %w", repo.Name, err)
+	}
+
+	dtypes := make([]string, 0, len(md))
+	for dtype := range md {
+		dtypes = append(dtypes, dtype)
+	}
+	sort.Strings(dtypes)
+
+	var errs []error
+	for _, dtype := range dtypes {
+		fname := repo.cacheFile(dtype, md[dtype])
+		if !path_exists(fname) {
+			continue
+		}
+		repo.msg.Debugf("repository [%s] - verifying [%s]\n", repo.Name, fname)
+		err = VerifyFile(fname, md[dtype].ChecksumType, md[dtype].Checksum)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dtype, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("yum: corrupted cache for repository [%s]: %w", repo.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// cacheFile returns the path in the cache directory to the file of type dtype
+// described by md.
+// The DBs of the backends are cached under their own name, the other files
+// under the name of their remote location.
+func (repo *Repository) cacheFile(dtype string, md RepoMD) string {
+	type dbFiler interface {
+		dbFile() string
+	}
+
+	for _, bname := range RegisteredBackends() {
+		ba, err := NewBackend(bname, repo)
+		if err != nil || ba.YumDataType() != dtype {
+			continue
+		}
+		if f, ok := ba.(dbFiler); ok {
+			return f.dbFile()
+		}
+	}
+	return filepath.Join(repo.CacheDir, path.Base(md.Location))
+}
@@ -0,0 +1,61 @@
+package yum
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryVerifyCache(t *testing.T) {
+	tmp := newTestRemote(t, "testdata/testconfig-xml/var/cache/lbyum/lcg")
+	defer os.RemoveAll(tmp)
+	cachedir := filepath.Join(tmp, "repodata")
+
+	repo, err := NewRepository(
+		"lcg", "http://dummy-url.org", cachedir,
+		[]string{"RepositoryXMLBackend"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+	defer repo.Close()
+
+	err = repo.VerifyCache()
+	if err != nil {
+		t.Fatalf("expected a valid cache: %v\n", err)
+	}
+
+	// corrupt the cached DB and add a corrupted filelists DB.
+	for _, fname := range []string{"primary.xml.gz", "filelists.xml.gz"} {
+		err = ioutil.WriteFile(filepath.Join(cachedir, fname), []byte("corrupted"), 0644)
+		if err != nil {
+			t.Fatalf("could not corrupt [%s]: %v\n", fname, err)
+		}
+	}
+
+	err = repo.VerifyCache()
+	if err == nil {
+		t.Fatalf("expected a corrupted cache\n")
+	}
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch. got=%v\n", err)
+	}
+	for _, fname := range []string{"primary.xml.gz", "filelists.xml.gz"} {
+		if !strings.Contains(err.Error(), fname) {
+			t.Fatalf("expected [%s] to be reported. got=%v\n", fname, err)
+		}
+	}
+
+	err = os.Remove(repo.LocalRepoMdXml)
+	if err != nil {
+		t.Fatalf("could not remove repomd.xml: %v\n", err)
+	}
+	err = repo.VerifyCache()
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("expected ErrMetadataNotFound. got=%v\n", err)
+	}
+}
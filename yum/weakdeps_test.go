@@ -0,0 +1,87 @@
+package yum
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWeakDependencies(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/weakdeps.xml")
+	defer repo.Close()
+
+	editor, err := repo.FindLatestMatchingName("editor", "", "")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+	if n := len(editor.Recommends()); n != 2 {
+		t.Fatalf("expected 2 recommends. got=%d\n", n)
+	}
+	if n := len(editor.Suggests()); n != 2 {
+		t.Fatalf("expected 2 suggests. got=%d\n", n)
+	}
+	if rec := editor.Recommends()[0]; rec.Name() != "spell" || rec.Flags() != "GE" || rec.Version() != "1.0" {
+		t.Fatalf("invalid recommends entry: %v\n", rec)
+	}
+
+	// weak dependencies survive the on-disk index.
+	dir, err := ioutil.TempDir("", "lbpkr-test-index-")
+	if err != nil {
+		t.Fatalf("could not create tmpdir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	xrepo := repo.Backend.(*RepositoryXMLBackend)
+	xrepo.Index = filepath.Join(dir, "weakdeps.index")
+	err = xrepo.saveIndex("key")
+	if err != nil {
+		t.Fatalf("could not save index: %v\n", err)
+	}
+	cached, err := NewRepositoryXMLBackend(repo)
+	if err != nil {
+		t.Fatalf("could not create backend: %v\n", err)
+	}
+	cached.Index = xrepo.Index
+	err = cached.loadIndex("key")
+	if err != nil {
+		t.Fatalf("could not load index: %v\n", err)
+	}
+	p := cached.Packages["editor"][0]
+	if !reflect.DeepEqual(p.Recommends(), editor.Recommends()) || !reflect.DeepEqual(p.Suggests(), editor.Suggests()) {
+		t.Fatalf("weak dependencies lost by the index.\ngot= %v %v\nwant=%v %v\n",
+			p.Recommends(), p.Suggests(), editor.Recommends(), editor.Suggests(),
+		)
+	}
+
+	for _, table := range []struct {
+		exclude  bool
+		expected []string
+	}{
+		{false, []string{"libed", "spell", "editor"}},
+		{true, []string{"libed", "editor"}},
+	} {
+		repo.ExcludeRecommends = table.exclude
+		// a missing recommended package is not an error.
+		plan, err := repo.PlanInstall([]string{"editor"})
+		if err != nil {
+			t.Fatalf("exclude=%v: could not plan install: %v\n", table.exclude, err)
+		}
+		names := make([]string, 0, len(plan.Packages))
+		for _, pkg := range plan.Packages {
+			names = append(names, pkg.Name)
+		}
+		if !reflect.DeepEqual(names, table.expected) {
+			t.Fatalf("exclude=%v: expected %v. got=%v\n", table.exclude, table.expected, names)
+		}
+
+		// suggested packages are listed, not installed.
+		suggests := []string{"editor-doc"}
+		if table.exclude {
+			suggests = append(suggests, "spell")
+		}
+		if !reflect.DeepEqual(plan.Suggests, suggests) {
+			t.Fatalf("exclude=%v: expected suggests %v. got=%v\n", table.exclude, suggests, plan.Suggests)
+		}
+	}
+}
@@ -1,26 +1,27 @@
 package yum
 
 import (
-	"compress/gzip"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
-
-	"github.com/gonuts/logger"
+	"strings"
 )
 
 // RepositoryXMLBackend is a Backend querying YUM XML repositories
 type RepositoryXMLBackend struct {
 	Name       string
-	Packages   map[string][]*Package
-	Provides   map[string][]*Provides
+	Packages   map[string][]*Package  // packages, indexed by name
+	Provides   map[string][]*Provides // provides, indexed by capability name
 	DBName     string
 	Primary    string
+	Index      string // index of the parsed DB
+	pending    string // key of the index still to be written, if any
 	Repository *Repository
-	msg        *logger.Logger
+	msg        Logger
 }
 
 func NewRepositoryXMLBackend(repo *Repository) (*RepositoryXMLBackend, error) {
@@ -31,6 +32,7 @@ func NewRepositoryXMLBackend(repo *Repository) (*RepositoryXMLBackend, error) {
 		Provides:   make(map[string][]*Provides),
 		DBName:     dbname,
 		Primary:    filepath.Join(repo.CacheDir, dbname),
+		Index:      filepath.Join(repo.CacheDir, dbname+".index"),
 		Repository: repo,
 		msg:        repo.msg,
 	}, nil
@@ -48,20 +50,16 @@ func (repo *RepositoryXMLBackend) YumDataType() string {
 
 // Download the DB from server
 func (repo *RepositoryXMLBackend) GetLatestDB(url string) error {
-	var err error
-	out, err := os.Create(repo.Primary)
+	r, err := repo.Repository.download(repo.Repository.context(), url)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer r.Close()
 
-	r, err := getRemoteData(url)
-	if err != nil {
+	return write_file_atomic(repo.Primary, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
 		return err
-	}
-	defer r.Close()
-	_, err = io.Copy(out, r)
-	return err
+	})
 }
 
 // Check whether the DB is there
@@ -69,10 +67,39 @@ func (repo *RepositoryXMLBackend) HasDB() bool {
 	return path_exists(repo.Primary)
 }
 
+// dbFile returns the path to the DB, as downloaded, in the cache directory.
+func (repo *RepositoryXMLBackend) dbFile() string {
+	return repo.Primary
+}
+
+// cacheFiles returns the files of the backend in the cache directory.
+func (repo *RepositoryXMLBackend) cacheFiles() []string {
+	return []string{repo.Primary, repo.Index}
+}
+
 // Load loads the DB
+// If the index of a previous parsing of the same DB is available in the
+// cache directory, it is loaded instead of parsing the DB again.
 func (repo *RepositoryXMLBackend) LoadDB() error {
 	var err error
 
+	repo.Packages = make(map[string][]*Package)
+	repo.Provides = make(map[string][]*Provides)
+	repo.pending = ""
+
+	key := repo.indexKey()
+	if key != "" {
+		err = repo.loadIndex(key)
+		if err == nil {
+			repo.msg.Debugf("loaded index [%s]\n", repo.Index)
+			return nil
+		}
+		repo.msg.Debugf("could not load index [%s]: %v\n", repo.Index, err)
+		repo.Packages = make(map[string][]*Package)
+		repo.Provides = make(map[string][]*Provides)
+		err = nil
+	}
+
 	repo.msg.Debugf("start parsing metadata XML file... (%s)\n", repo.Primary)
 	type xmlTree struct {
 		XMLName  xml.Name `xml:"metadata"`
@@ -142,6 +169,38 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 					Pre     string `xml:"pre,attr"`
 				} `xml:"requires>entry"`
 
+				Obsoletes []struct {
+					Name    string `xml:"name,attr"`
+					Flags   string `xml:"flags,attr"`
+					Epoch   string `xml:"epoch,attr"`
+					Version string `xml:"ver,attr"`
+					Release string `xml:"rel,attr"`
+				} `xml:"obsoletes>entry"`
+
+				Conflicts []struct {
+					Name    string `xml:"name,attr"`
+					Flags   string `xml:"flags,attr"`
+					Epoch   string `xml:"epoch,attr"`
+					Version string `xml:"ver,attr"`
+					Release string `xml:"rel,attr"`
+				} `xml:"conflicts>entry"`
+
+				Recommends []struct {
+					Name    string `xml:"name,attr"`
+					Flags   string `xml:"flags,attr"`
+					Epoch   string `xml:"epoch,attr"`
+					Version string `xml:"ver,attr"`
+					Release string `xml:"rel,attr"`
+				} `xml:"recommends>entry"`
+
+				Suggests []struct {
+					Name    string `xml:"name,attr"`
+					Flags   string `xml:"flags,attr"`
+					Epoch   string `xml:"epoch,attr"`
+					Version string `xml:"ver,attr"`
+					Release string `xml:"rel,attr"`
+				} `xml:"suggests>entry"`
+
 				Files []string `xml:"file"`
 			} `xml:"format"`
 		} `xml:"package"`
@@ -154,20 +213,12 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 	}
 	defer f.Close()
 
-	var r io.Reader
-	if rr, err := gzip.NewReader(f); err != nil {
-		if err != gzip.ErrHeader {
-			return err
-		}
-		// perhaps not a compressed file after all...
-		_, err = f.Seek(0, 0)
-		if err != nil {
-			return err
-		}
-		r = f
-	} else {
-		r = rr
-		defer rr.Close()
+	r, err := decompress(f, repo.Primary)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
 	}
 
 	var tree xmlTree
@@ -184,6 +235,17 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 		pkg.arch = xml.Arch
 		pkg.group = xml.Format.Group
 		pkg.location = xml.Location.Href
+		pkg.size = xml.Size.Package
+		pkg.isize = xml.Size.Installed
+		pkg.checksum = strings.TrimSpace(xml.Checksum.Value)
+		pkg.chksumType = xml.Checksum.Type
+		pkg.info = &PackageInfo{
+			Summary:     strings.TrimSpace(xml.Summary),
+			Description: strings.TrimSpace(xml.Descr),
+			Packager:    strings.TrimSpace(xml.Packager),
+			URL:         strings.TrimSpace(xml.Url),
+			License:     strings.TrimSpace(xml.Format.License),
+		}
 		for _, v := range xml.Format.Provides {
 			prov := NewProvides(
 				v.Name,
@@ -194,10 +256,6 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 				pkg,
 			)
 			pkg.provides = append(pkg.provides, prov)
-
-			if !str_in_slice(prov.Name(), IGNORED_PACKAGES) {
-				repo.Provides[prov.Name()] = append(repo.Provides[prov.Name()], prov)
-			}
 		}
 
 		for _, v := range xml.Format.Requires {
@@ -211,23 +269,92 @@ func (repo *RepositoryXMLBackend) LoadDB() error {
 			)
 			pkg.requires = append(pkg.requires, req)
 		}
+
+		for _, v := range xml.Format.Obsoletes {
+			obs := NewRequires(
+				v.Name,
+				v.Version,
+				v.Release,
+				v.Epoch,
+				v.Flags,
+				"",
+			)
+			pkg.obsoletes = append(pkg.obsoletes, obs)
+		}
+
+		for _, v := range xml.Format.Conflicts {
+			conflict := NewRequires(
+				v.Name,
+				v.Version,
+				v.Release,
+				v.Epoch,
+				v.Flags,
+				"",
+			)
+			pkg.conflicts = append(pkg.conflicts, conflict)
+		}
+
+		for _, v := range xml.Format.Recommends {
+			rec := NewRequires(
+				v.Name,
+				v.Version,
+				v.Release,
+				v.Epoch,
+				v.Flags,
+				"",
+			)
+			pkg.recommends = append(pkg.recommends, rec)
+		}
+
+		for _, v := range xml.Format.Suggests {
+			sug := NewRequires(
+				v.Name,
+				v.Version,
+				v.Release,
+				v.Epoch,
+				v.Flags,
+				"",
+			)
+			pkg.suggests = append(pkg.suggests, sug)
+		}
 		pkg.repository = repo.Repository
+		pkg.backend = "RepositoryXMLBackend"
 
 		// add package to repository
-		repo.Packages[pkg.Name()] = append(repo.Packages[pkg.Name()], pkg)
-		repo.msg.Debugf(
-			"(repo=%s) added package: %s.%s-%s\n",
-			repo.Primary,
-			pkg.Name(),
-			pkg.Version(),
-			pkg.Release(),
-		)
+		repo.addPackage(pkg)
 	}
 
 	repo.msg.Debugf("start parsing metadata XML file... (%s) [done]\n", repo.Primary)
+
+	if key != "" {
+		err = repo.saveIndex(key)
+		if err != nil {
+			repo.msg.Warnf("could not save index [%s]: %v\n", repo.Index, err)
+			repo.pending = key
+			err = nil
+		}
+	}
 	return err
 }
 
+// addPackage adds pkg to the packages and provides known by the backend
+func (repo *RepositoryXMLBackend) addPackage(pkg *Package) {
+	for _, prov := range pkg.provides {
+		if !str_in_slice(prov.Name(), IGNORED_PACKAGES) {
+			repo.Provides[prov.Name()] = append(repo.Provides[prov.Name()], prov)
+		}
+	}
+
+	repo.Packages[pkg.Name()] = append(repo.Packages[pkg.Name()], pkg)
+	repo.msg.Debugf(
+		"(repo=%s) added package: %s.%s-%s\n",
+		repo.Primary,
+		pkg.Name(),
+		pkg.Version(),
+		pkg.Release(),
+	)
+}
+
 // FindLatestMatchingName locats a package by name, returns the latest available version.
 func (repo *RepositoryXMLBackend) FindLatestMatchingName(name, version, release string) (*Package, error) {
 	var pkg *Package
@@ -263,6 +390,21 @@ func (repo *RepositoryXMLBackend) FindLatestMatchingName(name, version, release
 	return pkg, err
 }
 
+// FindAllMatchingName locates all the packages with a given name, sorted from
+// the newest to the oldest version.
+func (repo *RepositoryXMLBackend) FindAllMatchingName(name string) ([]*Package, error) {
+	pkgs, ok := repo.Packages[name]
+	if !ok || len(pkgs) <= 0 {
+		repo.msg.Debugf("could not find package %q\n", name)
+		return nil, fmt.Errorf("no such package %q", name)
+	}
+
+	sorted := make([]*Package, len(pkgs))
+	copy(sorted, pkgs)
+	sort.Stable(sort.Reverse(Packages(sorted)))
+	return sorted, nil
+}
+
 // FindLatestMatchingRequire locates a package providing a given functionality.
 func (repo *RepositoryXMLBackend) FindLatestMatchingRequire(requirement *Requires) (*Package, error) {
 	var pkg *Package
@@ -313,8 +455,112 @@ func (repo *RepositoryXMLBackend) GetPackages() []*Package {
 	return pkgs
 }
 
+// walkPackages calls fn for each package known by the backend, until fn
+// returns an error.
+func (repo *RepositoryXMLBackend) walkPackages(fn func(pkg *Package) error) error {
+	for _, pkgs := range repo.Packages {
+		for _, pkg := range pkgs {
+			err := fn(pkg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findProviders returns the packages named name or providing the
+// capability name, whatever their version.
+func (repo *RepositoryXMLBackend) findProviders(name string) ([]*Package, error) {
+	seen := make(map[*Package]bool)
+	pkgs := make([]*Package, 0)
+	add := func(pkg *Package) {
+		if pkg != nil && !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	for _, pkg := range repo.Packages[name] {
+		add(pkg)
+	}
+	for _, prov := range repo.Provides[name] {
+		add(prov.Package)
+	}
+	return pkgs, nil
+}
+
+// counts returns the number of packages, provides and requires entries.
+func (repo *RepositoryXMLBackend) counts() (int64, int64, int64, error) {
+	var packages, provides, requires int64
+	for _, pkgs := range repo.Packages {
+		n, p, r := countPackages(pkgs)
+		packages += n
+		provides += p
+		requires += r
+	}
+	return packages, provides, requires, nil
+}
+
+// ListPackages returns the packages whose name matches the shell pattern,
+// sorted by name and version.
+func (repo *RepositoryXMLBackend) ListPackages(pattern string) ([]*Package, error) {
+	pkgs := make(Packages, 0)
+	for name, p := range repo.Packages {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pkgs = append(pkgs, p...)
+		}
+	}
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// FindObsoleting returns the packages obsoleting the package name,
+// sorted by name and version.
+func (repo *RepositoryXMLBackend) FindObsoleting(name string) ([]*Package, error) {
+	pkgs := make(Packages, 0)
+	for _, p := range repo.Packages {
+		for _, pkg := range p {
+			for _, obs := range pkg.Obsoletes() {
+				if obs.Name() == name {
+					pkgs = append(pkgs, pkg)
+					break
+				}
+			}
+		}
+	}
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+// FindRequiring returns the packages requiring the capability name,
+// sorted by name and version.
+func (repo *RepositoryXMLBackend) FindRequiring(name string) ([]*Package, error) {
+	pkgs := make(Packages, 0)
+	for _, p := range repo.Packages {
+		for _, pkg := range p {
+			for _, req := range pkg.Requires() {
+				if req.Name() == name {
+					pkgs = append(pkgs, pkg)
+					break
+				}
+			}
+		}
+	}
+	sort.Sort(pkgs)
+	return pkgs, nil
+}
+
+func (repo *RepositoryXMLBackend) setLogger(msg Logger) {
+	repo.msg = msg
+}
+
 func init() {
 	g_backends["RepositoryXMLBackend"] = func(repo *Repository) (Backend, error) {
 		return NewRepositoryXMLBackend(repo)
 	}
+	g_backends["primary"] = g_backends["RepositoryXMLBackend"]
 }
@@ -0,0 +1,221 @@
+package yum
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestXMLRepository returns a Repository whose XML backend was loaded from fname.
+func newTestXMLRepository(t *testing.T, fname string) *Repository {
+	repo, err := NewRepository("testrepo", "http://dummy-url.org", "testdata/cachedir.tmp",
+		[]string{"primary"},
+		false, false,
+	)
+	if err != nil {
+		t.Fatalf("could not create repository: %v\n", err)
+	}
+
+	backend, err := NewBackend("primary", repo)
+	if err != nil {
+		t.Fatalf("could not create backend: %v\n", err)
+	}
+	backend.(*RepositoryXMLBackend).Primary = fname
+
+	repo.Backend = backend
+	err = repo.Backend.LoadDB()
+	if err != nil {
+		t.Fatalf("could not load DB [%s]: %v\n", fname, err)
+	}
+	return repo
+}
+
+func TestXMLBackendRelations(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	if dt := repo.Backend.YumDataType(); dt != "primary" {
+		t.Fatalf("expected data type %q. got=%q\n", "primary", dt)
+	}
+
+	pkg, err := repo.FindLatestMatchingName("foo", "", "")
+	if err != nil {
+		t.Fatalf("could not find foo: %v\n", err)
+	}
+
+	if n := len(pkg.Obsoletes()); n != 1 {
+		t.Fatalf("expected 1 obsoletes entry. got=%d\n", n)
+	}
+	obs := pkg.Obsoletes()[0]
+	if obs.Name() != "oldfoo" || obs.Flags() != "LT" || obs.Version() != "2.0" {
+		t.Fatalf("invalid obsoletes entry: %s %s %s\n", obs.Name(), obs.Flags(), obs.Version())
+	}
+
+	if n := len(pkg.Conflicts()); n != 1 {
+		t.Fatalf("expected 1 conflicts entry. got=%d\n", n)
+	}
+	conflict := pkg.Conflicts()[0]
+	if conflict.Name() != "baz" || conflict.Flags() != "LT" || conflict.Version() != "1.5" {
+		t.Fatalf("invalid conflicts entry: %s %s %s\n", conflict.Name(), conflict.Flags(), conflict.Version())
+	}
+
+	req := NewRequires("libfoo.so", "", "", "", "EQ", "")
+	prov, err := repo.FindLatestMatchingRequire(req)
+	if err != nil {
+		t.Fatalf("could not find provider of libfoo.so: %v\n", err)
+	}
+	if prov.Name() != "foo" {
+		t.Fatalf("expected foo to provide libfoo.so. got=%q\n", prov.Name())
+	}
+}
+
+func TestXMLBackendFindAllMatchingName(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	pkgs, err := repo.FindAllMatchingName("foo")
+	if err != nil {
+		t.Fatalf("could not find foo: %v\n", err)
+	}
+
+	want := []string{"foo-2.0-1.x86_64", "foo-1.5-2.i686", "foo-1.5-1.x86_64"}
+	if len(pkgs) != len(want) {
+		t.Fatalf("expected %d packages. got=%d\n", len(want), len(pkgs))
+	}
+	for i, pkg := range pkgs {
+		id := pkg.Name() + "-" + pkg.Version() + "-" + pkg.Release() + "." + pkg.Arch()
+		if id != want[i] {
+			t.Fatalf("pkg[%d]: expected %q. got=%q\n", i, want[i], id)
+		}
+	}
+
+	_, err = repo.FindAllMatchingName("nosuchpkg")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown package\n")
+	}
+}
+
+func TestXMLBackendListPackages(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"foo", []string{"foo-1.5-1", "foo-1.5-2", "foo-2.0-1"}},
+		{"ba?", []string{"bar-1.0-1", "baz-1.0-1"}},
+		{"cyc-*", []string{"cyc-a-1.0-1", "cyc-b-1.0-1"}},
+		{"*flict*", []string{"conflicted-1.0-1"}},
+		{"[bq]*", []string{"bar-1.0-1", "baz-1.0-1", "qux-1.0-1"}},
+		{"nosuchpkg*", []string{}},
+	} {
+		pkgs, err := repo.ListPackages(table.pattern)
+		if err != nil {
+			t.Fatalf("%q: could not list packages: %v\n", table.pattern, err)
+		}
+		ids := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.ID())
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(table.want) {
+			t.Fatalf("%q: expected %v. got=%v\n", table.pattern, table.want, ids)
+		}
+	}
+
+	_, err := repo.ListPackages("[foo")
+	if err == nil {
+		t.Fatalf("expected an error for an invalid pattern\n")
+	}
+}
+
+func TestXMLBackendFindObsoleting(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	for _, table := range []struct {
+		name string
+		want []string
+	}{
+		{"oldfoo", []string{"foo-1.5-1", "foo-2.0-1"}},
+		{"foo", []string{}},
+		{"nosuchpkg", []string{}},
+	} {
+		pkgs, err := repo.FindObsoleting(table.name)
+		if err != nil {
+			t.Fatalf("%q: could not find obsoleting packages: %v\n", table.name, err)
+		}
+		ids := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.ID())
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(table.want) {
+			t.Fatalf("%q: expected %v. got=%v\n", table.name, table.want, ids)
+		}
+	}
+}
+
+func TestXMLBackendStats(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	stats := repo.Stats()
+	if stats.Backend != "*yum.RepositoryXMLBackend" {
+		t.Fatalf("invalid backend. got=%q\n", stats.Backend)
+	}
+	if stats.Packages != 10 {
+		t.Fatalf("invalid number of packages. got=%d. want=%d\n", stats.Packages, 10)
+	}
+	if stats.Provides != 11 {
+		t.Fatalf("invalid number of provides. got=%d. want=%d\n", stats.Provides, 11)
+	}
+	if stats.Requires != 10 {
+		t.Fatalf("invalid number of requires. got=%d. want=%d\n", stats.Requires, 10)
+	}
+	if !stats.Timestamp.IsZero() {
+		t.Fatalf("expected no timestamp without repomd.xml. got=%v\n", stats.Timestamp)
+	}
+
+	n, p, r := countPackages(repo.GetPackages())
+	if n != stats.Packages || p != stats.Provides || r != stats.Requires {
+		t.Fatalf("inconsistent counts: (%d, %d, %d) != %#v\n", n, p, r, stats)
+	}
+
+	err := copyTestFile(repo.LocalRepoMdXml, "testdata/testconfig-xml/var/cache/lbyum/lcg/repomd.xml")
+	if err != nil {
+		t.Fatalf("could not copy repomd.xml: %v\n", err)
+	}
+	defer os.RemoveAll(repo.LocalRepoMdXml)
+
+	stats = repo.Stats()
+	if want := time.Unix(1343662777, 0); !stats.Timestamp.Equal(want) {
+		t.Fatalf("invalid timestamp. got=%v. want=%v\n", stats.Timestamp, want)
+	}
+}
+
+func TestXMLBackendPackageInfo(t *testing.T) {
+	repo := newTestXMLRepository(t, "testdata/primary.xml")
+	defer repo.Close()
+
+	pkg, err := repo.FindLatestMatchingName("foo", "2.0", "1")
+	if err != nil {
+		t.Fatalf("could not find package: %v\n", err)
+	}
+
+	info, err := pkg.Info()
+	if err != nil {
+		t.Fatalf("could not load package info: %v\n", err)
+	}
+
+	want := PackageInfo{
+		Summary:     "The foo package",
+		Description: "foo provides the foo library.",
+		Packager:    "LHCb librarian",
+		URL:         "http://lhcb.cern.ch/foo",
+		License:     "GPL",
+	}
+	if info != want {
+		t.Fatalf("invalid package info.\ngot= %#v\nwant=%#v\n", info, want)
+	}
+}
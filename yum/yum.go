@@ -62,14 +62,17 @@ func newClient(siteroot string, backends []string, checkForUpdates, manualConfig
 	return client, err
 }
 
+// defaultBackends are the backends of the repositories, in order of preference.
+var defaultBackends = []string{
+	"RepositorySQLiteBackend",
+	"RepositoryXMLBackend",
+}
+
 // New returns a new YUM Client, rooted at siteroot.
 func New(siteroot string) (*Client, error) {
 	checkForUpdates := true
 	manualConfig := false
-	backends := []string{
-		"RepositorySQLiteBackend",
-		"RepositoryXMLBackend",
-	}
+	backends := append([]string(nil), defaultBackends...)
 	return newClient(siteroot, backends, checkForUpdates, manualConfig)
 }
 
@@ -80,7 +83,7 @@ func (yum *Client) Close() error {
 		e := repo.Close()
 		if e != nil {
 			yum.msg.Errorf("error closing repo [%s]: %v\n", name, e)
-			e = err
+			err = e
 		} else {
 			yum.msg.Debugf("closed repo [%s]\n", name)
 		}
@@ -92,7 +95,7 @@ func (yum *Client) Close() error {
 func (yum *Client) SetLevel(lvl logger.Level) {
 	yum.msg.SetLevel(lvl)
 	for _, repo := range yum.repos {
-		repo.msg.SetLevel(lvl)
+		repo.SetLogLevel(lvl)
 	}
 }
 
@@ -227,7 +230,7 @@ func (yum *Client) pkgDeps(pkg *Package, processed map[string]*Package, maxdepth
 	var lasterr error
 	msg := yum.msg
 
-	processed[pkg.ID()] = pkg
+	processed[pkg.Key()] = pkg
 	required := make(map[string]*Package)
 
 	if maxdepth == 0 {
@@ -248,7 +251,7 @@ func (yum *Client) pkgDeps(pkg *Package, processed map[string]*Package, maxdepth
 			msg.Errorf("could not find match for %s\n", req.ID())
 			continue
 		}
-		if _, dup := processed[p.ID()]; dup {
+		if _, dup := processed[p.Key()]; dup {
 			msg.Debugf("package %s already processed (required by pkg=%s | req=%s)\n", p.ID(), pkg.ID(), req.ID())
 			continue
 		}
@@ -259,7 +262,7 @@ func (yum *Client) pkgDeps(pkg *Package, processed map[string]*Package, maxdepth
 			//return nil, fmt.Errorf("package %s.%s-%s not found", req.Name(), req.Version(), req.Release())
 		}
 		msg.Verbosef("--> adding dep %s\n", p.ID())
-		required[p.ID()] = p
+		required[p.Key()] = p
 		if maxdepth < 0 || maxdepth > idepth+1 {
 			sdeps, err := yum.pkgDeps(p, processed, maxdepth, idepth+1)
 			if err != nil {
@@ -267,7 +270,7 @@ func (yum *Client) pkgDeps(pkg *Package, processed map[string]*Package, maxdepth
 				continue
 			}
 			for _, sdep := range sdeps {
-				required[sdep.ID()] = sdep
+				required[sdep.Key()] = sdep
 			}
 		}
 	}
@@ -363,7 +366,7 @@ func (yum *Client) initRepositories(urls map[string]string, checkForUpdates bool
 			)
 			return err
 		}
-		r.msg = yum.msg
+		r.SetLogger(stdLogger{yum.msg})
 		yum.repos[repo] = r
 	}
 